@@ -0,0 +1,230 @@
+package data
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"slices"
+)
+
+// ALTER TABLE changes the columns of a table without rewriting its tuples, the versions keep the data they were
+// written with. Therefore the columns of a table are only appended:
+//   - a dropped column keeps its place, it gets hidden and renamed, so the data of the others stays where it is.
+//   - an added column gets the next place. The versions written before are shorter, they read the column with
+//     the value it was added with.
+//   - a column getting another type keeps its place, a hidden column gets appended. The versions written before
+//     are not longer than the hidden column, their values of the column are converted when they are read.
+//
+// The tuples are read in the current shape by the iterators, the new versions are written in it.
+
+type columnChange struct {
+	width   int                                      // of the table before the change, the place of the column appended
+	column  int                                      // the column added or converted
+	value   driver.Value                             // of the column added in the versions written before
+	convert func(driver.Value) (driver.Value, error) // of the values of the column converted, nil if it was added
+}
+
+// the values of a version in the current shape of the table
+func (t *GoSqlTable) shape(values []driver.Value) ([]driver.Value, error) {
+	changes := t.columnChanges.Load()
+	if changes == nil || len(values) > (*changes)[len(*changes)-1].width {
+		return values, nil
+	}
+	res := make([]driver.Value, (*changes)[len(*changes)-1].width+1)
+	copy(res, values)
+	for _, change := range *changes {
+		if change.width < len(values) {
+			continue
+		}
+		if change.convert == nil {
+			res[change.column] = change.value
+			continue
+		}
+		if res[change.column] == nil {
+			continue
+		}
+		converted, err := change.convert(res[change.column])
+		if err != nil {
+			return nil, err
+		}
+		res[change.column] = converted
+	}
+	return res, nil
+}
+
+// the values of a stored version in the current shape, their conversions were checked by ALTER TABLE
+func (t *GoSqlTable) shaped(values []driver.Value) []driver.Value {
+	res, err := t.shape(values)
+	if err != nil {
+		panic(fmt.Sprintf("table %s: %v", t.TableName, err))
+	}
+	return res
+}
+
+// changes the columns, while no tuple of the table gets changed. alter gets a copy of the columns.
+func (t *GoSqlTable) alterColumns(alter func(columns []GoSqlColumn) ([]GoSqlColumn, *columnChange, error)) error {
+	t.uniqueMu.Lock()
+	defer t.uniqueMu.Unlock()
+	t.indexMu.Lock()
+	defer t.indexMu.Unlock()
+	columns, change, err := alter(slices.Clone(t.TableColumns))
+	if err != nil {
+		return err
+	}
+	if change != nil {
+		var changes []columnChange
+		if old := t.columnChanges.Load(); old != nil {
+			changes = slices.Clone(*old)
+		}
+		changes = append(changes, *change)
+		t.columnChanges.Store(&changes)
+	}
+	t.TableColumns = columns
+	return nil
+}
+
+func (t *GoSqlTable) findVisibleColumn(columns []GoSqlColumn, name string) (int, error) {
+	ix := slices.IndexFunc(columns, func(col GoSqlColumn) bool { return col.Name == name && !col.Hidden })
+	if ix < 0 {
+		return -1, fmt.Errorf("column %s of table %s does not exist", name, t.TableName)
+	}
+	return ix, nil
+}
+
+// AddColumn appends the column, the tuples existing get value. validate is called before, while no tuple of the
+// table gets changed.
+func (t *GoSqlTable) AddColumn(column GoSqlColumn, value driver.Value, validate func() error) error {
+	return t.alterColumns(func(columns []GoSqlColumn) ([]GoSqlColumn, *columnChange, error) {
+		if slices.ContainsFunc(columns, func(col GoSqlColumn) bool { return col.Name == column.Name }) {
+			return nil, nil, fmt.Errorf("column %s of table %s already exists", column.Name, t.TableName)
+		}
+		err := validate()
+		if err != nil {
+			return nil, nil, err
+		}
+		return append(columns, column), &columnChange{len(columns), len(columns), value, nil}, nil
+	})
+}
+
+// DropColumn hides the column, the indexes and foreign keys of the table containing it are dropped as well as
+// the CHECKs refering to it. A column referenced by a foreign key of another table can not be dropped.
+func (t *GoSqlTable) DropColumn(name string, refersTo func(check any) bool) error {
+	referencing := t.ReferencingKeys()
+	return t.alterColumns(func(columns []GoSqlColumn) ([]GoSqlColumn, *columnChange, error) {
+		ix, err := t.findVisibleColumn(columns, name)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, fk := range referencing {
+			if fk.Child != t && slices.Contains(fk.ParentColumns, ix) {
+				return nil, nil, fmt.Errorf("cannot drop column %s of table %s, foreign key %s of table %s requires it",
+					name, t.TableName, fk.Name, fk.Child.TableName)
+			}
+		}
+		t.indexes = slices.DeleteFunc(t.indexes, func(index *GoSqlIndex) bool { return slices.Contains(index.Columns, ix) })
+		t.foreignKeys = slices.DeleteFunc(t.foreignKeys, func(fk *GoSqlForeignKey) bool {
+			return slices.Contains(fk.Columns, ix) || fk.Parent == t && slices.Contains(fk.ParentColumns, ix)
+		})
+		for i := range columns {
+			if columns[i].Check != nil && refersTo(columns[i].Check) {
+				columns[i].Check = nil
+			}
+		}
+		columns[ix] = GoSqlColumn{fmt.Sprintf("........dropped.%d........", ix), columns[ix].ColType, columns[ix].ParserType,
+			columns[ix].Length, 0, true, false, nil, nil}
+		// the sequence of an autoincrement column is kept, not owned anymore
+		t.mu.Lock()
+		delete(t.sequences, name)
+		t.mu.Unlock()
+		return columns, nil, nil
+	})
+}
+
+// RenameColumn renames the column, rename returns the DEFAULTs and CHECKs of the columns refering to it by the
+// new name.
+func (t *GoSqlTable) RenameColumn(name string, newName string, rename func(term any) any) error {
+	return t.alterColumns(func(columns []GoSqlColumn) ([]GoSqlColumn, *columnChange, error) {
+		ix, err := t.findVisibleColumn(columns, name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if slices.ContainsFunc(columns, func(col GoSqlColumn) bool { return col.Name == newName }) {
+			return nil, nil, fmt.Errorf("column %s of table %s already exists", newName, t.TableName)
+		}
+		columns[ix].Name = newName
+		for i := range columns {
+			if columns[i].Default != nil {
+				columns[i].Default = rename(columns[i].Default)
+			}
+			if columns[i].Check != nil {
+				columns[i].Check = rename(columns[i].Check)
+			}
+		}
+		t.mu.Lock()
+		if s, ok := t.sequences[name]; ok {
+			delete(t.sequences, name)
+			t.sequences[newName] = s
+		}
+		t.mu.Unlock()
+		return columns, nil, nil
+	})
+}
+
+// AlterColumnType replaces the column by column having another type, convert converts the values of the versions
+// written before, nil if they keep their values. They are checked to convert, while no tuple of the table gets
+// changed. A column being part of an index or a foreign key can not be altered.
+func (t *GoSqlTable) AlterColumnType(name string, column GoSqlColumn, convert func(driver.Value) (driver.Value, error)) error {
+	referencing := t.ReferencingKeys()
+	return t.alterColumns(func(columns []GoSqlColumn) ([]GoSqlColumn, *columnChange, error) {
+		ix, err := t.findVisibleColumn(columns, name)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, index := range t.indexes {
+			if slices.Contains(index.Columns, ix) {
+				return nil, nil, fmt.Errorf("cannot alter type of column %s, index %s contains it", name, index.Name)
+			}
+		}
+		for _, fk := range append(referencing, t.foreignKeys...) {
+			if fk.Child == t && slices.Contains(fk.Columns, ix) || fk.Parent == t && slices.Contains(fk.ParentColumns, ix) {
+				return nil, nil, fmt.Errorf("cannot alter type of column %s, foreign key %s contains it", name, fk.Name)
+			}
+		}
+		columns[ix] = column
+		if convert == nil {
+			return columns, nil, nil
+		}
+		err = t.checkConversion(ix, convert)
+		if err != nil {
+			return nil, nil, err
+		}
+		marker := GoSqlColumn{fmt.Sprintf("........converted.%d........", len(columns)), column.ColType, column.ParserType, 0, 0, true, false, nil, nil}
+		return append(columns, marker), &columnChange{len(columns), ix, nil, convert}, nil
+	})
+}
+
+// checks, that the values of the column in all versions convert
+func (t *GoSqlTable) checkConversion(column int, convert func(driver.Value) (driver.Value, error)) error {
+	t.mu.RLock()
+	tuples := make([]*VersionedTuple, 0, t.data.Size())
+	it := t.data.Iterator()
+	for it.Next() {
+		tuples = append(tuples, it.Value().(*VersionedTuple))
+	}
+	t.mu.RUnlock()
+	for _, tuple := range tuples {
+		tuple.mu.Lock()
+		for _, version := range tuple.Versions {
+			value := t.shaped(version.Data)[column]
+			if value == nil {
+				continue
+			}
+			_, err := convert(value)
+			if err != nil {
+				tuple.mu.Unlock()
+				return fmt.Errorf("column %s of tuple %d can not be converted: %w", t.TableColumns[column].Name, tuple.id, err)
+			}
+		}
+		tuple.mu.Unlock()
+	}
+	return nil
+}
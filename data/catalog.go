@@ -0,0 +1,487 @@
+package data
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// The schemas and their tables, views and sequences are changed transactionally, like in postgres. CREATE and DROP
+// of tables, views, sequences and schemas are recorded by the transaction doing them, only its own statements see the change until it commits. Schemas of
+// the database holds the schemas and tables committed, the changes get into it at commit, a rollback forgets them and a rollback to a
+// savepoint the ones done after it. The schema public always exists.
+//
+// Table locks, held until the end of the transaction, keep concurrent transactions apart:
+//   - a transaction changing or locking tuples of a table holds the table shared.
+//   - a transaction creating, dropping, truncating or renaming a table, creating, replacing or dropping a view or
+//     creating or dropping a sequence holds its name exclusively and its schema shared.
+//   - a transaction creating or dropping a schema holds its name exclusively.
+//
+// A transaction waits for the others holding a conflicting lock, taking part in the deadlock detection.
+// Readers do not lock, they see the tables committed and the ones changed by their own transaction.
+
+type catalogChange struct {
+	cid     int32
+	schema  string
+	name    string // of the table or view, empty for the schema itself
+	table   Table  // the table or view created
+	dropped bool
+}
+
+type tableLock struct {
+	exclusive int64          // xid of the transaction holding the name exclusively, NO_TRANSACTION if none
+	shared    map[int64]bool // xids of the transactions holding the table shared
+}
+
+// the key of the table schema.name, the one of the schema if name is empty
+func tableKey(schema string, name string) string {
+	return schema + "." + name
+}
+
+// the running transaction holding a lock conflicting with the one wanted by xid, NO_TRANSACTION if there is none
+func (l *tableLock) conflicting(db *Database, xid int64, exclusive bool) int64 {
+	if l.exclusive != NO_TRANSACTION && l.exclusive != xid && db.isRunningTransaction(l.exclusive) {
+		return l.exclusive
+	}
+	if exclusive {
+		for holder := range l.shared {
+			if holder != xid && db.isRunningTransaction(holder) {
+				return holder
+			}
+		}
+	}
+	return NO_TRANSACTION
+}
+
+// locks the table schema.name for the transaction of conn until it ends
+func lockTable(conn *GoSqlConnData, schema string, name string, exclusive bool) error {
+	err := StartTransaction(conn)
+	if err != nil {
+		return err
+	}
+	db := conn.Database
+	tra := conn.Transaction
+	key := tableKey(schema, name)
+	var deadline time.Time
+	for {
+		db.tablesMu.Lock()
+		lock := db.tableLocks[key]
+		if lock == nil {
+			lock = &tableLock{NO_TRANSACTION, make(map[int64]bool)}
+			db.tableLocks[key] = lock
+		}
+		holder := lock.conflicting(db, tra.Xid, exclusive)
+		if holder == NO_TRANSACTION {
+			if exclusive {
+				lock.exclusive = tra.Xid
+			} else {
+				lock.shared[tra.Xid] = true
+			}
+			if !slices.Contains(tra.tableLocks, key) {
+				tra.tableLocks = append(tra.tableLocks, key)
+			}
+			db.tablesMu.Unlock()
+			return nil
+		}
+		db.tablesMu.Unlock()
+		if deadline.IsZero() && tra.MaxLockTimeInMs > 0 {
+			deadline = time.Now().Add(time.Duration(tra.MaxLockTimeInMs) * time.Millisecond)
+		}
+		err = waitForTransaction(tra, holder, deadline)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// LockTableName locks the name exclusively for the transaction of conn, to create, drop, truncate or rename the
+// table. Waits for the other transactions holding the table. Fails if the schema does not exist or is
+// information_schema.
+func LockTableName(conn *GoSqlConnData, schema string, name string) error {
+	if schema == INFORMATION_SCHEMA_NAME {
+		return fmt.Errorf("schema %s is read-only", schema)
+	}
+	err := lockTable(conn, schema, "", false)
+	if err != nil {
+		return err
+	}
+	if !SchemaExists(conn, schema) {
+		return fmt.Errorf("schema %s does not exist", schema)
+	}
+	return lockTable(conn, schema, name, true)
+}
+
+// LockSchemaName locks the name exclusively for the transaction of conn, to create or drop the schema. Waits for
+// the other transactions creating, dropping, truncating or renaming tables of it.
+func LockSchemaName(conn *GoSqlConnData, schema string) error {
+	return lockTable(conn, schema, "", true)
+}
+
+// Lock locks the table shared for the transaction of conn, so no other transaction drops or truncates it, until
+// that ended. Fails if the table got dropped, while waiting for the transaction doing that.
+func (t *GoSqlTable) Lock(conn *GoSqlConnData) error {
+	err := lockTable(conn, t.SchemaName, t.TableName, false)
+	if err != nil {
+		return err
+	}
+	db := conn.Database
+	db.tablesMu.Lock()
+	defer db.tablesMu.Unlock()
+	if t.dropped {
+		return fmt.Errorf("table %s does not exist anymore", t.TableName)
+	}
+	return nil
+}
+
+// the table as seen by the transaction of conn: the one committed, if the transaction did not change it
+func lookupTable(conn *GoSqlConnData, schema string, name string) Table {
+	if schema == INFORMATION_SCHEMA_NAME {
+		return informationSchemaTable(name)
+	}
+	db := conn.Database
+	db.tablesMu.Lock()
+	defer db.tablesMu.Unlock()
+	if tra := conn.Transaction; tra != nil && tra.isRunning() {
+		for ix := len(tra.catalog) - 1; ix >= 0; ix-- {
+			change := tra.catalog[ix]
+			if change.schema == schema && change.name == name {
+				if change.dropped {
+					return nil
+				}
+				return change.table
+			}
+		}
+	}
+	return db.Schemas[schema][name]
+}
+
+// SchemaExists is true, if the transaction of conn sees the schema
+func SchemaExists(conn *GoSqlConnData, schema string) bool {
+	if schema == PUBLIC_SCHEMA_NAME || schema == INFORMATION_SCHEMA_NAME {
+		return true
+	}
+	db := conn.Database
+	db.tablesMu.Lock()
+	defer db.tablesMu.Unlock()
+	if tra := conn.Transaction; tra != nil && tra.isRunning() {
+		for ix := len(tra.catalog) - 1; ix >= 0; ix-- {
+			if change := tra.catalog[ix]; change.schema == schema && change.name == "" {
+				return !change.dropped
+			}
+		}
+	}
+	_, ok := db.Schemas[schema]
+	return ok
+}
+
+// SchemaNames returns the names of the schemas seen by the transaction of conn, sorted
+func SchemaNames(conn *GoSqlConnData) []string {
+	db := conn.Database
+	db.tablesMu.Lock()
+	defer db.tablesMu.Unlock()
+	schemas := map[string]bool{PUBLIC_SCHEMA_NAME: true, INFORMATION_SCHEMA_NAME: true}
+	for schema := range db.Schemas {
+		if schema != tempTableSchemaName {
+			schemas[schema] = true
+		}
+	}
+	if tra := conn.Transaction; tra != nil && tra.isRunning() {
+		for _, change := range tra.catalog {
+			if change.name == "" {
+				schemas[change.schema] = !change.dropped
+			}
+		}
+	}
+	var res []string
+	for schema, exists := range schemas {
+		if exists {
+			res = append(res, schema)
+		}
+	}
+	slices.Sort(res)
+	return res
+}
+
+// the tables, views and sequences of the schema as seen by the transaction of conn, sorted by name
+func schemaRelations(conn *GoSqlConnData, schema string) []Table {
+	db := conn.Database
+	db.tablesMu.Lock()
+	defer db.tablesMu.Unlock()
+	tables := make(map[string]Table)
+	for name, table := range db.Schemas[schema] {
+		tables[name] = table
+	}
+	if tra := conn.Transaction; tra != nil && tra.isRunning() {
+		for _, change := range tra.catalog {
+			if change.schema != schema || change.name == "" {
+				continue
+			}
+			if change.dropped {
+				delete(tables, change.name)
+			} else {
+				tables[change.name] = change.table
+			}
+		}
+	}
+	res := make([]Table, 0, len(tables))
+	for _, t := range tables {
+		res = append(res, t)
+	}
+	slices.SortFunc(res, func(a, b Table) int { return strings.Compare(a.Name(), b.Name()) })
+	return res
+}
+
+// SchemaTables returns the tables of the schema as seen by the transaction of conn
+func SchemaTables(conn *GoSqlConnData, schema string) []*GoSqlTable {
+	var res []*GoSqlTable
+	for _, table := range schemaRelations(conn, schema) {
+		if t, ok := table.(*GoSqlTable); ok {
+			res = append(res, t)
+		}
+	}
+	return res
+}
+
+// SchemaViews returns the views of the schema as seen by the transaction of conn
+func SchemaViews(conn *GoSqlConnData, schema string) []*View {
+	var res []*View
+	for _, table := range schemaRelations(conn, schema) {
+		if v, ok := table.(*View); ok {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// VisibleFor is true, if the transaction of conn sees the table
+func (t *GoSqlTable) VisibleFor(conn *GoSqlConnData) bool {
+	return lookupTable(conn, t.SchemaName, t.TableName) == Table(t)
+}
+
+// CreatedBy is true, if the table got created by the running transaction of conn
+func (t *GoSqlTable) CreatedBy(conn *GoSqlConnData) bool {
+	tra := conn.Transaction
+	return tra != nil && tra.isRunning() && t.createdBy == tra.Xid
+}
+
+// CheckAlterable returns an error, if statement can not change the table inside the transaction of conn.
+// ALTER TABLE and CREATE or DROP INDEX change the table for all transactions at once, a rollback could not undo
+// them, so inside a transaction they are only allowed on a table created by it: its rollback drops the table.
+func (t *GoSqlTable) CheckAlterable(conn *GoSqlConnData, statement string) error {
+	if conn.DoAutoCommit || t.CreatedBy(conn) {
+		return nil
+	}
+	return fmt.Errorf("%s on table %s is not supported inside a transaction, it could not be rolled back", statement, t.TableName)
+}
+
+// the tables created by running transactions, not yet committed
+func (db *Database) uncommittedTables() []*GoSqlTable {
+	db.tablesMu.Lock()
+	defer db.tablesMu.Unlock()
+	var res []*GoSqlTable
+	for _, tra := range db.catalogTransactions {
+		for _, change := range tra.catalog {
+			if t, ok := change.table.(*GoSqlTable); ok && !slices.Contains(res, t) {
+				res = append(res, t)
+			}
+		}
+	}
+	return res
+}
+
+func (tra *Transaction) changeCatalog(change catalogChange) {
+	db := tra.Conn.Database
+	db.tablesMu.Lock()
+	defer db.tablesMu.Unlock()
+	tra.catalog = append(tra.catalog, change)
+	db.catalogTransactions[tra.Xid] = tra
+}
+
+// CreateTable adds the table to its schema, the transaction of conn sees it from now on, the others after it
+// committed. The caller holds the name of the table exclusively.
+func CreateTable(conn *GoSqlConnData, t *GoSqlTable) {
+	tra := conn.Transaction
+	t.db = conn.Database
+	t.createdBy = tra.Xid
+	tra.changeCatalog(catalogChange{tra.Cid, t.SchemaName, t.TableName, t, false})
+}
+
+// DropTable removes the table and the sequences owned by its columns from its schema, the other transactions see
+// it until the one of conn committed. The caller holds the name of the table exclusively. A table referenced by
+// foreign keys of other tables can not be dropped.
+func DropTable(conn *GoSqlConnData, t *GoSqlTable) error {
+	err := checkNotReferenced(conn, []*GoSqlTable{t}, "drop table "+t.TableName)
+	if err != nil {
+		return err
+	}
+	sequences := t.ownedSequences()
+	for _, s := range sequences {
+		err = LockTableName(conn, s.SchemaName, s.TableName)
+		if err != nil {
+			return err
+		}
+	}
+	tra := conn.Transaction
+	tra.changeCatalog(catalogChange{tra.Cid, t.SchemaName, t.TableName, nil, true})
+	for _, s := range sequences {
+		tra.changeCatalog(catalogChange{tra.Cid, s.SchemaName, s.TableName, nil, true})
+	}
+	return nil
+}
+
+// fails, if a foreign key of a table seen by the transaction of conn, other than the tables, references one of them
+func checkNotReferenced(conn *GoSqlConnData, tables []*GoSqlTable, action string) error {
+	for _, t := range tables {
+		for _, fk := range t.ReferencingKeys() {
+			if !slices.Contains(tables, fk.Child) && fk.Child.VisibleFor(conn) {
+				return fmt.Errorf("cannot %s, foreign key %s of table %s references table %s", action, fk.Name,
+					fk.Child.TableName, t.TableName)
+			}
+		}
+	}
+	return nil
+}
+
+// CreateSchema adds the schema, the transaction of conn sees it from now on, the others after it committed.
+// The caller holds the name of the schema exclusively.
+func CreateSchema(conn *GoSqlConnData, schema string) {
+	tra := conn.Transaction
+	tra.changeCatalog(catalogChange{tra.Cid, schema, "", nil, false})
+}
+
+// DropSchema removes the schema, the other transactions see it until the one of conn committed. The caller holds
+// the name of the schema exclusively. With cascade the tables and views of the schema are dropped too, otherwise it
+// must not contain any. The schemas public and information_schema can not be dropped.
+func DropSchema(conn *GoSqlConnData, schema string, cascade bool) error {
+	if schema == PUBLIC_SCHEMA_NAME || schema == INFORMATION_SCHEMA_NAME {
+		return fmt.Errorf("cannot drop schema %s", schema)
+	}
+	relations := schemaRelations(conn, schema)
+	if len(relations) > 0 && !cascade {
+		return fmt.Errorf("cannot drop schema %s, it contains tables", schema)
+	}
+	for _, t := range relations {
+		err := LockTableName(conn, schema, t.Name())
+		if err != nil {
+			return err
+		}
+	}
+	err := checkNotReferenced(conn, SchemaTables(conn, schema), "drop schema "+schema)
+	if err != nil {
+		return err
+	}
+	tra := conn.Transaction
+	for _, t := range relations {
+		tra.changeCatalog(catalogChange{tra.Cid, schema, t.Name(), nil, true})
+	}
+	tra.changeCatalog(catalogChange{tra.Cid, schema, "", nil, true})
+	return nil
+}
+
+// Truncate deletes all tuples of the table, it gets locked exclusively for the transaction, so no other one
+// changes it meanwhile. Unlike DELETE it also deletes the tuples committed after the snapshot of a REPEATABLE READ
+// transaction. A table referenced by foreign keys of other tables can not be truncated.
+func (t *GoSqlTable) Truncate(baseData *StatementBaseData) (int64, error) {
+	conn := baseData.Conn
+	err := LockTableName(conn, t.SchemaName, t.TableName)
+	if err != nil {
+		return 0, err
+	}
+	err = checkNotReferenced(conn, []*GoSqlTable{t}, "truncate table "+t.TableName)
+	if err != nil {
+		return 0, err
+	}
+	it := t.NewIterator(baseData, true).(*GoSqlTableIterator)
+	defer it.Close()
+	if it.err == nil {
+		it.setSnapShot(conn.Database.GetSnapShot(conn.Transaction))
+	}
+	count := int64(0)
+	for {
+		tuple, ok, err := it.Next(func(Tuple) (bool, error) { return true, nil })
+		if err != nil || !ok {
+			return count, err
+		}
+		t.Delete(tuple.Id(), conn)
+		count++
+	}
+}
+
+// RenameTable renames the table in its schema. The caller holds the old and the new name exclusively.
+// A table created by the running transaction keeps being seen only by it, otherwise the change is not transactional
+// and only done in autocommit mode, see CheckAlterable.
+func RenameTable(conn *GoSqlConnData, t *GoSqlTable, name string) error {
+	if lookupTable(conn, t.SchemaName, name) != nil {
+		return fmt.Errorf("table %s already exists", name)
+	}
+	db := conn.Database
+	db.tablesMu.Lock()
+	defer db.tablesMu.Unlock()
+	if t.CreatedBy(conn) {
+		catalog := conn.Transaction.catalog
+		for ix := range catalog {
+			if catalog[ix].table == Table(t) {
+				catalog[ix].name = name
+			}
+		}
+		t.TableName = name
+		return nil
+	}
+	tables := db.Schemas[t.SchemaName]
+	delete(tables, t.TableName)
+	t.TableName = name
+	tables[name] = t
+	return nil
+}
+
+// brings the changes of the catalog into the Schemas of the database, if the transaction committed, and releases
+// its table locks. Called before the end of the transaction gets visible.
+func (tra *Transaction) endCatalog(committed bool) {
+	db := tra.Conn.Database
+	db.tablesMu.Lock()
+	defer db.tablesMu.Unlock()
+	if committed {
+		for _, change := range tra.catalog {
+			switch {
+			case change.name == "" && change.dropped:
+				delete(db.Schemas, change.schema)
+			case change.dropped:
+				if dropped, ok := db.Schemas[change.schema][change.name].(*GoSqlTable); ok {
+					dropped.dropped = true
+				}
+				delete(db.Schemas[change.schema], change.name)
+			default:
+				if db.Schemas[change.schema] == nil {
+					db.Schemas[change.schema] = make(map[string]Table)
+				}
+				if change.name != "" {
+					db.Schemas[change.schema][change.name] = change.table
+				}
+			}
+		}
+	}
+	tra.catalog = nil
+	delete(db.catalogTransactions, tra.Xid)
+	for _, key := range tra.tableLocks {
+		lock := db.tableLocks[key]
+		if lock.exclusive == tra.Xid {
+			lock.exclusive = NO_TRANSACTION
+		}
+		delete(lock.shared, tra.Xid)
+		if lock.exclusive == NO_TRANSACTION && len(lock.shared) == 0 {
+			delete(db.tableLocks, key)
+		}
+	}
+	tra.tableLocks = nil
+}
+
+// forgets the changes of the catalog done after the savepoint, the table locks are kept
+func (tra *Transaction) rollbackCatalog(cid int32) {
+	db := tra.Conn.Database
+	db.tablesMu.Lock()
+	defer db.tablesMu.Unlock()
+	for len(tra.catalog) > 0 && tra.catalog[len(tra.catalog)-1].cid >= cid {
+		tra.catalog = tra.catalog[:len(tra.catalog)-1]
+	}
+}
@@ -0,0 +1,251 @@
+package data
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// A checkpoint creates the next generation of the write-ahead log:
+//   - snapshot-<gen>.dat contains all ddl-statements committed, the values of the sequences and, for every table,
+//     NextTupleId and the latest committed versions of the tuples, as seen by a snapshot taken at position P of the log.
+//   - wal-<gen>.log contains the records written before P by transactions still running at P, followed by
+//     everything written after P.
+//
+// After CURRENT names the new generation, the files of the old one are removed. Recovery replays the
+// snapshot and afterwards the log, just like the log of a generation without snapshot.
+
+// Checkpoint writes a snapshot of all tables of the database and truncates its write-ahead log.
+// Does nothing if no write-ahead log is used. If the last background checkpoint failed,
+// its error is returned instead.
+func (db *Database) Checkpoint() error {
+	w := db.wal.Load()
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	err := w.checkpointErr
+	w.checkpointErr = nil
+	w.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("background checkpoint failed: %w", err)
+	}
+	return w.checkpoint()
+}
+
+func (w *writeAheadLog) checkpoint() error {
+	w.checkpointMu.Lock()
+	defer w.checkpointMu.Unlock()
+	if w.db.wal.Load() != w {
+		return nil // closed meanwhile
+	}
+
+	w.mu.Lock()
+	err := w.flush(false)
+	if err != nil {
+		w.mu.Unlock()
+		return err
+	}
+	cut := w.size
+	snapShot := w.db.GetSnapShot(nil)
+	// the statements and tables of transactions committing meanwhile are part of the log of the next generation
+	statements := slices.DeleteFunc(slices.Clone(w.statements), func(rec *walRecord) bool {
+		return slices.Contains(snapShot.runningXids, rec.xid)
+	})
+	tables := slices.DeleteFunc(w.db.collectGoSqlTables(), func(t *GoSqlTable) bool {
+		return slices.Contains(snapShot.runningXids, t.createdBy)
+	})
+	// the values at P, the ones changed later are logged after P
+	var sequences []*walRecord
+	for _, s := range w.db.collectSequences() {
+		if !slices.Contains(snapShot.runningXids, s.createdBy) {
+			sequences = append(sequences, s.walRecord(NO_TRANSACTION, s.value.Load()))
+		}
+	}
+	w.mu.Unlock()
+
+	next := w.generation + 1
+	snapshotPath := filepath.Join(w.dir, snapshotFileName(next))
+	logPath := filepath.Join(w.dir, walFileName(next))
+	err = writeSnapshot(snapshotPath, statements, sequences, tables, snapShot)
+	if err != nil {
+		os.Remove(snapshotPath)
+		return err
+	}
+	file, err := os.OpenFile(logPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		os.Remove(snapshotPath)
+		return err
+	}
+	err = w.switchToLog(file, cut, snapShot.runningXids)
+	if err != nil {
+		file.Close()
+		os.Remove(logPath)
+		os.Remove(snapshotPath)
+		return err
+	}
+	// the old generation is not needed anymore
+	os.Remove(filepath.Join(w.dir, walFileName(next-1)))
+	os.Remove(filepath.Join(w.dir, snapshotFileName(next-1)))
+	return nil
+}
+
+// all tables which are changed by transactions, temporary tables are not part of a snapshot
+func (db *Database) collectGoSqlTables() []*GoSqlTable {
+	db.tablesMu.Lock()
+	defer db.tablesMu.Unlock()
+	var res []*GoSqlTable
+	for _, schema := range db.Schemas {
+		for _, table := range schema {
+			if t, ok := table.(*GoSqlTable); ok {
+				res = append(res, t)
+			}
+		}
+	}
+	return res
+}
+
+func (db *Database) collectSequences() []*Sequence {
+	db.tablesMu.Lock()
+	defer db.tablesMu.Unlock()
+	var res []*Sequence
+	for _, schema := range db.Schemas {
+		for _, table := range schema {
+			if s, ok := table.(*Sequence); ok {
+				res = append(res, s)
+			}
+		}
+	}
+	return res
+}
+
+func writeSnapshot(path string, statements []*walRecord, sequences []*walRecord, tables []*GoSqlTable, snapShot *SnapShot) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writer := bufio.NewWriter(file)
+	write := func(rec *walRecord) {
+		var payload []byte
+		if err == nil {
+			payload, err = encodeWalRecord(rec)
+		}
+		if err == nil {
+			_, err = writeWalFrame(writer, payload)
+		}
+	}
+	for _, rec := range statements {
+		write(&walRecord{kind: walStatement, schema: rec.schema, sql: rec.sql})
+	}
+	for _, rec := range sequences {
+		write(rec)
+	}
+	for _, t := range tables {
+		write(&walRecord{kind: walTableState, schema: t.SchemaName, table: t.TableName, counter: t.NextTupleId.Load()})
+		// registered, so vacuum keeps the versions visible for the snapshot
+		it := &GoSqlTableIterator{nil, snapShot, t, 0, false, LOCK_FOR_UPDATE, LOCK_WAIT, nil, t.db}
+		t.mu.Lock()
+		t.iterators = append(t.iterators, it)
+		t.mu.Unlock()
+		for err == nil {
+			tuple, found, iterErr := it.Next(func(Tuple) (bool, error) { return true, nil })
+			if iterErr != nil {
+				it.Close()
+				return iterErr
+			}
+			if !found {
+				break
+			}
+			write(&walRecord{kind: walInsert, schema: t.SchemaName, table: t.TableName, recid: tuple.Id(), values: tuple.(*SliceTuple).data})
+		}
+		// a failed write ends the loop before the last tuple
+		it.Close()
+	}
+	write(&walRecord{kind: walCommit, xid: NO_TRANSACTION})
+	if err != nil {
+		return err
+	}
+	if err = writer.Flush(); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// fills the log of the next generation and appends to it from now on
+func (w *writeAheadLog) switchToLog(file *os.File, cut int64, runningXids []int64) error {
+	writer := bufio.NewWriter(file)
+	size := int64(0)
+	old := io.NewSectionReader(w.file, 0, cut)
+	for {
+		payload, ok := readWalFrame(old)
+		if !ok {
+			break
+		}
+		rec, err := decodeWalRecord(payload)
+		if err != nil {
+			return err
+		}
+		if rec.xid != NO_TRANSACTION && slices.Contains(runningXids, rec.xid) {
+			n, err := writeWalFrame(writer, payload)
+			if err != nil {
+				return err
+			}
+			size += int64(n)
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	err := w.flush(false)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(writer, io.NewSectionReader(w.file, cut, w.size-cut))
+	if err != nil {
+		return err
+	}
+	size += n
+	if err = writer.Flush(); err != nil {
+		return err
+	}
+	if err = file.Sync(); err != nil {
+		return err
+	}
+	if err = writeCurrentGeneration(w.dir, w.generation+1); err != nil {
+		return err
+	}
+	w.file.Close()
+	w.file = file
+	w.writer = writer
+	w.size = size
+	w.generation++
+	return nil
+}
+
+func writeCurrentGeneration(dir string, generation int64) error {
+	tmpPath := filepath.Join(dir, walCurrentFileName+".tmp")
+	err := os.WriteFile(tmpPath, []byte(fmt.Sprintf("%d\n", generation)), 0o644)
+	if err == nil {
+		err = syncFile(tmpPath)
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, filepath.Join(dir, walCurrentFileName))
+	}
+	if err == nil {
+		err = syncFile(dir)
+	}
+	return err
+}
+
+func syncFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
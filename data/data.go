@@ -15,16 +15,17 @@ import (
 
 const (
 	PRIMARY_AUTOINCREMENT = 1
+	PRIMARY_KEY_COLUMN    = 2
+	UNIQUE_COLUMN         = 3
 	DEFAULT_MAX_LENGTH    = 40
 	DEFAULT_SCHEMA_NAME   = "%%DEFAULTSCHEMA%%"
+	PUBLIC_SCHEMA_NAME    = "public"
 )
 
 var (
 	VersionedRecordId = "VersionedRecordIdentifier"
 )
 
-var tablesMu sync.Mutex
-
 var NULL_TUPLE = &SliceTuple{-1, nil}
 
 type GoSqlColumn struct {
@@ -34,11 +35,20 @@ type GoSqlColumn struct {
 	Length     int
 	Spec2      int
 	Hidden     bool
+	NotNull    bool
+	Default    any // the term of DEFAULT, compiled by the statements using it, nil if there is none
+	Check      any // the boolean term of CHECK, nil if there is none
 }
 
+var ErrNotNullViolation = errors.New("null value violates not-null constraint")
+
+var ErrCheckViolation = errors.New("new row violates check constraint")
+
 type TableIterator interface {
 	GetTable() Table
 	Next(func(tuple Tuple) (bool, error)) (Tuple, bool, error)
+	// Close releases the snapshot of the iterator, Next does it, when it found no more tuples
+	Close()
 }
 
 type Table interface {
@@ -89,8 +99,9 @@ func (t *SliceTuple) SafeData(tableIx int, ix int) driver.Value {
 	return t.data[ix]
 }
 
+// the clone gets its own values, so setting them does not change the version the tuple was read from
 func (t *SliceTuple) Clone() Tuple {
-	return &SliceTuple{t.Id(), t.data}
+	return &SliceTuple{t.Id(), slices.Clone(t.data)}
 }
 
 func (t *SliceTuple) SetData(tableIx int, ix int, val driver.Value) error {
@@ -109,7 +120,8 @@ func NewSliceTuple(i int64, data []driver.Value) Tuple {
 }
 
 const (
-	FOR_UPDATE_FLAG = 1
+	FOR_UPDATE_FLAG    = 1
+	KEY_UNCHANGED_FLAG = 2
 )
 
 type TupleVersion struct {
@@ -124,14 +136,19 @@ type VersionedTuple struct {
 	id       int64
 	mu       sync.Mutex
 	Versions []TupleVersion
+	locks    []tupleLock // share locks
 }
 
 type GoSqlTableIterator struct {
-	Transaction *Transaction
-	SnapShot    *SnapShot
-	table       *GoSqlTable
-	nextKey     int64
-	forUpdate   bool
+	Transaction  *Transaction
+	SnapShot     *SnapShot
+	table        *GoSqlTable
+	nextKey      int64
+	forUpdate    bool
+	lockStrength LockStrength
+	lockWait     LockWaitPolicy
+	err          error // of locking the table for a change, returned by Next
+	db           *Database
 }
 
 type TempTableIterator struct {
@@ -141,9 +158,9 @@ type TempTableIterator struct {
 
 func (it *TempTableIterator) Next(check func(Tuple) (bool, error)) (Tuple, bool, error) {
 	for {
-		// ignore snapshot, just check if xids match
+		// ignore snapshot, just check if xids match. The position is the id, so the tuples can be joined.
 		if len(it.table.Tempdata) > it.ix {
-			candidate := NewSliceTuple(-1, it.table.Tempdata[it.ix])
+			candidate := NewSliceTuple(int64(it.ix+1), it.table.Tempdata[it.ix])
 			it.ix++
 			found, err := check(candidate)
 			if err != nil {
@@ -166,11 +183,23 @@ type BaseTable struct {
 
 type GoSqlTable struct {
 	BaseTable
-	ids         map[string]int64
+	sequences   map[string]*Sequence // owned by the autoincrement columns
 	NextTupleId atomic.Int64
 	data        *redblacktree.Tree
 	iterators   []TableIterator
 	mu          sync.RWMutex
+	// updates and deletes since the last vacuum
+	changesSinceVacuum atomic.Int64
+	vacuuming          atomic.Bool
+	indexes            []*GoSqlIndex
+	indexMu            sync.RWMutex // held exclusively while an index gets created or dropped or a foreign key added
+	uniqueMu           sync.Mutex   // serializes the checks of the unique indexes and the changes checked
+	foreignKeys        []*GoSqlForeignKey
+	columnChanges      atomic.Pointer[[]columnChange] // by ALTER TABLE, see shape
+	createdBy          int64                          // xid of the transaction creating the table
+	dropped            bool                           // by a committed DROP TABLE, protected by tablesMu of db
+	db                 *Database                      // the table belongs to, set by CreateTable
+	Definition         string                         // the CREATE MATERIALIZED VIEW statement, empty for tables
 }
 
 func (t *BaseTable) Name() string {
@@ -198,24 +227,17 @@ func (t *GoSqlTable) Data() *[][]driver.Value {
 	return nil
 }
 
-type TempTableData struct {
-	nextTableId atomic.Int64
-	mu          sync.RWMutex
-}
-
-var tempTableData = TempTableData{}
-
 const tempTableSchemaName = "%TEMPTABLES%"
 
-func NewTempTable(columns []GoSqlColumn) Table {
-	nextId := tempTableData.nextTableId.Add(1)
+func (db *Database) NewTempTable(columns []GoSqlColumn) Table {
+	nextId := db.nextTempTableId.Add(1)
 	res := &TempTable{BaseTable{tempTableSchemaName, fmt.Sprintf("Temp%d", nextId), columns}, [][]driver.Value{}}
-	tablesMu.Lock()
-	defer tablesMu.Unlock()
-	tempTables := Schemas[tempTableSchemaName]
+	db.tablesMu.Lock()
+	defer db.tablesMu.Unlock()
+	tempTables := db.Schemas[tempTableSchemaName]
 	if tempTables == nil {
 		tempTables = make(map[string]Table)
-		Schemas[tempTableSchemaName] = tempTables
+		db.Schemas[tempTableSchemaName] = tempTables
 	}
 	tempTables[res.TableName] = res
 	return res
@@ -230,8 +252,9 @@ func NewTable(name GoSqlIdentifier, columns []GoSqlColumn) *GoSqlTable {
 		schemaName = DEFAULT_SCHEMA_NAME
 		tableName = name.Parts[0]
 	}
-	res := &GoSqlTable{BaseTable{schemaName, tableName, columns}, make(map[string]int64),
-		atomic.Int64{}, redblacktree.NewWith(utils.Int64Comparator), []TableIterator{}, sync.RWMutex{}}
+	res := &GoSqlTable{BaseTable{schemaName, tableName, columns}, make(map[string]*Sequence),
+		atomic.Int64{}, redblacktree.NewWith(utils.Int64Comparator), []TableIterator{}, sync.RWMutex{},
+		atomic.Int64{}, atomic.Bool{}, nil, sync.RWMutex{}, sync.Mutex{}, nil, atomic.Pointer[[]columnChange]{}, NO_TRANSACTION, false, nil, ""}
 	res.NextTupleId.Store(1)
 	return res
 }
@@ -247,22 +270,36 @@ func (tIt *TempTableIterator) GetTable() Table {
 	return tIt.table
 }
 
+func (tIt *TempTableIterator) Close() {}
+
 func (t *GoSqlTable) NewIterator(baseData *StatementBaseData, forChange bool) TableIterator {
 	if forChange {
 		if baseData.Conn.Transaction == nil || !baseData.Conn.Transaction.IsStarted() {
-			InitTransaction(baseData.Conn)
-			startTransactionInternal(baseData.Conn.Transaction)
+			StartTransaction(baseData.Conn)
+		}
+		err := t.Lock(baseData.Conn)
+		if err != nil {
+			return &GoSqlTableIterator{baseData.Conn.Transaction, nil, t, 0, forChange, baseData.LockStrength, baseData.LockWait, err, baseData.Conn.Database}
 		}
 	}
 	var s *SnapShot
 	tra := baseData.Conn.Transaction
 	if tra == nil || tra.IsolationLevel == COMMITTED_READ {
-		s = GetSnapShot(baseData.Conn.Transaction)
+		s = baseData.Conn.Database.GetSnapShot(baseData.Conn.Transaction)
 		baseData.SnapShot = s // not yet clear, if the snapshot is necessary outside of Iterator
 	} else {
-		s = tra.SnapShot
+		if !tra.IsStarted() {
+			// the snapshot of the transaction is taken by its first statement
+			StartTransaction(baseData.Conn)
+			tra = baseData.Conn.Transaction
+		}
+		// the transaction sees its own changes done by previous statements
+		traSnapShot := *tra.SnapShot
+		traSnapShot.Cid = tra.Cid
+		s = &traSnapShot
+		ssiRead(tra, t)
 	}
-	res := GoSqlTableIterator{baseData.Conn.Transaction, s, t, 0, forChange}
+	res := GoSqlTableIterator{baseData.Conn.Transaction, s, t, 0, forChange, baseData.LockStrength, baseData.LockWait, nil, baseData.Conn.Database}
 	t.mu.Lock()
 	defer t.mu.Unlock()
 	t.iterators = append(t.iterators, &res)
@@ -284,7 +321,7 @@ func (ti *GoSqlTableIterator) isVisible(xid int64) bool {
 func (ti *GoSqlTableIterator) isRolledback(xid int64) bool {
 	bySnapshot := slices.Contains(ti.SnapShot.rolledbackXids, xid)
 	if !bySnapshot && (xid < ti.SnapShot.xmin || ti.SnapShot.xmin == 0) {
-		tra, err := GetTransaction(xid)
+		tra, err := ti.db.GetTransaction(xid)
 		if err != nil {
 			fmt.Printf("during GetTransaction: %v", err)
 			return false
@@ -356,10 +393,6 @@ func encountered(err error) (bool, bool, *TupleVersion, int64, error) {
 	return true, false, nil, -1, err
 }
 
-func unvisibleOrError(forUpdate bool) (bool, bool, *TupleVersion, int64, error) {
-	return true, false, nil, -1, errorIfUpdate(forUpdate)
-}
-
 func visibleOrError(forUpdate bool, version *TupleVersion) (bool, bool, *TupleVersion, int64, error) {
 	return true, true, version, -1, errorIfUpdate(forUpdate)
 }
@@ -380,6 +413,10 @@ func (ti *GoSqlTableIterator) isVisibleTupleNoTraEffectVariousSingleVersionCases
 			if ti.isRolledback(actVersion.xmax) {
 				return foundVersion(actVersion)
 			} else if ti.isRunning(actVersion.xmax) || actVersion.xmax >= s.xmax {
+				if actVersion.flags&FOR_UPDATE_FLAG != 0 && ti.db.lockReleased(actVersion.xmax) {
+					// the lock of a transaction ended after the snapshot was taken
+					return foundVersion(actVersion)
+				}
 				return true, true, actVersion, actVersion.xmax, waitIfUpdate(forUpdate)
 			} else if ti.isCommitted(actVersion.xmax) {
 				return visibleOrError(forUpdate, actVersion)
@@ -388,18 +425,20 @@ func (ti *GoSqlTableIterator) isVisibleTupleNoTraEffectVariousSingleVersionCases
 			}
 		} else {
 			if actVersion.flags&FOR_UPDATE_FLAG == 0 {
+				// deleted before the snapshot was taken, nothing to conflict with
 				if actVersion.xmax == actVersion.xmin {
-					return unvisibleOrError(forUpdate)
+					return notFoundVersion()
 				}
 				if ti.isVisible(actVersion.xmax) {
-					return unvisibleOrError(forUpdate)
+					return notFoundVersion()
 				}
 			} else {
+				// the lock of an ended transaction does not conflict
 				if actVersion.xmax == actVersion.xmin {
-					return visibleOrError(forUpdate, actVersion)
+					return foundVersion(actVersion)
 				}
 				if ti.isVisible(actVersion.xmax) {
-					return visibleOrError(forUpdate, actVersion)
+					return foundVersion(actVersion)
 				}
 			}
 			return encountered(errors.New("should never reach this"))
@@ -434,6 +473,10 @@ func (ti *GoSqlTableIterator) isVisibleTupleNoTraEffectCurrentNotVisibleCases(tu
 		for i := actVersionOffset - 1; i >= 0; i-- {
 			followingVersion := tuple.Versions[i]
 			if ti.isVisible(followingVersion.xmin) {
+				if offset == 0 && (ti.isRunning(actVersion.xmin) || actVersion.xmin >= ti.SnapShot.xmax) {
+					// the visible version got updated by a transaction, which did not end before the snapshot
+					return true, true, &followingVersion, actVersion.xmin, waitIfUpdate(forUpdate)
+				}
 				return foundVersion(&followingVersion)
 			}
 		}
@@ -608,6 +651,7 @@ func (ti *GoSqlTableIterator) handleCandidate(check func(Tuple) (bool, error), f
 	tuple.mu.Lock()
 	defer tuple.mu.Unlock()
 
+	var deadline time.Time
 	for {
 		waitForTraIfVisibleAndSelected := false
 		visible, version, contendingTra, err := ti.isVisibleTuple(tuple, forUpdate)
@@ -621,52 +665,63 @@ func (ti *GoSqlTableIterator) handleCandidate(check func(Tuple) (bool, error), f
 		if !visible {
 			return NULL_TUPLE, false, nil
 		}
-		selected, err := check(NewSliceTuple(tuple.id, version.Data))
+		values := ti.table.shaped(version.Data)
+		selected, err := check(NewSliceTuple(tuple.id, values))
 		if err != nil {
 			return NULL_TUPLE, false, err
 		}
-		if selected {
-			if forUpdate {
-				// todo: synchronize on tuple
-				if waitForTraIfVisibleAndSelected {
-					if contendingTra < 0 {
-						return NULL_TUPLE, false, errors.New("expected contending tra to wait for")
-					} else {
-						tuple.mu.Unlock()
-						endT := time.Now().UnixMilli() + ti.Transaction.MaxLockTimeInMs
-						for {
-							tra, err := GetTransaction(contendingTra)
-							if err != nil {
-								tuple.mu.Lock()
-								return NULL_TUPLE, false, err
-							}
-							if time.Now().UnixMilli() > endT {
-								tuple.mu.Lock()
-								return NULL_TUPLE, false, ErrTraLockTimeout
-							}
-							if !tra.IsStarted() {
-								break
-							}
-						}
-						tuple.mu.Lock()
-					}
-
-				} else {
-					version := &tuple.Versions[len(tuple.Versions)-1]
-					version.xmax = ti.Transaction.Xid
-					version.flags |= FOR_UPDATE_FLAG
-					return NewSliceTuple(tuple.id, version.Data), true, nil
+		if !selected {
+			return NULL_TUPLE, false, nil
+		}
+		if !forUpdate {
+			return NewSliceTuple(tuple.id, values), true, nil
+		}
+		if waitForTraIfVisibleAndSelected {
+			if contendingTra < 0 {
+				return NULL_TUPLE, false, errors.New("expected contending tra to wait for")
+			}
+			holder, err := ti.db.GetTransaction(contendingTra)
+			if err != nil {
+				return NULL_TUPLE, false, err
+			}
+			if !holder.isRunning() {
+				err = ti.waitedFor(tuple, contendingTra)
+				if err != nil {
+					return NULL_TUPLE, false, err
 				}
-			} else {
-				return NewSliceTuple(tuple.id, version.Data), true, nil
+				continue
+			} else if !ti.lockStrength.conflictsWith(tuple.xmaxLockStrength(contendingTra)) {
+				waitForTraIfVisibleAndSelected = false
 			}
-		} else {
+		}
+		if !waitForTraIfVisibleAndSelected {
+			contendingTra = tuple.conflictingShareLock(ti.db, ti.Transaction.Xid, ti.lockStrength)
+			if contendingTra == NO_TRANSACTION {
+				return ti.lock(tuple, version), true, nil
+			}
+		}
+		if ti.lockWait == LOCK_SKIP_LOCKED {
 			return NULL_TUPLE, false, nil
 		}
+		if ti.lockWait == LOCK_NOWAIT {
+			return NULL_TUPLE, false, ErrLockNotAvailable
+		}
+		if deadline.IsZero() && ti.Transaction.MaxLockTimeInMs > 0 {
+			deadline = time.Now().Add(time.Duration(ti.Transaction.MaxLockTimeInMs) * time.Millisecond)
+		}
+		tuple.mu.Unlock()
+		err = waitForTransaction(ti.Transaction, contendingTra, deadline)
+		tuple.mu.Lock()
+		if err != nil {
+			return NULL_TUPLE, false, err
+		}
 	}
 }
 
 func (ti *GoSqlTableIterator) Next(check func(Tuple) (bool, error)) (Tuple, bool, error) {
+	if ti.err != nil {
+		return NULL_TUPLE, false, ti.err
+	}
 	forUpdate := ti.forUpdate
 	// select versions against snapShot
 	for {
@@ -686,12 +741,17 @@ func (ti *GoSqlTableIterator) Next(check func(Tuple) (bool, error)) (Tuple, bool
 			return tuple, done, err
 		}
 	}
+	ti.Close()
+	return NULL_TUPLE, false, nil
+}
+
+// the iterator does not need its snapshot anymore, vacuum can remove the versions only it sees
+func (ti *GoSqlTableIterator) Close() {
 	ti.table.mu.Lock()
 	defer ti.table.mu.Unlock()
 	ti.table.iterators = slices.DeleteFunc(ti.table.iterators, func(i TableIterator) bool {
 		return i.(*GoSqlTableIterator) == ti
 	})
-	return NULL_TUPLE, false, nil
 }
 
 func (t BaseTable) FindColumn(name string) (int, error) {
@@ -703,33 +763,36 @@ func (t BaseTable) FindColumn(name string) (int, error) {
 	return -1, fmt.Errorf("did not find column with name %s", name)
 }
 
-func (t *GoSqlTable) Increment(columnName string) int64 {
-	t.mu.Lock()
-	defer t.mu.Unlock()
-	id, ok := t.ids[columnName]
-	if !ok {
-		t.ids[columnName] = 1
-		return 1
-	} else {
-		t.ids[columnName] = id + 1
-		return id + 1
-	}
+func (t *GoSqlTable) Insert(recordValues []driver.Value, conn *GoSqlConnData) int64 {
+	return t.insertWithId(t.NextTupleId.Add(1)-1, recordValues, conn)
 }
 
-func (t *GoSqlTable) Insert(recordValues []driver.Value, conn *GoSqlConnData) int64 {
+// used directly during recovery, where the record id is already known
+func (t *GoSqlTable) insertWithId(id int64, recordValues []driver.Value, conn *GoSqlConnData) int64 {
 	StartTransaction(conn)
+	ssiWrite(conn.Transaction, t)
 	recordVersion := TupleVersion{recordValues, conn.Transaction.Xid, 0, 0, conn.Transaction.Cid}
-	id := t.NextTupleId.Load()
-	tuple := &VersionedTuple{id, sync.Mutex{}, []TupleVersion{recordVersion}}
-	t.NextTupleId.Add(1)
+	tuple := &VersionedTuple{id, sync.Mutex{}, []TupleVersion{recordVersion}, nil}
+	conn.Transaction.recordUndo(tuple, true, false)
+	t.indexMu.RLock()
+	defer t.indexMu.RUnlock()
+	for {
+		next := t.NextTupleId.Load()
+		if next > id || t.NextTupleId.CompareAndSwap(next, id+1) {
+			break
+		}
+	}
 	t.mu.Lock()
 	defer t.mu.Unlock()
 	t.data.Put(id, tuple)
+	t.addIndexEntries(id, recordValues)
+	logTableChange(walInsert, t, id, recordValues, conn)
 	return id
 }
 
 func (t *GoSqlTable) Delete(recordId int64, conn *GoSqlConnData) bool {
 	StartTransaction(conn)
+	ssiWrite(conn.Transaction, t)
 	t.mu.RLock()
 	defer t.mu.RUnlock()
 	value, ok := t.data.Get(recordId)
@@ -737,18 +800,24 @@ func (t *GoSqlTable) Delete(recordId int64, conn *GoSqlConnData) bool {
 		tuplep := value.(*VersionedTuple)
 		tuplep.mu.Lock()
 		defer tuplep.mu.Unlock()
+		conn.Transaction.recordUndo(tuplep, false, true)
 		version := &tuplep.Versions[len(tuplep.Versions)-1]
 		if version.xmax != 0 {
-			version.flags &= ^FOR_UPDATE_FLAG
+			version.flags &= ^(FOR_UPDATE_FLAG | KEY_UNCHANGED_FLAG)
 		}
 		version.xmax = conn.Transaction.Xid
 		version.cid = conn.Transaction.Cid
+		logTableChange(walDelete, t, recordId, nil, conn)
+		t.countChange()
 	}
 	return ok
 }
 
 func (t *GoSqlTable) Update(recordId int64, recordValues Tuple, conn *GoSqlConnData) bool {
 	StartTransaction(conn)
+	ssiWrite(conn.Transaction, t)
+	t.indexMu.RLock()
+	defer t.indexMu.RUnlock()
 	t.mu.RLock()
 	defer t.mu.RUnlock()
 	value, ok := t.data.Get(recordId)
@@ -756,6 +825,7 @@ func (t *GoSqlTable) Update(recordId int64, recordValues Tuple, conn *GoSqlConnD
 		tuplep := value.(*VersionedTuple)
 		tuplep.mu.Lock()
 		defer tuplep.mu.Unlock()
+		conn.Transaction.recordUndo(tuplep, true, true)
 		version := &tuplep.Versions[len(tuplep.Versions)-1]
 		if version.xmax != 0 {
 			version.flags &= ^FOR_UPDATE_FLAG
@@ -764,6 +834,9 @@ func (t *GoSqlTable) Update(recordId int64, recordValues Tuple, conn *GoSqlConnD
 		version.cid = conn.Transaction.Cid
 		recordVersion := TupleVersion{recordValues.(*SliceTuple).data, conn.Transaction.Xid, 0, 0, conn.Transaction.Cid}
 		tuplep.Versions = append(tuplep.Versions, recordVersion)
+		t.addIndexEntries(recordId, recordVersion.Data)
+		logTableChange(walUpdate, t, recordId, recordVersion.Data, conn)
+		t.countChange()
 	}
 	return ok
 }
@@ -780,8 +853,3 @@ func (t *TempTable) Update(recordId int64, recordValues Tuple, conn *GoSqlConnDa
 func (t *TempTable) Delete(recordId int64, conn *GoSqlConnData) bool {
 	panic("not implemented")
 }
-
-var (
-	Schemas = make(map[string]map[string]Table)
-	// Tables map[string]Table = make(map[string]Table)
-)
@@ -0,0 +1,81 @@
+package data
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// A Database is an instance holding its own schemas, transactions, temporary tables and write-ahead log, which
+// are independent of the ones of all other databases of the process. The connections opened by the driver with the
+// same DSN share a database: "memory:<name>" opens the in memory database name, a directory the database logged
+// there and every other DSN the default database.
+
+type Database struct {
+	Name                string
+	Schemas             map[string]map[string]Table // the tables committed by schema and name, protected by tablesMu
+	tablesMu            sync.Mutex
+	tableLocks          map[string]*tableLock  // by schema and name, protected by tablesMu
+	catalogTransactions map[int64]*Transaction // having catalog changes, protected by tablesMu
+	transactionManager  *transactionManagerType
+	nextTempTableId     atomic.Int64
+	wal                 atomic.Pointer[writeAheadLog]
+	walMu               sync.Mutex
+}
+
+const DEFAULT_DATABASE_NAME = "memory"
+
+var (
+	databases   = make(map[string]*Database)
+	databasesMu sync.Mutex
+)
+
+func newDatabase(name string) *Database {
+	return &Database{name, make(map[string]map[string]Table), sync.Mutex{}, make(map[string]*tableLock),
+		make(map[int64]*Transaction), NewTransactionManager(), atomic.Int64{}, atomic.Pointer[writeAheadLog]{}, sync.Mutex{}}
+}
+
+// OpenDatabase returns the database name, it is created if it does not exist
+func OpenDatabase(name string) *Database {
+	databasesMu.Lock()
+	defer databasesMu.Unlock()
+	db, ok := databases[name]
+	if !ok {
+		db = newDatabase(name)
+		databases[name] = db
+	}
+	return db
+}
+
+// CreateDatabase creates the empty database name, fails if it exists
+func CreateDatabase(name string) (*Database, error) {
+	databasesMu.Lock()
+	defer databasesMu.Unlock()
+	if _, ok := databases[name]; ok {
+		return nil, fmt.Errorf("database %s already exists", name)
+	}
+	db := newDatabase(name)
+	databases[name] = db
+	return db, nil
+}
+
+// DatabaseExists is true, if the database name got created and not destroyed since
+func DatabaseExists(name string) bool {
+	databasesMu.Lock()
+	defer databasesMu.Unlock()
+	_, ok := databases[name]
+	return ok
+}
+
+// DestroyDatabase forgets the database name and closes its write-ahead log, the files stay. Connections opened
+// before keep using it, the next one opened gets a new database.
+func DestroyDatabase(name string) error {
+	databasesMu.Lock()
+	db, ok := databases[name]
+	delete(databases, name)
+	databasesMu.Unlock()
+	if !ok {
+		return fmt.Errorf("database %s does not exist", name)
+	}
+	return db.CloseWriteAheadLog()
+}
@@ -0,0 +1,161 @@
+package data
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// A foreign key of a table references the columns of a PRIMARY KEY or UNIQUE constraint of the parent table.
+// It is kept by the referencing table, the parent finds the keys referencing it by ReferencingKeys.
+// The checks and referential actions are done by the statements changing the tuples. They look for the tuples by
+// NewKeyIterator and lock them like postgres does: a child locks its parent FOR KEY SHARE, so the parent can not be
+// deleted or get another key until the transaction of the child ended.
+
+type ReferentialAction int8
+
+const (
+	FK_NO_ACTION ReferentialAction = iota
+	FK_RESTRICT
+	FK_CASCADE
+	FK_SET_NULL
+	FK_SET_DEFAULT
+)
+
+type GoSqlForeignKey struct {
+	Name          string
+	Child         *GoSqlTable
+	Columns       []int // indexes of the referencing columns of Child
+	Parent        *GoSqlTable
+	ParentColumns []int // indexes of the referenced columns of Parent
+	OnDelete      ReferentialAction
+	OnUpdate      ReferentialAction
+}
+
+var ErrForeignKeyViolation = errors.New("violates foreign key constraint")
+
+// Violation returns the error, the foreign key is violated by the change described by format and args
+func (fk *GoSqlForeignKey) Violation(format string, args ...any) error {
+	return fmt.Errorf("%s %w %s", fmt.Sprintf(format, args...), ErrForeignKeyViolation, fk.Name)
+}
+
+// AddForeignKey adds the foreign key to its child table. The referenced columns must be the ones of a
+// PRIMARY KEY or UNIQUE constraint of the parent.
+func AddForeignKey(fk *GoSqlForeignKey) error {
+	if !slices.ContainsFunc(fk.Parent.Indexes(), func(ix *GoSqlIndex) bool {
+		return ix.IsConstraint() && sameColumns(ix.Columns, fk.ParentColumns)
+	}) {
+		return fmt.Errorf("there is no unique constraint matching the referenced columns of %s", fk.Parent.TableName)
+	}
+	for i, col := range fk.Columns {
+		if fk.Child.TableColumns[col].ParserType != fk.Parent.TableColumns[fk.ParentColumns[i]].ParserType {
+			return fmt.Errorf("column %s of foreign key %s has another type than the referenced column",
+				fk.Child.TableColumns[col].Name, fk.Name)
+		}
+	}
+	fk.Child.indexMu.Lock()
+	defer fk.Child.indexMu.Unlock()
+	fk.Child.foreignKeys = append(fk.Child.foreignKeys, fk)
+	return nil
+}
+
+func sameColumns(a []int, b []int) bool {
+	return len(a) == len(b) && !slices.ContainsFunc(a, func(col int) bool { return !slices.Contains(b, col) })
+}
+
+// ForeignKeys returns the foreign keys of the table
+func (t *GoSqlTable) ForeignKeys() []*GoSqlForeignKey {
+	t.indexMu.RLock()
+	defer t.indexMu.RUnlock()
+	return slices.Clone(t.foreignKeys)
+}
+
+// ReferencingKeys returns the foreign keys of all tables referencing the table, including the ones created by
+// running transactions
+func (t *GoSqlTable) ReferencingKeys() []*GoSqlForeignKey {
+	var res []*GoSqlForeignKey
+	for _, table := range append(t.db.collectGoSqlTables(), t.db.uncommittedTables()...) {
+		for _, fk := range table.ForeignKeys() {
+			if fk.Parent == t {
+				res = append(res, fk)
+			}
+		}
+	}
+	return res
+}
+
+// Key returns the values of the columns of the tuple
+func Key(tuple Tuple, columns []int) []driver.Value {
+	res := make([]driver.Value, len(columns))
+	for i, col := range columns {
+		res[i] = tuple.SafeData(0, col)
+	}
+	return res
+}
+
+// MatchesKey is true, if the columns of the tuple have the values of the key. NULL matches nothing.
+func MatchesKey(tuple Tuple, columns []int, key []driver.Value) bool {
+	for i, col := range columns {
+		value := tuple.SafeData(0, col)
+		if value == nil || key[i] == nil || compareValues(value, key[i]) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// NewKeyIterator iterates the tuples of the table, whose columns may have the values of key, using an index having
+// these columns as leading ones if there is one. With crossCheck the iterator looks at the latest committed state,
+// so it also finds the tuples inserted after the snapshot of a REPEATABLE READ or SERIALIZABLE transaction.
+// Next fails with ErrTraSerialization, if it finds such a tuple.
+func (t *GoSqlTable) NewKeyIterator(baseData *StatementBaseData, forChange bool, columns []int, key []driver.Value, crossCheck bool) TableIterator {
+	var res TableIterator
+	ti := t.NewIterator(baseData, forChange).(*GoSqlTableIterator)
+	res = ti
+	for _, ix := range t.Indexes() {
+		if len(ix.Columns) >= len(columns) && sameColumns(ix.Columns[:len(columns)], columns) {
+			var r IndexRange
+			for _, col := range ix.Columns[:len(columns)] {
+				r.Equal = append(r.Equal, key[slices.Index(columns, col)])
+			}
+			res = &GoSqlIndexIterator{ti, ix.candidates(r), 0}
+			break
+		}
+	}
+	if ti.err != nil || !crossCheck || ti.Transaction == nil || ti.Transaction.IsolationLevel == COMMITTED_READ {
+		return res
+	}
+	own := *ti.SnapShot
+	ti.setSnapShot(ti.db.GetSnapShot(ti.Transaction))
+	return &crossCheckIterator{res, &GoSqlTableIterator{ti.Transaction, &own, t, 0, false, ti.lockStrength, ti.lockWait, nil, ti.db}}
+}
+
+type crossCheckIterator struct {
+	TableIterator
+	own *GoSqlTableIterator // not registered, only used to decide the visibility in the snapshot of the transaction
+}
+
+func (ci *crossCheckIterator) Next(check func(Tuple) (bool, error)) (Tuple, bool, error) {
+	tuple, ok, err := ci.TableIterator.Next(check)
+	if !ok || err != nil {
+		return tuple, ok, err
+	}
+	ci.own.table.mu.RLock()
+	value, found := ci.own.table.data.Get(tuple.Id())
+	ci.own.table.mu.RUnlock()
+	if !found {
+		return tuple, ok, nil
+	}
+	versionedTuple := value.(*VersionedTuple)
+	versionedTuple.mu.Lock()
+	visible, _, _, err := ci.own.isVisibleTuple(versionedTuple, false)
+	versionedTuple.mu.Unlock()
+	if err != nil {
+		return NULL_TUPLE, false, err
+	}
+	if !visible {
+		return NULL_TUPLE, false, ErrTraSerialization
+	}
+	return tuple, ok, nil
+}
@@ -0,0 +1,594 @@
+package data
+
+import (
+	"bytes"
+	"cmp"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emirpasic/gods/trees/redblacktree"
+)
+
+// An index of a GoSqlTable is an ordered tree of the values of its columns plus the id of the tuple.
+// Like in postgres it does not know about visibility: there is an entry for every key any version of a tuple has,
+// entries are added by Insert and Update and kept after a Delete, since older snapshots still see the tuple.
+// So an index scan only yields candidates, whose visible version is evaluated by the iterator as usual.
+// Vacuum removes the entries of removed tuples and of keys no version has anymore.
+//
+// Unique indexes back the PRIMARY KEY and UNIQUE constraints. Their check does not use a snapshot: a key inserted
+// or deleted by a running transaction makes the change wait for its end, just like a lock does.
+
+type IndexKind int8
+
+const (
+	PLAIN_INDEX            IndexKind = iota
+	UNIQUE_INDEX                     // created by CREATE UNIQUE INDEX
+	UNIQUE_CONSTRAINT                // of a UNIQUE constraint of the table, not dropped by DROP INDEX
+	PRIMARY_KEY_CONSTRAINT           // of the PRIMARY KEY of the table, whose columns must not be NULL
+)
+
+type GoSqlIndex struct {
+	Name    string
+	Kind    IndexKind
+	Columns []int // indexes of the columns in the tuples
+	tree    *redblacktree.Tree
+	mu      sync.Mutex
+}
+
+type indexKey struct {
+	values []driver.Value
+	id     int64
+}
+
+// IndexRange describes the keys an index scan looks at
+type IndexRange struct {
+	Equal         []driver.Value // values of the leading columns of the index
+	Lower         driver.Value   // bound of the column following them, nil if there is none
+	Upper         driver.Value   // bound of the column following them, nil if there is none
+	LowerIncluded bool
+	UpperIncluded bool
+}
+
+// orders values of the same type, NULL first. Values of different types are ordered by type
+func compareValues(a driver.Value, b driver.Value) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return -1
+		default:
+			return 1
+		}
+	}
+	switch x := a.(type) {
+	case int64:
+		switch y := b.(type) {
+		case int64:
+			return cmp.Compare(x, y)
+		case float64:
+			return cmp.Compare(float64(x), y)
+		}
+	case float64:
+		switch y := b.(type) {
+		case int64:
+			return cmp.Compare(x, float64(y))
+		case float64:
+			return cmp.Compare(x, y)
+		}
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y)
+		}
+	case bool:
+		if y, ok := b.(bool); ok {
+			switch {
+			case x == y:
+				return 0
+			case !x:
+				return -1
+			default:
+				return 1
+			}
+		}
+	case time.Time:
+		if y, ok := b.(time.Time); ok {
+			return x.Compare(y)
+		}
+	case []byte:
+		if y, ok := b.([]byte); ok {
+			return bytes.Compare(x, y)
+		}
+	}
+	return strings.Compare(fmt.Sprintf("%T", a), fmt.Sprintf("%T", b))
+}
+
+// ValuesKey returns a string, which is the same for values compareValues finds equal: numbers are equal by value,
+// whatever their type, and times by the instant, whatever their location. It keys maps counting or deduplicating rows.
+func ValuesKey(values []driver.Value) string {
+	var b strings.Builder
+	for _, value := range values {
+		switch x := value.(type) {
+		case nil:
+			b.WriteString("n")
+		case int64:
+			if int64(float64(x)) == x { // else float64 can not hold it exactly
+				fmt.Fprintf(&b, "f%s", strconv.FormatFloat(float64(x), 'g', -1, 64))
+			} else {
+				fmt.Fprintf(&b, "i%d", x)
+			}
+		case float64:
+			if x == 0 {
+				x = 0 // -0 equals 0
+			}
+			fmt.Fprintf(&b, "f%s", strconv.FormatFloat(x, 'g', -1, 64))
+		case string:
+			fmt.Fprintf(&b, "s%d:%s", len(x), x)
+		case bool:
+			fmt.Fprintf(&b, "b%t", x)
+		case time.Time:
+			fmt.Fprintf(&b, "t%d.%d", x.UTC().Unix(), x.Nanosecond())
+		case []byte:
+			fmt.Fprintf(&b, "x%d:%s", len(x), x)
+		default:
+			fmt.Fprintf(&b, "%T:%#v", x, x)
+		}
+		b.WriteString(",")
+	}
+	return b.String()
+}
+
+// a shorter key is lower than the longer ones starting with it, so it can be used to search for a prefix
+func compareIndexKeys(a, b interface{}) int {
+	ka, kb := a.(indexKey), b.(indexKey)
+	for ix := 0; ix < len(ka.values) && ix < len(kb.values); ix++ {
+		if c := compareValues(ka.values[ix], kb.values[ix]); c != 0 {
+			return c
+		}
+	}
+	if len(ka.values) != len(kb.values) {
+		return cmp.Compare(len(ka.values), len(kb.values))
+	}
+	return cmp.Compare(ka.id, kb.id)
+}
+
+func newIndex(name string, kind IndexKind, columns []int) *GoSqlIndex {
+	return &GoSqlIndex{name, kind, columns, redblacktree.NewWith(compareIndexKeys), sync.Mutex{}}
+}
+
+func (ix *GoSqlIndex) IsUnique() bool {
+	return ix.Kind != PLAIN_INDEX
+}
+
+func (ix *GoSqlIndex) IsConstraint() bool {
+	return ix.Kind == UNIQUE_CONSTRAINT || ix.Kind == PRIMARY_KEY_CONSTRAINT
+}
+
+func (ix *GoSqlIndex) key(values []driver.Value) []driver.Value {
+	res := make([]driver.Value, len(ix.Columns))
+	for i, col := range ix.Columns {
+		res[i] = values[col]
+	}
+	return res
+}
+
+func keysEqual(a []driver.Value, b []driver.Value) bool {
+	return slices.EqualFunc(a, b, func(x, y driver.Value) bool { return compareValues(x, y) == 0 })
+}
+
+func (ix *GoSqlIndex) add(id int64, values []driver.Value) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.tree.Put(indexKey{ix.key(values), id}, nil)
+}
+
+// the ids of the tuples having a key within r, sorted like a scan of the table would return them
+func (ix *GoSqlIndex) candidates(r IndexRange) []int64 {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	start := slices.Clone(r.Equal)
+	if r.Lower != nil {
+		start = append(start, r.Lower)
+	}
+	node, _ := ix.tree.Ceiling(indexKey{start, math.MinInt64})
+	if node == nil {
+		return nil
+	}
+	var ids []int64
+	bounded := len(r.Equal) < len(ix.Columns) && (r.Lower != nil || r.Upper != nil)
+	it := ix.tree.IteratorAt(node)
+	for ok := true; ok; ok = it.Next() {
+		key := it.Key().(indexKey)
+		if !keysEqual(key.values[:len(r.Equal)], r.Equal) {
+			break
+		}
+		if bounded {
+			value := key.values[len(r.Equal)]
+			if value == nil {
+				continue
+			}
+			if r.Lower != nil {
+				if c := compareValues(value, r.Lower); c < 0 || c == 0 && !r.LowerIncluded {
+					continue
+				}
+			}
+			if r.Upper != nil {
+				if c := compareValues(value, r.Upper); c > 0 || c == 0 && !r.UpperIncluded {
+					break
+				}
+			}
+		}
+		ids = append(ids, key.id)
+	}
+	slices.Sort(ids)
+	return slices.Compact(ids)
+}
+
+// the index of the table with the name, nil if there is none
+func (t *GoSqlTable) FindIndex(name string) *GoSqlIndex {
+	t.indexMu.RLock()
+	defer t.indexMu.RUnlock()
+	for _, ix := range t.indexes {
+		if ix.Name == name {
+			return ix
+		}
+	}
+	return nil
+}
+
+func (t *GoSqlTable) Indexes() []*GoSqlIndex {
+	t.indexMu.RLock()
+	defer t.indexMu.RUnlock()
+	return slices.Clone(t.indexes)
+}
+
+// true if the column is part of a unique index, so changing it changes a key of the tuple
+func (t *GoSqlTable) IsKeyColumn(column int) bool {
+	return slices.ContainsFunc(t.Indexes(), func(ix *GoSqlIndex) bool {
+		return ix.IsUnique() && slices.Contains(ix.Columns, column)
+	})
+}
+
+// FindIndex searches the index with the name in the tables of the schema
+func (db *Database) FindIndex(schema string, name string) (*GoSqlTable, *GoSqlIndex) {
+	db.tablesMu.Lock()
+	defer db.tablesMu.Unlock()
+	for _, table := range db.Schemas[schema] {
+		if t, ok := table.(*GoSqlTable); ok {
+			if ix := t.FindIndex(name); ix != nil {
+				return t, ix
+			}
+		}
+	}
+	return nil, nil
+}
+
+// CreateIndex adds an index over the columns, fails if it is unique and the tuples violate it
+func (t *GoSqlTable) CreateIndex(name string, kind IndexKind, columns []int, conn *GoSqlConnData) error {
+	// changes of the table wait until the index is complete
+	t.indexMu.Lock()
+	defer t.indexMu.Unlock()
+	if kind == PRIMARY_KEY_CONSTRAINT && slices.ContainsFunc(t.indexes, func(ix *GoSqlIndex) bool { return ix.Kind == kind }) {
+		return fmt.Errorf("multiple primary keys for table %s are not allowed", t.TableName)
+	}
+	ix := newIndex(name, kind, columns)
+	t.mu.RLock()
+	tuples := make([]*VersionedTuple, 0, t.data.Size())
+	it := t.data.Iterator()
+	for it.Next() {
+		tuples = append(tuples, it.Value().(*VersionedTuple))
+	}
+	t.mu.RUnlock()
+	for _, tuple := range tuples {
+		tuple.mu.Lock()
+		for _, version := range tuple.Versions {
+			ix.add(tuple.id, t.shaped(version.Data))
+		}
+		tuple.mu.Unlock()
+	}
+	if ix.IsUnique() {
+		err := ix.checkAll(t, conn)
+		if err != nil {
+			return err
+		}
+	}
+	t.indexes = append(t.indexes, ix)
+	return nil
+}
+
+// DropIndex removes the index, returns false if the table has none with the name
+func (t *GoSqlTable) DropIndex(name string) bool {
+	t.indexMu.Lock()
+	defer t.indexMu.Unlock()
+	count := len(t.indexes)
+	t.indexes = slices.DeleteFunc(t.indexes, func(ix *GoSqlIndex) bool { return ix.Name == name })
+	return len(t.indexes) < count
+}
+
+// adds the entries for a new version of the tuple to all indexes, the caller holds indexMu
+func (t *GoSqlTable) addIndexEntries(id int64, values []driver.Value) {
+	for _, ix := range t.indexes {
+		ix.add(id, values)
+	}
+}
+
+func (db *Database) isRolledbackTransaction(xid int64) bool {
+	tra, err := db.GetTransaction(xid)
+	return err == nil && tra.State == ROLLEDBACK
+}
+
+// checks, if the tuple has the key, not as seen by a snapshot, but taking into account all transactions, which
+// did not roll back. Returns the running transaction, whose outcome decides, if it will keep it,
+// NO_TRANSACTION if it is decided already.
+func (ix *GoSqlIndex) holdsKey(t *GoSqlTable, tuple *VersionedTuple, key []driver.Value, xid int64) (bool, int64) {
+	db := t.db
+	latest := len(tuple.Versions) - 1
+	for latest >= 0 && db.isRolledbackTransaction(tuple.Versions[latest].xmin) {
+		latest--
+	}
+	if latest < 0 {
+		return false, NO_TRANSACTION
+	}
+	version := &tuple.Versions[latest]
+	holds := keysEqual(ix.key(t.shaped(version.Data)), key)
+	if version.xmin != xid && db.isRunningTransaction(version.xmin) {
+		// if the change gets rolled back, the previous version is the current one again
+		if !holds && latest > 0 {
+			holds = keysEqual(ix.key(t.shaped(tuple.Versions[latest-1].Data)), key)
+		}
+		return holds, version.xmin
+	}
+	if !holds || version.xmax == NO_TRANSACTION || version.flags&FOR_UPDATE_FLAG != 0 {
+		return holds, NO_TRANSACTION
+	}
+	switch {
+	case version.xmax == xid || version.xmax == version.xmin:
+		return false, NO_TRANSACTION
+	case db.isRunningTransaction(version.xmax):
+		return true, version.xmax
+	case db.isRolledbackTransaction(version.xmax):
+		return true, NO_TRANSACTION
+	default:
+		return false, NO_TRANSACTION
+	}
+}
+
+var ErrUniqueViolation = errors.New("duplicate key value violates unique constraint")
+
+func (ix *GoSqlIndex) duplicateError(key []driver.Value) error {
+	return fmt.Errorf("%w %s: key %v", ErrUniqueViolation, ix.Name, key)
+}
+
+// searches a tuple other than id having the key. If only tuples are found, whose key depends on the outcome of a
+// running transaction, that one is returned.
+func (ix *GoSqlIndex) findDuplicate(t *GoSqlTable, id int64, key []driver.Value, xid int64) (bool, int64) {
+	if slices.Contains(key, nil) {
+		// NULL is not equal to anything
+		return false, NO_TRANSACTION
+	}
+	found, running := false, NO_TRANSACTION
+	for _, other := range ix.candidates(IndexRange{Equal: key}) {
+		if other == id {
+			continue
+		}
+		t.mu.RLock()
+		value, ok := t.data.Get(other)
+		t.mu.RUnlock()
+		if !ok {
+			continue
+		}
+		tuple := value.(*VersionedTuple)
+		tuple.mu.Lock()
+		holds, deciding := ix.holdsKey(t, tuple, key, xid)
+		tuple.mu.Unlock()
+		if holds {
+			if deciding == NO_TRANSACTION {
+				return true, NO_TRANSACTION
+			}
+			found, running = true, deciding
+		}
+	}
+	return found, running
+}
+
+// checks a new unique index against all tuples of the table
+func (ix *GoSqlIndex) checkAll(t *GoSqlTable, conn *GoSqlConnData) error {
+	xid := NO_TRANSACTION
+	if conn != nil && conn.Transaction != nil {
+		xid = conn.Transaction.Xid
+	}
+	var keys [][]driver.Value
+	ix.mu.Lock()
+	it := ix.tree.Iterator()
+	for it.Next() {
+		keys = append(keys, it.Key().(indexKey).values)
+	}
+	ix.mu.Unlock()
+	for i := range keys {
+		if ix.Kind == PRIMARY_KEY_CONSTRAINT && slices.Contains(keys[i], nil) {
+			return ix.nullError()
+		}
+		if i == 0 || !keysEqual(keys[i-1], keys[i]) || slices.Contains(keys[i], nil) {
+			continue
+		}
+		holders := 0
+		for _, id := range ix.candidates(IndexRange{Equal: keys[i]}) {
+			t.mu.RLock()
+			value, ok := t.data.Get(id)
+			t.mu.RUnlock()
+			if !ok {
+				continue
+			}
+			tuple := value.(*VersionedTuple)
+			tuple.mu.Lock()
+			holds, _ := ix.holdsKey(t, tuple, keys[i], xid)
+			tuple.mu.Unlock()
+			if holds {
+				holders++
+			}
+		}
+		if holders > 1 {
+			return ix.duplicateError(keys[i])
+		}
+	}
+	return nil
+}
+
+func (ix *GoSqlIndex) nullError() error {
+	return fmt.Errorf("%w of primary key %s", ErrNotNullViolation, ix.Name)
+}
+
+// checks the values of the tuple id against the unique indexes, the caller holds uniqueMu.
+// Returns the running transaction to wait for, if the check depends on its outcome.
+func (t *GoSqlTable) checkUnique(id int64, values []driver.Value, conn *GoSqlConnData) (int64, error) {
+	xid := NO_TRANSACTION
+	if conn.Transaction != nil {
+		xid = conn.Transaction.Xid
+	}
+	for _, ix := range t.Indexes() {
+		if !ix.IsUnique() {
+			continue
+		}
+		key := ix.key(values)
+		if ix.Kind == PRIMARY_KEY_CONSTRAINT && slices.Contains(key, nil) {
+			return NO_TRANSACTION, ix.nullError()
+		}
+		duplicate, deciding := ix.findDuplicate(t, id, key, xid)
+		if duplicate {
+			if deciding != NO_TRANSACTION {
+				return deciding, nil
+			}
+			return NO_TRANSACTION, ix.duplicateError(key)
+		}
+	}
+	return NO_TRANSACTION, nil
+}
+
+// does the change, if the values do not violate the unique indexes of the table. If that depends on a running
+// transaction, which inserted, updated or deleted the same key, the change waits until it ended.
+// The values are brought into the current shape of the table first, ALTER TABLE might have changed it since the
+// statement read its columns.
+func (t *GoSqlTable) changeChecked(id int64, values []driver.Value, conn *GoSqlConnData, change func(values []driver.Value)) error {
+	err := t.Lock(conn)
+	if err != nil {
+		return err
+	}
+	var deadline time.Time
+	for {
+		t.uniqueMu.Lock()
+		values, err := t.shape(values)
+		if err != nil {
+			t.uniqueMu.Unlock()
+			return err
+		}
+		deciding, err := t.checkUnique(id, values, conn)
+		if err != nil || deciding == NO_TRANSACTION {
+			if err == nil {
+				change(values)
+			}
+			t.uniqueMu.Unlock()
+			return err
+		}
+		t.uniqueMu.Unlock()
+		// the waiting transaction takes part in the deadlock detection
+		StartTransaction(conn)
+		if deadline.IsZero() && conn.Transaction.MaxLockTimeInMs > 0 {
+			deadline = time.Now().Add(time.Duration(conn.Transaction.MaxLockTimeInMs) * time.Millisecond)
+		}
+		err = waitForTransaction(conn.Transaction, deciding, deadline)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// InsertChecked inserts the tuple, if it does not violate the unique indexes of the table
+func (t *GoSqlTable) InsertChecked(recordValues []driver.Value, conn *GoSqlConnData) (int64, error) {
+	id := int64(-1)
+	err := t.changeChecked(-1, recordValues, conn, func(values []driver.Value) { id = t.Insert(values, conn) })
+	return id, err
+}
+
+// UpdateChecked updates the tuple, if the new version does not violate the unique indexes of the table
+func (t *GoSqlTable) UpdateChecked(recordId int64, recordValues Tuple, conn *GoSqlConnData) (bool, error) {
+	done := false
+	err := t.changeChecked(recordId, recordValues.(*SliceTuple).data, conn, func(values []driver.Value) {
+		done = t.Update(recordId, NewSliceTuple(recordId, values), conn)
+	})
+	return done, err
+}
+
+// removes the entries of tuples removed by vacuum and of keys no version of the tuple has anymore
+func (t *GoSqlTable) vacuumIndexes() {
+	for _, ix := range t.Indexes() {
+		ix.mu.Lock()
+		var keys []indexKey
+		it := ix.tree.Iterator()
+		for it.Next() {
+			keys = append(keys, it.Key().(indexKey))
+		}
+		ix.mu.Unlock()
+		for _, key := range keys {
+			t.mu.RLock()
+			value, ok := t.data.Get(key.id)
+			t.mu.RUnlock()
+			if !ok {
+				ix.mu.Lock()
+				ix.tree.Remove(key)
+				ix.mu.Unlock()
+				continue
+			}
+			tuple := value.(*VersionedTuple)
+			// updates add their entries while holding the lock of the tuple, so none gets lost
+			tuple.mu.Lock()
+			if !slices.ContainsFunc(tuple.Versions, func(v TupleVersion) bool { return keysEqual(ix.key(t.shaped(v.Data)), key.values) }) {
+				ix.mu.Lock()
+				ix.tree.Remove(key)
+				ix.mu.Unlock()
+			}
+			tuple.mu.Unlock()
+		}
+	}
+}
+
+type GoSqlIndexIterator struct {
+	*GoSqlTableIterator
+	ids []int64
+	ix  int
+}
+
+// NewIndexIterator returns an iterator over the tuples having a key within r, which are visible and selected
+func (t *GoSqlTable) NewIndexIterator(baseData *StatementBaseData, forChange bool, index *GoSqlIndex, r IndexRange) TableIterator {
+	ti := t.NewIterator(baseData, forChange).(*GoSqlTableIterator)
+	// versions visible for the snapshot of the iterator already have their entries
+	return &GoSqlIndexIterator{ti, index.candidates(r), 0}
+}
+
+func (ii *GoSqlIndexIterator) Next(check func(Tuple) (bool, error)) (Tuple, bool, error) {
+	if ii.err != nil {
+		return NULL_TUPLE, false, ii.err
+	}
+	for ii.ix < len(ii.ids) {
+		id := ii.ids[ii.ix]
+		ii.ix++
+		ii.table.mu.RLock()
+		value, ok := ii.table.data.Get(id)
+		ii.table.mu.RUnlock()
+		if !ok {
+			continue
+		}
+		tuple, done, err := ii.handleCandidate(check, ii.forUpdate, value.(*VersionedTuple))
+		if done || err != nil {
+			return tuple, done, err
+		}
+	}
+	ii.Close()
+	return NULL_TUPLE, false, nil
+}
@@ -0,0 +1,213 @@
+package data
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"slices"
+)
+
+// The schema information_schema holds read-only tables describing the catalog, like the ones of the sql standard.
+// They are not stored, their tuples are built from the schemas and tables the transaction of the statement sees,
+// when it starts reading them. So they can be queried and joined like any other table.
+
+const INFORMATION_SCHEMA_NAME = "information_schema"
+
+// InformationSchemaColumn returns the column name of the type text, if text, of the type integer otherwise.
+// ColumnTypeName returns the sql name of the type of a column. Both are set by the parser, which knows the types.
+var (
+	InformationSchemaColumn func(name string, text bool) GoSqlColumn
+	ColumnTypeName          func(column GoSqlColumn) string
+)
+
+// the columns of the tables of information_schema, the ones not named in informationSchemaIntegers are texts
+var informationSchemaColumns = map[string][]string{
+	"schemata": {"catalog_name", "schema_name"},
+	"tables":   {"table_catalog", "table_schema", "table_name", "table_type"},
+	"columns": {"table_catalog", "table_schema", "table_name", "column_name", "ordinal_position", "is_nullable",
+		"data_type", "character_maximum_length"},
+	"table_constraints": {"constraint_catalog", "constraint_schema", "constraint_name", "table_catalog", "table_schema",
+		"table_name", "constraint_type"},
+	"key_column_usage": {"constraint_catalog", "constraint_schema", "constraint_name", "table_catalog", "table_schema",
+		"table_name", "column_name", "ordinal_position", "position_in_unique_constraint"},
+}
+
+var informationSchemaIntegers = []string{"ordinal_position", "character_maximum_length", "position_in_unique_constraint"}
+
+type InformationSchemaTable struct {
+	BaseTable
+}
+
+// the table of information_schema named name, nil if there is none
+func informationSchemaTable(name string) Table {
+	names, ok := informationSchemaColumns[name]
+	if !ok {
+		return nil
+	}
+	columns := make([]GoSqlColumn, len(names))
+	for ix, column := range names {
+		columns[ix] = InformationSchemaColumn(column, !slices.Contains(informationSchemaIntegers, column))
+	}
+	return &InformationSchemaTable{BaseTable{INFORMATION_SCHEMA_NAME, name, columns}}
+}
+
+func (t *InformationSchemaTable) Data() *[][]driver.Value {
+	return nil
+}
+
+// NewIterator iterates over the tuples built from the catalog seen by the statement. The tuples can not be
+// changed or locked, an iterator for that fails.
+func (t *InformationSchemaTable) NewIterator(baseData *StatementBaseData, forChange bool) TableIterator {
+	if forChange {
+		return &readOnlyIterator{t}
+	}
+	res := TempTableIterator{&TempTable{t.BaseTable, t.rows(baseData.Conn)}, 0}
+	return &res
+}
+
+type readOnlyIterator struct {
+	table Table
+}
+
+func (it *readOnlyIterator) GetTable() Table {
+	return it.table
+}
+
+func (it *readOnlyIterator) Next(check func(Tuple) (bool, error)) (Tuple, bool, error) {
+	return NULL_TUPLE, false, fmt.Errorf("table %s.%s is read-only", it.table.Schema(), it.table.Name())
+}
+
+func (it *readOnlyIterator) Close() {}
+
+func (t *InformationSchemaTable) Insert(recordValues []driver.Value, conn *GoSqlConnData) int64 {
+	panic("not implemented")
+}
+
+func (t *InformationSchemaTable) Update(recordId int64, recordValues Tuple, conn *GoSqlConnData) bool {
+	panic("not implemented")
+}
+
+func (t *InformationSchemaTable) Delete(recordId int64, conn *GoSqlConnData) bool {
+	panic("not implemented")
+}
+
+// the tables of information_schema sorted by name
+func informationSchemaTables() []Table {
+	var names []string
+	for name := range informationSchemaColumns {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	res := make([]Table, len(names))
+	for ix, name := range names {
+		res[ix] = informationSchemaTable(name)
+	}
+	return res
+}
+
+// the tables and views of the schema seen by the transaction of conn. Like in postgres materialized views and
+// sequences are left out, they are not tables of the sql standard.
+func visibleTables(conn *GoSqlConnData, schema string) []Table {
+	if schema == INFORMATION_SCHEMA_NAME {
+		return informationSchemaTables()
+	}
+	return slices.DeleteFunc(schemaRelations(conn, schema), func(table Table) bool {
+		_, isSequence := table.(*Sequence)
+		t, ok := table.(*GoSqlTable)
+		return isSequence || ok && t.IsMaterializedView()
+	})
+}
+
+func (t *InformationSchemaTable) rows(conn *GoSqlConnData) [][]driver.Value {
+	catalog := conn.Database.Name
+	var res [][]driver.Value
+	for _, schema := range SchemaNames(conn) {
+		if t.TableName == "schemata" {
+			res = append(res, []driver.Value{catalog, schema})
+			continue
+		}
+		for _, table := range visibleTables(conn, schema) {
+			res = append(res, tableRows(t.TableName, catalog, table)...)
+		}
+	}
+	return res
+}
+
+// the tuples describing table in the table of information_schema named name
+func tableRows(name string, catalog string, table Table) [][]driver.Value {
+	var res [][]driver.Value
+	schema := table.Schema()
+	switch name {
+	case "tables":
+		tableType := "BASE TABLE"
+		if _, ok := table.(*View); ok || schema == INFORMATION_SCHEMA_NAME {
+			tableType = "VIEW"
+		}
+		res = append(res, []driver.Value{catalog, schema, table.Name(), tableType})
+	case "columns":
+		position := int64(0)
+		for _, col := range table.Columns() {
+			if col.Hidden {
+				continue
+			}
+			position++
+			nullable := "YES"
+			if col.NotNull {
+				nullable = "NO"
+			}
+			var length driver.Value
+			if col.Length > 0 {
+				length = int64(col.Length)
+			}
+			res = append(res, []driver.Value{catalog, schema, table.Name(), col.Name, position, nullable, ColumnTypeName(col), length})
+		}
+	case "table_constraints":
+		t, ok := table.(*GoSqlTable)
+		if !ok {
+			break
+		}
+		for _, ix := range t.Indexes() {
+			if ix.IsConstraint() {
+				res = append(res, []driver.Value{catalog, schema, ix.Name, catalog, schema, t.TableName, ix.constraintType()})
+			}
+		}
+		for _, fk := range t.ForeignKeys() {
+			res = append(res, []driver.Value{catalog, schema, fk.Name, catalog, schema, t.TableName, "FOREIGN KEY"})
+		}
+		for _, col := range t.Columns() {
+			if col.Check != nil && !col.Hidden {
+				res = append(res, []driver.Value{catalog, schema, fmt.Sprintf("%s_%s_check", t.TableName, col.Name),
+					catalog, schema, t.TableName, "CHECK"})
+			}
+		}
+	case "key_column_usage":
+		t, ok := table.(*GoSqlTable)
+		if !ok {
+			break
+		}
+		columns := t.Columns()
+		for _, ix := range t.Indexes() {
+			if !ix.IsConstraint() {
+				continue
+			}
+			for position, col := range ix.Columns {
+				res = append(res, []driver.Value{catalog, schema, ix.Name, catalog, schema, t.TableName, columns[col].Name,
+					int64(position + 1), nil})
+			}
+		}
+		for _, fk := range t.ForeignKeys() {
+			for position, col := range fk.Columns {
+				res = append(res, []driver.Value{catalog, schema, fk.Name, catalog, schema, t.TableName, columns[col].Name,
+					int64(position + 1), int64(position + 1)})
+			}
+		}
+	}
+	return res
+}
+
+// the type of the constraint of a unique index
+func (ix *GoSqlIndex) constraintType() string {
+	if ix.Kind == PRIMARY_KEY_CONSTRAINT {
+		return "PRIMARY KEY"
+	}
+	return "UNIQUE"
+}
@@ -0,0 +1,182 @@
+package data
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+// A transaction waiting for a tuple locked by another transaction parks on the lockWait of that transaction.
+// The lockWait is woken when the transaction ends or rolls back to a savepoint, since both might release locks.
+// Woken waiters evaluate the tuple again and possibly go back to sleep.
+//
+// Before parking, the wait is added to the wait-for graph of the transaction manager. A waiting transaction
+// waits for exactly one other, so a deadlock is a cycle in the chain of holders starting at the new edge.
+// The transaction closing the cycle is the victim: it is rolled back, which wakes the others of the cycle.
+
+type LockWaitPolicy int8
+
+const (
+	LOCK_WAIT        LockWaitPolicy = iota // wait for the lock holder to end
+	LOCK_NOWAIT                            // fail with ErrLockNotAvailable
+	LOCK_SKIP_LOCKED                       // skip the tuple
+)
+
+// DEFAULT_LOCK_TIMEOUT_MS is the lock timeout of new connections, 0 means waiting without limit
+const DEFAULT_LOCK_TIMEOUT_MS = int64(1000)
+
+type lockWait struct {
+	mu sync.Mutex
+	ch chan struct{}
+}
+
+func newLockWait() *lockWait {
+	return &lockWait{ch: make(chan struct{})}
+}
+
+// the channel closed by the next wakeup
+func (w *lockWait) channel() <-chan struct{} {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.ch
+}
+
+func (w *lockWait) wakeup() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	close(w.ch)
+	w.ch = make(chan struct{})
+}
+
+var ErrDeadlock = errors.New("deadlock detected")
+
+var ErrLockNotAvailable = errors.New("could not obtain lock")
+
+// adds the edge waiter -> holder to the wait-for graph, fails with ErrDeadlock if that closes a cycle
+func (m *transactionManagerType) addWaitsFor(waiter int64, holder int64) error {
+	m.waitsForMu.Lock()
+	defer m.waitsForMu.Unlock()
+	for xid, steps := holder, 0; steps <= len(m.waitsFor); steps++ {
+		if xid == waiter {
+			return ErrDeadlock
+		}
+		next, waiting := m.waitsFor[xid]
+		if !waiting {
+			break
+		}
+		xid = next
+	}
+	m.waitsFor[waiter] = holder
+	return nil
+}
+
+func (m *transactionManagerType) removeWaitsFor(waiter int64) {
+	m.waitsForMu.Lock()
+	defer m.waitsForMu.Unlock()
+	delete(m.waitsFor, waiter)
+}
+
+func (t *Transaction) isRunning() bool {
+	return t.State == STARTED || t.State == ROLLBACKONLY
+}
+
+// true if xid is not ended yet, not as seen by a snapshot, but now
+func (db *Database) isRunningTransaction(xid int64) bool {
+	tra, err := db.GetTransaction(xid)
+	return err == nil && tra.isRunning()
+}
+
+// wakes all transactions waiting for locks held by t
+func (t *Transaction) wakeupWaiters() {
+	if t.lockWait != nil {
+		t.lockWait.wakeup()
+	}
+}
+
+// blocks until the transaction xid ended or released locks. Fails with ErrTraLockTimeout if the deadline passed
+// or with the error of the context of the connection of the waiter, if that is done. A zero deadline means
+// waiting without limit. If waiting would deadlock, the waiter is rolled back and ErrDeadlock returned.
+func waitForTransaction(waiter *Transaction, xid int64, deadline time.Time) error {
+	m := waiter.Conn.Database.transactionManager
+	tra, err := waiter.Conn.Database.GetTransaction(xid)
+	if err != nil {
+		return err
+	}
+	if tra.lockWait == nil {
+		return nil
+	}
+	// the channel is fetched before the state is checked, so a wakeup in between is not lost
+	wakeup := tra.lockWait.channel()
+	if !tra.isRunning() {
+		return nil
+	}
+	err = m.addWaitsFor(waiter.Xid, xid)
+	if err != nil {
+		// releases the locks of the waiter, so the other transactions of the cycle can go on
+		EndTransaction(waiter.Conn, ROLLEDBACK)
+		return err
+	}
+	defer m.removeWaitsFor(waiter.Xid)
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	var done <-chan struct{}
+	ctx := waiter.Conn.Ctx
+	if ctx != nil {
+		done = ctx.Done()
+	}
+	select {
+	case <-wakeup:
+		return nil
+	case <-timeout:
+		return ErrTraLockTimeout
+	case <-done:
+		return ctx.Err()
+	}
+}
+
+// true if the transaction holding a lock ended, so the lock does not conflict anymore
+func (db *Database) lockReleased(xid int64) bool {
+	tra, err := db.GetTransaction(xid)
+	return err == nil && !tra.isRunning()
+}
+
+// brings the snapshot of the iterator up to date after waiting for xid, the tuple is evaluated again afterwards.
+// Only statements running COMMITTED READ see what xid committed, the others can not change the tuples changed
+// by xid anymore, but the ones xid just locked.
+func (ti *GoSqlTableIterator) waitedFor(tuple *VersionedTuple, xid int64) error {
+	tra, err := ti.db.GetTransaction(xid)
+	if err != nil {
+		return err
+	}
+	if tra.isRunning() {
+		// woken by a rollback to a savepoint
+		return nil
+	}
+	var snapShot SnapShot
+	if ti.Transaction.IsolationLevel == COMMITTED_READ {
+		snapShot = *ti.db.GetSnapShot(ti.Transaction)
+	} else if tra.State == COMMITTED {
+		if tuple.changedBy(xid) {
+			return ErrTraSerialization
+		}
+		return nil
+	} else {
+		snapShot = *ti.SnapShot
+		snapShot.runningXids = slices.DeleteFunc(slices.Clone(snapShot.runningXids), func(running int64) bool { return running == xid })
+		snapShot.rolledbackXids = append(slices.Clone(snapShot.rolledbackXids), xid)
+	}
+	ti.setSnapShot(&snapShot)
+	return nil
+}
+
+// vacuum looks at the snapshots of the iterators, so they are replaced under the lock of the table
+func (ti *GoSqlTableIterator) setSnapShot(s *SnapShot) {
+	ti.table.mu.Lock()
+	defer ti.table.mu.Unlock()
+	ti.SnapShot = s
+}
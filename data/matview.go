@@ -0,0 +1,87 @@
+package data
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// A materialized view is a table holding the tuples of a select, they are computed when it gets created and
+// recomputed by REFRESH. They are read like the ones of any other table, but only REFRESH changes them.
+// REFRESH holds the view exclusively, like TRUNCATE, so refreshes wait for each other. Readers are not blocked,
+// they see the tuples committed before until the refreshing transaction commits:
+//   - REFRESH deletes all tuples and inserts the ones computed.
+//   - REFRESH CONCURRENTLY deletes the tuples not computed anymore and inserts the new ones, the others keep their
+//     versions.
+
+// NewMaterializedView returns the table of a materialized view, definition is the CREATE MATERIALIZED VIEW statement
+func NewMaterializedView(name GoSqlIdentifier, columns []GoSqlColumn, definition string) *GoSqlTable {
+	res := NewTable(name, columns)
+	res.Definition = definition
+	return res
+}
+
+// IsMaterializedView is true, if the tuples of the table are computed by a select
+func (t *GoSqlTable) IsMaterializedView() bool {
+	return t.Definition != ""
+}
+
+// Refresh replaces the tuples of the materialized view by tuples, computed by the statement. Columns added to the
+// tables read after the view got created are left out.
+func (t *GoSqlTable) Refresh(baseData *StatementBaseData, tuples [][]driver.Value, concurrently bool) error {
+	conn := baseData.Conn
+	err := LockTableName(conn, t.SchemaName, t.TableName)
+	if err != nil {
+		return err
+	}
+	width := len(t.TableColumns)
+	for ix, values := range tuples {
+		if len(values) < width {
+			return fmt.Errorf("materialized view %s has got %d columns, its select returns %d", t.TableName, width, len(values))
+		}
+		tuples[ix] = values[:width]
+	}
+	// the number of tuples having the values, which are computed again
+	computed := make(map[string]int)
+	if concurrently {
+		for _, values := range tuples {
+			computed[ValuesKey(values)]++
+		}
+	}
+	it := t.NewIterator(baseData, true).(*GoSqlTableIterator)
+	defer it.Close()
+	if it.err == nil {
+		it.setSnapShot(conn.Database.GetSnapShot(conn.Transaction))
+	}
+	for {
+		tuple, ok, err := it.Next(func(Tuple) (bool, error) { return true, nil })
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		values := make([]driver.Value, tuple.DataLen())
+		for ix := range values {
+			values[ix] = tuple.SafeData(0, ix)
+		}
+		if key := ValuesKey(values); computed[key] > 0 {
+			computed[key]-- // kept
+			continue
+		}
+		t.Delete(tuple.Id(), conn)
+	}
+	for _, values := range tuples {
+		if concurrently {
+			key := ValuesKey(values)
+			if computed[key] == 0 {
+				continue // kept
+			}
+			computed[key]--
+		}
+		_, err = t.InsertChecked(values, conn)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package data
+
+import (
+	"slices"
+)
+
+// Row locks as done by postgres. The exclusive ones (FOR UPDATE, FOR NO KEY UPDATE) are kept in xmax of the latest
+// version with FOR_UPDATE_FLAG set, like the change of a tuple, so only one transaction can hold them.
+// KEY_UNCHANGED_FLAG marks locks and changes by xmax, which leave the key of the tuple as it is.
+// The share locks (FOR SHARE, FOR KEY SHARE) can be held by several transactions at once, they are kept in
+// the list of locks of the VersionedTuple and are released, when their transaction ends.
+
+type LockStrength int8
+
+const (
+	LOCK_FOR_UPDATE        LockStrength = iota // e.g. delete
+	LOCK_FOR_NO_KEY_UPDATE                     // e.g. update not changing the key
+	LOCK_FOR_SHARE
+	LOCK_FOR_KEY_SHARE
+)
+
+type tupleLock struct {
+	xid      int64
+	strength LockStrength
+}
+
+func (s LockStrength) isShared() bool {
+	return s == LOCK_FOR_SHARE || s == LOCK_FOR_KEY_SHARE
+}
+
+// the conflict table of postgres
+func (s LockStrength) conflictsWith(other LockStrength) bool {
+	switch s {
+	case LOCK_FOR_UPDATE:
+		return true
+	case LOCK_FOR_NO_KEY_UPDATE:
+		return other != LOCK_FOR_KEY_SHARE
+	case LOCK_FOR_SHARE:
+		return other == LOCK_FOR_UPDATE || other == LOCK_FOR_NO_KEY_UPDATE
+	default:
+		return other == LOCK_FOR_UPDATE
+	}
+}
+
+// the strength of the lock xid holds by xmax, an inserting transaction holds the tuple exclusively
+func (tuple *VersionedTuple) xmaxLockStrength(xid int64) LockStrength {
+	for ix := len(tuple.Versions) - 1; ix >= 0; ix-- {
+		version := &tuple.Versions[ix]
+		if version.xmax == xid && version.xmin != xid {
+			if version.flags&KEY_UNCHANGED_FLAG != 0 {
+				return LOCK_FOR_NO_KEY_UPDATE
+			}
+			return LOCK_FOR_UPDATE
+		}
+	}
+	return LOCK_FOR_UPDATE
+}
+
+// true if xid created, updated or deleted a version, not only locked it
+func (tuple *VersionedTuple) changedBy(xid int64) bool {
+	for ix := range tuple.Versions {
+		version := &tuple.Versions[ix]
+		if version.xmin == xid || version.xmax == xid && version.flags&FOR_UPDATE_FLAG == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// a running transaction other than xid holding a share lock conflicting with strength, NO_TRANSACTION if none
+func (tuple *VersionedTuple) conflictingShareLock(db *Database, xid int64, strength LockStrength) int64 {
+	for _, l := range tuple.locks {
+		if l.xid != xid && strength.conflictsWith(l.strength) && db.isRunningTransaction(l.xid) {
+			return l.xid
+		}
+	}
+	return NO_TRANSACTION
+}
+
+// returns false, if xid already holds a lock at least as strong
+func (tuple *VersionedTuple) addShareLock(db *Database, xid int64, strength LockStrength) bool {
+	// locks of ended transactions are released
+	tuple.locks = slices.DeleteFunc(tuple.locks, func(l tupleLock) bool { return l.xid != xid && !db.isRunningTransaction(l.xid) })
+	if slices.ContainsFunc(tuple.locks, func(l tupleLock) bool { return l.xid == xid && l.strength <= strength }) {
+		return false
+	}
+	tuple.locks = append(tuple.locks, tupleLock{xid, strength})
+	return true
+}
+
+func (tuple *VersionedTuple) removeShareLock(xid int64) {
+	for ix := len(tuple.locks) - 1; ix >= 0; ix-- {
+		if tuple.locks[ix].xid == xid {
+			tuple.locks = slices.Delete(tuple.locks, ix, ix+1)
+			return
+		}
+	}
+}
+
+// locks the tuple for the transaction of the iterator, version is the version visible for it
+func (ti *GoSqlTableIterator) lock(tuple *VersionedTuple, version *TupleVersion) Tuple {
+	xid := ti.Transaction.Xid
+	if ti.lockStrength.isShared() {
+		if tuple.addShareLock(ti.db, xid, ti.lockStrength) {
+			ti.Transaction.recordShareLockUndo(tuple)
+		}
+		return NewSliceTuple(tuple.id, ti.table.shaped(version.Data))
+	}
+	ti.Transaction.recordUndo(tuple, false, true)
+	latest := &tuple.Versions[len(tuple.Versions)-1]
+	// a lock held by the transaction itself does not get weaker
+	if ti.lockStrength == LOCK_FOR_NO_KEY_UPDATE && (latest.xmax != xid || latest.flags&KEY_UNCHANGED_FLAG != 0) {
+		latest.flags |= KEY_UNCHANGED_FLAG
+	} else {
+		latest.flags &= ^KEY_UNCHANGED_FLAG
+	}
+	latest.flags |= FOR_UPDATE_FLAG
+	latest.xmax = xid
+	return NewSliceTuple(tuple.id, ti.table.shaped(latest.Data))
+}
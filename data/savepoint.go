@@ -0,0 +1,135 @@
+package data
+
+import (
+	"fmt"
+)
+
+// Savepoints are based on the command id: a savepoint remembers Transaction.Cid, all changes done afterwards
+// carry a cid >= that. While savepoints exist, the transaction records the headers of the versions it changes,
+// so rolling back to a savepoint can remove the versions created and restore xmax, flags and cid of the versions
+// deleted, updated or locked by commands after it.
+//
+// A changed header is always the one of the latest version of the tuple. It is found again as the latest version,
+// once the versions created after it are removed: the entries are undone in reverse order and vacuum only removes
+// versions in front of it, so no index into the versions is kept.
+
+type savepoint struct {
+	name        string
+	cid         int32
+	changeCount int64 // changes written to the write-ahead log before the savepoint
+}
+
+type undoEntry struct {
+	tuple     *VersionedTuple
+	cid       int32
+	created   bool  // a version got appended to the tuple
+	changed   bool  // the header of the latest version got changed
+	xmax      int64 // of the version, the xid of the transaction in case of a share lock
+	flags     int32
+	headerCid int32
+	shareLock bool // a share lock of the transaction got added
+}
+
+// records a change of a tuple, must be called before the change while holding the lock of the tuple
+func (t *Transaction) recordUndo(tuple *VersionedTuple, created bool, changed bool) {
+	if len(t.savepoints) == 0 {
+		return
+	}
+	entry := undoEntry{tuple: tuple, cid: t.Cid, created: created, changed: changed}
+	if changed {
+		version := &tuple.Versions[len(tuple.Versions)-1]
+		entry.xmax = version.xmax
+		entry.flags = version.flags
+		entry.headerCid = version.cid
+	}
+	t.undo = append(t.undo, entry)
+}
+
+func (t *Transaction) recordShareLockUndo(tuple *VersionedTuple) {
+	if len(t.savepoints) == 0 {
+		return
+	}
+	t.undo = append(t.undo, undoEntry{tuple: tuple, cid: t.Cid, xmax: t.Xid, shareLock: true})
+}
+
+func (t *Transaction) findSavepoint(name string) (int, error) {
+	for ix := len(t.savepoints) - 1; ix >= 0; ix-- {
+		if t.savepoints[ix].name == name {
+			return ix, nil
+		}
+	}
+	return -1, fmt.Errorf("savepoint %s does not exist", name)
+}
+
+// Savepoint creates a savepoint, an existing one with the same name gets hidden until released
+func (t *Transaction) Savepoint(name string) error {
+	if t.State != INITED && t.State != STARTED && t.State != ROLLBACKONLY {
+		return fmt.Errorf("savepoint %s can not be created in transaction %d in state %d", name, t.Xid, t.State)
+	}
+	t.savepoints = append(t.savepoints, savepoint{name, t.Cid, t.ChangeCount})
+	return nil
+}
+
+// ReleaseSavepoint removes the savepoint and all created after it, the changes done are kept
+func (t *Transaction) ReleaseSavepoint(name string) error {
+	ix, err := t.findSavepoint(name)
+	if err != nil {
+		return err
+	}
+	t.savepoints = t.savepoints[:ix]
+	if len(t.savepoints) == 0 {
+		t.undo = nil
+	}
+	return nil
+}
+
+// RollbackToSavepoint undoes all changes done after the savepoint was created, the savepoint is kept.
+func (t *Transaction) RollbackToSavepoint(name string) error {
+	ix, err := t.findSavepoint(name)
+	if err != nil {
+		return err
+	}
+	sp := t.savepoints[ix]
+	t.savepoints = t.savepoints[:ix+1]
+	for len(t.undo) > 0 && t.undo[len(t.undo)-1].cid >= sp.cid {
+		t.undo[len(t.undo)-1].apply()
+		t.undo = t.undo[:len(t.undo)-1]
+	}
+	t.rollbackCatalog(sp.cid)
+	// locks taken after the savepoint are released
+	t.wakeupWaiters()
+	if t.ChangeCount > sp.changeCount {
+		t.ChangeCount = sp.changeCount
+		logRollbackToSavepoint(t, sp.changeCount)
+	}
+	// the command id is not reused, following commands see the state of the savepoint
+	t.Cid++
+	return nil
+}
+
+func (e *undoEntry) apply() {
+	e.tuple.mu.Lock()
+	defer e.tuple.mu.Unlock()
+	if e.shareLock {
+		e.tuple.removeShareLock(e.xmax)
+		return
+	}
+	if e.created {
+		if len(e.tuple.Versions) == 1 {
+			// iterators might be looking at the inserted tuple, so it is kept as deleted by the transaction
+			// itself, until vacuum removes it
+			version := &e.tuple.Versions[0]
+			version.xmax = version.xmin
+			version.flags = 0
+			version.cid = e.cid
+			return
+		}
+		e.tuple.Versions = e.tuple.Versions[:len(e.tuple.Versions)-1]
+	}
+	if e.changed {
+		version := &e.tuple.Versions[len(e.tuple.Versions)-1]
+		version.xmax = e.xmax
+		version.flags = e.flags
+		version.cid = e.headerCid
+	}
+}
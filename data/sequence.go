@@ -0,0 +1,242 @@
+package data
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// A sequence generates integers, like in postgres. It is created and dropped transactionally like a table, but its
+// value is not: a value returned by nextval is never returned again, even if the transaction rolls back, unless the
+// sequence cycles. setval changes the value for all sessions at once. Read like a table it returns one tuple
+// holding last_value and is_called.
+// The autoincrement of a PRIMARY KEY AUTOINCREMENT column is a sequence owned by the column, it gets dropped with the
+// table and can not be dropped by itself.
+
+type Sequence struct {
+	BaseTable
+	Start     int64
+	Increment int64
+	MinValue  int64
+	MaxValue  int64
+	Cycle     bool
+	value     atomic.Pointer[sequenceValue]
+	mu        sync.Mutex  // serializes nextval and setval
+	owner     *GoSqlTable // of the column owning the sequence, nil if none
+	createdBy int64       // xid of the transaction creating the sequence
+}
+
+type sequenceValue struct {
+	last   int64
+	called bool // false, if nextval returns last next
+}
+
+// NewSequence returns the sequence schema.name, columns are the ones it is read with, last_value and is_called
+func NewSequence(schema string, name string, columns []GoSqlColumn, start int64, increment int64, minValue int64,
+	maxValue int64, cycle bool) (*Sequence, error) {
+	if increment == 0 {
+		return nil, fmt.Errorf("INCREMENT of sequence %s must not be zero", name)
+	}
+	if minValue >= maxValue {
+		return nil, fmt.Errorf("MINVALUE (%d) of sequence %s must be less than MAXVALUE (%d)", minValue, name, maxValue)
+	}
+	if start < minValue || start > maxValue {
+		return nil, fmt.Errorf("START value (%d) of sequence %s must be between MINVALUE (%d) and MAXVALUE (%d)",
+			start, name, minValue, maxValue)
+	}
+	res := &Sequence{BaseTable{schema, name, columns}, start, increment, minValue, maxValue, cycle,
+		atomic.Pointer[sequenceValue]{}, sync.Mutex{}, nil, NO_TRANSACTION}
+	res.value.Store(&sequenceValue{start, false})
+	return res, nil
+}
+
+// NextValue advances the sequence and returns its new value, which becomes the one of currval for the session
+// of conn.
+func (s *Sequence) NextValue(conn *GoSqlConnData) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	act := s.value.Load()
+	next := act.last
+	if act.called {
+		if s.Increment > 0 && next > s.MaxValue-s.Increment {
+			if !s.Cycle {
+				return 0, fmt.Errorf("nextval: reached maximum value of sequence %s (%d)", s.TableName, s.MaxValue)
+			}
+			next = s.MinValue
+		} else if s.Increment < 0 && next < s.MinValue-s.Increment {
+			if !s.Cycle {
+				return 0, fmt.Errorf("nextval: reached minimum value of sequence %s (%d)", s.TableName, s.MinValue)
+			}
+			next = s.MaxValue
+		} else {
+			next += s.Increment
+		}
+	}
+	s.store(conn, &sequenceValue{next, true})
+	if conn.SequenceValues == nil {
+		conn.SequenceValues = make(map[*Sequence]int64)
+	}
+	conn.SequenceValues[s] = next
+	return next, nil
+}
+
+// CurrentValue returns the value nextval returned last for the session of conn
+func (s *Sequence) CurrentValue(conn *GoSqlConnData) (int64, error) {
+	value, ok := conn.SequenceValues[s]
+	if !ok {
+		return 0, fmt.Errorf("currval of sequence %s is not yet defined in this session", s.TableName)
+	}
+	return value, nil
+}
+
+// SetValue sets the value of the sequence, nextval returns value if called is false, otherwise the one following it
+func (s *Sequence) SetValue(conn *GoSqlConnData, value int64, called bool) error {
+	if value < s.MinValue || value > s.MaxValue {
+		return fmt.Errorf("setval: value %d is out of bounds for sequence %s (%d..%d)", value, s.TableName,
+			s.MinValue, s.MaxValue)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.store(conn, &sequenceValue{value, called})
+	return nil
+}
+
+// logged while holding mu, so the log keeps the order of the changes
+func (s *Sequence) store(conn *GoSqlConnData, value *sequenceValue) {
+	s.value.Store(value)
+	if w := conn.Database.wal.Load(); w != nil {
+		// the value of a sequence not committed yet is replayed, if its creation is
+		xid := NO_TRANSACTION
+		if tra := conn.Transaction; tra != nil && tra.isRunning() && tra.Xid == s.createdBy {
+			xid = tra.Xid
+		}
+		w.append(s.walRecord(xid, value))
+	}
+}
+
+func (s *Sequence) walRecord(xid int64, value *sequenceValue) *walRecord {
+	return &walRecord{kind: walSequence, xid: xid, schema: s.SchemaName, table: s.TableName, counter: value.last,
+		called: value.called}
+}
+
+func (s *Sequence) Data() *[][]driver.Value {
+	return nil
+}
+
+// NewIterator iterates over the single tuple of the sequence. It can not be changed or locked, an iterator for that
+// fails.
+func (s *Sequence) NewIterator(baseData *StatementBaseData, forChange bool) TableIterator {
+	if forChange {
+		return &readOnlyIterator{s}
+	}
+	value := s.value.Load()
+	res := TempTableIterator{&TempTable{s.BaseTable, [][]driver.Value{{value.last, value.called}}}, 0}
+	return &res
+}
+
+func (s *Sequence) Insert(recordValues []driver.Value, conn *GoSqlConnData) int64 {
+	panic("not implemented")
+}
+
+func (s *Sequence) Update(recordId int64, recordValues Tuple, conn *GoSqlConnData) bool {
+	panic("not implemented")
+}
+
+func (s *Sequence) Delete(recordId int64, conn *GoSqlConnData) bool {
+	panic("not implemented")
+}
+
+// Owner returns the table of the column owning the sequence, nil if none does
+func (s *Sequence) Owner() *GoSqlTable {
+	if s.owner == nil || s.owner.sequenceColumn(s) == "" {
+		return nil
+	}
+	return s.owner
+}
+
+// FindSequence returns the sequence named like the argument of nextval, currval and setval: its name, optionally
+// qualified by its schema, is folded to lower case unless quoted, like an identifier of sql.
+func FindSequence(conn *GoSqlConnData, name string) (*Sequence, error) {
+	parts := strings.Split(name, ".")
+	for ix, part := range parts {
+		if len(part) > 1 && strings.HasPrefix(part, `"`) && strings.HasSuffix(part, `"`) {
+			parts[ix] = part[1 : len(part)-1]
+		} else {
+			parts[ix] = strings.ToLower(part)
+		}
+	}
+	if len(parts) <= 2 {
+		schema, table := TableSchema(conn, GoSqlIdentifier{parts})
+		if s, ok := lookupTable(conn, schema, table).(*Sequence); ok {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("sequence %s does not exist", name)
+}
+
+// NewAutoincrementSequence returns the sequence counting 1, 2, ... to be owned by an autoincrement column
+func NewAutoincrementSequence(schema string, name string, columns []GoSqlColumn) *Sequence {
+	res, _ := NewSequence(schema, name, columns, 1, 1, 1, math.MaxInt64, false)
+	return res
+}
+
+// OwnSequence makes the sequence the one of the autoincrement column, before the table gets created
+func (t *GoSqlTable) OwnSequence(column string, s *Sequence) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sequences[column] = s
+	s.owner = t
+}
+
+// ColumnSequence returns the sequence owned by the column, nil if it has none
+func (t *GoSqlTable) ColumnSequence(column string) *Sequence {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.sequences[column]
+}
+
+// the column owning the sequence, empty if none does
+func (t *GoSqlTable) sequenceColumn(s *Sequence) string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for column, owned := range t.sequences {
+		if owned == s {
+			return column
+		}
+	}
+	return ""
+}
+
+// the sequences owned by the columns of the table
+func (t *GoSqlTable) ownedSequences() []*Sequence {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	res := make([]*Sequence, 0, len(t.sequences))
+	for _, s := range t.sequences {
+		res = append(res, s)
+	}
+	return res
+}
+
+// CreateSequence adds the sequence to its schema, the transaction of conn sees it from now on, the others after it
+// committed. The caller holds the name of the sequence exclusively.
+func CreateSequence(conn *GoSqlConnData, s *Sequence) {
+	tra := conn.Transaction
+	s.createdBy = tra.Xid
+	tra.changeCatalog(catalogChange{tra.Cid, s.SchemaName, s.TableName, s, false})
+}
+
+// DropSequence removes the sequence from its schema, the other transactions see it until the one of conn committed.
+// The caller holds the name of the sequence exclusively. A sequence owned by a column can not be dropped.
+func DropSequence(conn *GoSqlConnData, s *Sequence) error {
+	if owner := s.Owner(); owner != nil {
+		return fmt.Errorf("cannot drop sequence %s, column %s of table %s requires it", s.TableName,
+			owner.sequenceColumn(s), owner.TableName)
+	}
+	tra := conn.Transaction
+	tra.changeCatalog(catalogChange{tra.Cid, s.SchemaName, s.TableName, nil, true})
+	return nil
+}
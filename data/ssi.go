@@ -0,0 +1,164 @@
+package data
+
+import (
+	"slices"
+	"sync"
+)
+
+// Serializable snapshot isolation as done by postgres: transactions running SERIALIZABLE keep track of the
+// tables they read (relation level SIREAD locks, since all reads are sequential scans) and the tables they write.
+// If a transaction R reads a table written by a concurrent transaction W, R did not see the changes of W, so
+// R must be serialized before W: there is a rw-antidependency R -> W.
+// A dangerous structure T_in -> pivot -> T_out, where T_out commits first, might not be serializable. It is
+// checked at commit: the pivot or T_in, whichever commits last, is rolled back and gets ErrTraSerialization.
+
+type ssiTransaction struct {
+	tra       *Transaction
+	reads     map[*GoSqlTable]bool
+	writes    map[*GoSqlTable]bool
+	in        map[*ssiTransaction]bool // concurrent transactions, which read what this one wrote
+	out       map[*ssiTransaction]bool // concurrent transactions, which wrote what this one read
+	commitSeq int64                    // order of commit, 0 as long as not committing
+}
+
+type ssiManager struct {
+	transactions map[int64]*ssiTransaction
+	commitSeq    int64
+	mu           sync.Mutex
+}
+
+func newSsiManager() *ssiManager {
+	return &ssiManager{transactions: make(map[int64]*ssiTransaction)}
+}
+
+// true if changes of xid are visible for the snapshot, nil means the snapshot is not yet taken
+func visibleIn(s *SnapShot, xid int64) bool {
+	return s != nil && xid < s.xmax && !slices.Contains(s.runningXids, xid)
+}
+
+func (e *ssiTransaction) concurrentTo(other *ssiTransaction) bool {
+	return !visibleIn(e.tra.SnapShot, other.tra.Xid) && !visibleIn(other.tra.SnapShot, e.tra.Xid)
+}
+
+// called before the snapshot of the transaction is taken
+func ssiStart(t *Transaction) {
+	if t.IsolationLevel != SERIALIZABLE {
+		return
+	}
+	m := t.Conn.Database.transactionManager.ssi
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.transactions[t.Xid] = &ssiTransaction{tra: t, reads: make(map[*GoSqlTable]bool), writes: make(map[*GoSqlTable]bool),
+		in: make(map[*ssiTransaction]bool), out: make(map[*ssiTransaction]bool)}
+}
+
+func ssiRead(t *Transaction, table *GoSqlTable) {
+	if t == nil || t.IsolationLevel != SERIALIZABLE {
+		return
+	}
+	m := t.Conn.Database.transactionManager.ssi
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	reader, ok := m.transactions[t.Xid]
+	if !ok || reader.reads[table] {
+		return
+	}
+	reader.reads[table] = true
+	for _, writer := range m.transactions {
+		if writer != reader && writer.writes[table] && reader.concurrentTo(writer) {
+			rwConflict(reader, writer)
+		}
+	}
+}
+
+func ssiWrite(t *Transaction, table *GoSqlTable) {
+	if t == nil || t.IsolationLevel != SERIALIZABLE {
+		return
+	}
+	m := t.Conn.Database.transactionManager.ssi
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	writer, ok := m.transactions[t.Xid]
+	if !ok || writer.writes[table] {
+		return
+	}
+	writer.writes[table] = true
+	for _, reader := range m.transactions {
+		if reader != writer && reader.reads[table] && writer.concurrentTo(reader) {
+			rwConflict(reader, writer)
+		}
+	}
+}
+
+func rwConflict(reader *ssiTransaction, writer *ssiTransaction) {
+	reader.out[writer] = true
+	writer.in[reader] = true
+}
+
+func committedBefore(e *ssiTransaction, other *ssiTransaction) bool {
+	return e.commitSeq != 0 && (other.commitSeq == 0 || e.commitSeq < other.commitSeq)
+}
+
+// returns ErrTraSerialization if the transaction has to be rolled back instead of being committed
+func ssiPrepareCommit(t *Transaction) error {
+	if t.IsolationLevel != SERIALIZABLE {
+		return nil
+	}
+	m := t.Conn.Database.transactionManager.ssi
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.transactions[t.Xid]
+	if !ok {
+		return nil
+	}
+	for tOut := range e.out {
+		// e is the pivot
+		if len(e.in) > 0 && committedBefore(tOut, e) {
+			return ErrTraSerialization
+		}
+		// e is T_in, tOut the pivot
+		for pivotOut := range tOut.out {
+			if tOut.commitSeq != 0 && committedBefore(pivotOut, tOut) {
+				return ErrTraSerialization
+			}
+		}
+	}
+	m.commitSeq++
+	e.commitSeq = m.commitSeq
+	return nil
+}
+
+// called after the state of the transaction got committed or rolled back. Rolled back transactions are
+// forgotten, committed ones as soon as all running serializable transactions see their changes.
+func ssiEnd(t *Transaction) {
+	if t.IsolationLevel != SERIALIZABLE {
+		return
+	}
+	m := t.Conn.Database.transactionManager.ssi
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.transactions[t.Xid]; ok && t.State != COMMITTED {
+		for reader := range e.in {
+			delete(reader.out, e)
+		}
+		for writer := range e.out {
+			delete(writer.in, e)
+		}
+		delete(m.transactions, t.Xid)
+	}
+	for xid, committed := range m.transactions {
+		if committed.tra.State != COMMITTED {
+			continue
+		}
+		seenByAll := true
+		for _, running := range m.transactions {
+			if running.tra.State != COMMITTED && !visibleIn(running.tra.SnapShot, xid) {
+				seenByAll = false
+				break
+			}
+		}
+		if seenByAll {
+			delete(m.transactions, xid)
+		}
+	}
+}
@@ -13,12 +13,9 @@ type transactionManagerType struct {
 	lowestRunningXid atomic.Int64
 	transactions     map[int64]*Transaction
 	mu               sync.RWMutex
-}
-
-var transactionManager = NewTransactionManager()
-
-func InitTransactionManager() {
-	transactionManager = NewTransactionManager()
+	ssi              *ssiManager
+	waitsFor         map[int64]int64 // wait-for graph: xid of a waiting transaction -> xid of the lock holder
+	waitsForMu       sync.Mutex
 }
 
 func NewTransactionManager() *transactionManagerType {
@@ -27,11 +24,24 @@ func NewTransactionManager() *transactionManagerType {
 		atomic.Int64{},
 		make(map[int64]*Transaction),
 		sync.RWMutex{},
+		newSsiManager(),
+		make(map[int64]int64),
+		sync.Mutex{},
 	}
 	res.nextXid.Add(1)
 	return &res
 }
 
+// after recovery, xids must not collide with xids found in the write-ahead log
+func (m *transactionManagerType) continueXidsBehind(xid int64) {
+	for {
+		next := m.nextXid.Load()
+		if next > xid || m.nextXid.CompareAndSwap(next, xid+1) {
+			return
+		}
+	}
+}
+
 // called during any change after transaction begin (or after last commit/rollback in case of autocommit)
 func StartTransaction(c *GoSqlConnData) error {
 	if c.Transaction != nil {
@@ -39,7 +49,9 @@ func StartTransaction(c *GoSqlConnData) error {
 			return nil
 		}
 	}
-	InitTransaction(c)
+	if c.Transaction == nil || c.Transaction.State != INITED {
+		InitTransaction(c)
+	}
 	t, err := startTransactionInternal(c.Transaction)
 	if err != nil {
 		return err
@@ -50,7 +62,7 @@ func StartTransaction(c *GoSqlConnData) error {
 }
 
 func InitTransaction(conn *GoSqlConnData) {
-	conn.Transaction = &Transaction{NO_TRANSACTION, 0, 0, 0, 0, 1000, nil, INITED, conn.DefaultIsolationLevel, conn}
+	conn.Transaction = &Transaction{NO_TRANSACTION, 0, 0, 0, 0, conn.LockTimeoutInMs, nil, INITED, conn.DefaultIsolationLevel, conn, nil, nil, nil, nil, nil, nil}
 }
 
 func (t *Transaction) IsStarted() bool {
@@ -64,8 +76,10 @@ func startTransactionInternal(t *Transaction) (*Transaction, error) {
 		return nil, fmt.Errorf("trying to restart transaction %d", t.Xid)
 	}
 	if t.State == ROLLEDBACK || t.State == COMMITTED {
-		t = &Transaction{NO_TRANSACTION, 0, 0, 0, 0, 1000, nil, INITED, t.IsolationLevel, t.Conn}
+		t = &Transaction{NO_TRANSACTION, 0, 0, 0, 0, t.MaxLockTimeInMs, nil, INITED, t.IsolationLevel, t.Conn, nil, nil, nil, nil, nil, nil}
 	}
+	db := t.Conn.Database
+	transactionManager := db.transactionManager
 	var xid int64
 	for {
 		xid = transactionManager.nextXid.Load()
@@ -79,12 +93,15 @@ func startTransactionInternal(t *Transaction) (*Transaction, error) {
 	t.Xid = xid
 	t.Started = time.Now().UnixNano()
 	t.State = STARTED
-	if t.IsolationLevel == REPEATABLE_READ || t.IsolationLevel == SERIALIZABLE {
-		t.SnapShot = GetSnapShot(t)
-	}
+	t.lockWait = newLockWait()
 	transactionManager.mu.Lock()
-	defer transactionManager.mu.Unlock()
 	transactionManager.transactions[xid] = t
+	transactionManager.mu.Unlock()
+	if t.IsolationLevel == REPEATABLE_READ || t.IsolationLevel == SERIALIZABLE {
+		// the snapshot expects the transaction itself to be registered already
+		ssiStart(t)
+		t.SnapShot = db.GetSnapShot(t)
+	}
 	return t, nil
 }
 
@@ -103,6 +120,15 @@ func EndStatement(baseData *StatementBaseData) error {
 	}
 }
 
+// AbortStatement is called, when a statement failed after it might have changed tuples. In autocommit mode
+// its changes are rolled back, otherwise the transaction is left to the application.
+func AbortStatement(baseData *StatementBaseData) {
+	transaction := baseData.Conn.Transaction
+	if transaction != nil && baseData.Conn.DoAutoCommit && transaction.isRunning() {
+		EndTransaction(baseData.Conn, ROLLEDBACK)
+	}
+}
+
 func EndTransaction(conn *GoSqlConnData, newState TransactionState) error {
 	transaction := conn.Transaction
 	if transaction == nil {
@@ -118,8 +144,23 @@ func EndTransaction(conn *GoSqlConnData, newState TransactionState) error {
 	if rollbackInsteadOfCommit {
 		newState = ROLLEDBACK
 	}
+	var ssiErr error
+	if newState == COMMITTED {
+		ssiErr = ssiPrepareCommit(transaction)
+		if ssiErr != nil {
+			newState = ROLLEDBACK
+		}
+	}
+	walErr := logEndTransaction(transaction, newState)
+	if walErr != nil && newState == COMMITTED {
+		newState = ROLLEDBACK
+	}
+	transaction.endCatalog(newState == COMMITTED)
 	transaction.Ended = time.Now().UnixNano()
 	transaction.State = newState
+	transaction.wakeupWaiters()
+	ssiEnd(transaction)
+	transactionManager := conn.Database.transactionManager
 	if transactionManager.lowestRunningXid.Load() == transaction.Xid {
 		transactionManager.mu.Lock()
 		defer transactionManager.mu.Unlock()
@@ -147,6 +188,12 @@ func EndTransaction(conn *GoSqlConnData, newState TransactionState) error {
 		}
 	}
 	conn.Transaction = nil
+	if ssiErr != nil {
+		return ssiErr
+	}
+	if walErr != nil {
+		return fmt.Errorf("transaction %d ended as %d, write-ahead log: %w", transaction.Xid, newState, walErr)
+	}
 	if rollbackInsteadOfCommit {
 		return fmt.Errorf("rolled back transaction because of rollback only")
 	} else {
@@ -159,6 +206,9 @@ func (t *Transaction) Commit() error {
 }
 
 func (t *Transaction) Rollback() error {
+	if t.State == ROLLEDBACK {
+		return nil // e.g. already rolled back as victim of a deadlock
+	}
 	return EndTransaction(t.Conn, ROLLEDBACK)
 }
 
@@ -170,7 +220,8 @@ func (t *Transaction) SetRollbackOnly() error {
 	return nil
 }
 
-func GetTransaction(xid int64) (*Transaction, error) {
+func (db *Database) GetTransaction(xid int64) (*Transaction, error) {
+	transactionManager := db.transactionManager
 	transactionManager.mu.RLock()
 	defer transactionManager.mu.RUnlock()
 	res, ok := transactionManager.transactions[xid]
@@ -181,7 +232,8 @@ func GetTransaction(xid int64) (*Transaction, error) {
 	}
 }
 
-func GetSnapShot(transaction *Transaction) *SnapShot {
+func (db *Database) GetSnapShot(transaction *Transaction) *SnapShot {
+	transactionManager := db.transactionManager
 	transactionManager.mu.RLock()
 	defer transactionManager.mu.RUnlock()
 	xmin := transactionManager.lowestRunningXid.Load()
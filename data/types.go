@@ -1,6 +1,9 @@
 package data
 
-import "database/sql/driver"
+import (
+	"context"
+	"database/sql/driver"
+)
 
 type TransactionState int8
 
@@ -34,6 +37,12 @@ type Transaction struct {
 	State           TransactionState
 	IsolationLevel  TransactionIsolationLevel
 	Conn            *GoSqlConnData
+	walErr          error // the first change the write-ahead log could not record, the transaction cannot commit
+	savepoints      []savepoint
+	undo            []undoEntry     // changes done while savepoints exist
+	lockWait        *lockWait       // woken when locks of the transaction get released
+	catalog         []catalogChange // tables created and dropped, seen by others after commit
+	tableLocks      []string        // the keys of the table locks held
 }
 
 type SnapShot struct {
@@ -49,7 +58,12 @@ type GoSqlConnData struct {
 	Transaction           *Transaction
 	DoAutoCommit          bool
 	DefaultIsolationLevel TransactionIsolationLevel
-	CurrentSchema         string
+	CurrentSchema         string   // the tables named without schema are created in
+	SearchPath            []string // further schemas searched for tables named without schema
+	LockTimeoutInMs       int64
+	Ctx                   context.Context     // of the statement currently executed, nil if there is none
+	Database              *Database           // the connection works on
+	SequenceValues        map[*Sequence]int64 // returned by nextval in the session, the values of currval
 }
 
 type StatementInterface interface {
@@ -66,9 +80,13 @@ func (s *BaseStatement) BaseData() *StatementBaseData {
 }
 
 type StatementBaseData struct {
-	Conn     *GoSqlConnData
-	SnapShot *SnapShot
-	State    StmtState
+	Conn         *GoSqlConnData
+	SnapShot     *SnapShot
+	State        StmtState
+	Sql          string
+	LockStrength LockStrength   // of the locks on the tuples changed or selected for update
+	LockWait     LockWaitPolicy // when the statement meets tuples locked by other transactions
+	Views        []*View        // being expanded, the statement executes the select of the last one
 }
 
 func (r *StatementBaseData) NumInput() int {
@@ -99,7 +117,7 @@ const (
 )
 
 func NewStatementBaseData() BaseStatement {
-	return BaseStatement{StatementBaseData{nil, nil, Created}}
+	return BaseStatement{StatementBaseData{nil, nil, Created, "", LOCK_FOR_UPDATE, LOCK_WAIT, nil}}
 }
 
 type GoSqlIdentifier struct {
@@ -114,29 +132,43 @@ func (i GoSqlIdentifier) Name() string {
 	return res
 }
 
-func GetTable(stmt BaseStatement, id GoSqlIdentifier) (Table, bool) {
-	if len(id.Parts) == 1 {
-		res := Schemas[stmt.Conn.CurrentSchema][id.Parts[0]]
-		return res, res != nil
-	} else if len(id.Parts) == 2 {
-		if Schemas[id.Parts[0]] == nil {
-			return nil, false
-		} else {
-			res := Schemas[id.Parts[0]][id.Parts[1]]
-			return res, res != nil
+// SearchedSchemas returns the schemas searched for tables named without schema, the current schema first
+func (c *GoSqlConnData) SearchedSchemas() []string {
+	return append([]string{c.CurrentSchema}, c.SearchPath...)
+}
+
+// TableSchema returns the schema and the name of the table identified. A table named without schema is searched
+// in the schemas of the search path, the current schema is returned if none of them contains it.
+func TableSchema(conn *GoSqlConnData, id GoSqlIdentifier) (string, string) {
+	if len(id.Parts) > 1 {
+		return id.Parts[0], id.Parts[1]
+	}
+	for _, schema := range conn.SearchedSchemas() {
+		if lookupTable(conn, schema, id.Parts[0]) != nil {
+			return schema, id.Parts[0]
 		}
-	} else {
-		return nil, false
 	}
+	return conn.CurrentSchema, id.Parts[0]
 }
 
-func GetTempTable(name string) Table {
-	if Schemas[tempTableSchemaName] == nil {
-		return nil
+// GetTable returns the table as seen by the transaction of the statement
+func GetTable(stmt BaseStatement, id GoSqlIdentifier) (Table, bool) {
+	if len(id.Parts) > 2 {
+		return nil, false
 	}
-	return Schemas[tempTableSchemaName][name]
+	schema, name := TableSchema(stmt.Conn, id)
+	res := lookupTable(stmt.Conn, schema, name)
+	return res, res != nil
+}
+
+func (db *Database) GetTempTable(name string) Table {
+	db.tablesMu.Lock()
+	defer db.tablesMu.Unlock()
+	return db.Schemas[tempTableSchemaName][name]
 }
 
-func DeleteTempTable(name string) {
-	delete(Schemas[tempTableSchemaName], name)
+func (db *Database) DeleteTempTable(name string) {
+	db.tablesMu.Lock()
+	defer db.tablesMu.Unlock()
+	delete(db.Schemas[tempTableSchemaName], name)
 }
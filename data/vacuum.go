@@ -0,0 +1,137 @@
+package data
+
+import (
+	"slices"
+)
+
+// Vacuum removes the TupleVersions of a GoSqlTable, which no snapshot is able to see anymore:
+//   - versions created by rolled back transactions
+//   - versions replaced by a version, whose transaction committed before the horizon
+//   - tuples deleted by a transaction committed before the horizon
+//
+// The horizon is the lowest xid, whose changes might be invisible for a transaction or a statement still
+// running. Any transaction below it has ended and is seen as ended by all snapshots in use.
+
+// AutoVacuumThreshold is the number of updates and deletes of a table, after which it is vacuumed in the
+// background, 0 switches that off.
+var AutoVacuumThreshold = int64(1000)
+
+func snapShotHorizon(s *SnapShot) int64 {
+	if s.xmin != NO_TRANSACTION {
+		return s.xmin
+	}
+	return s.xmax
+}
+
+func vacuumHorizon(t *GoSqlTable) int64 {
+	transactionManager := t.db.transactionManager
+	transactionManager.mu.RLock()
+	horizon := transactionManager.nextXid.Load()
+	lowestRunningXid := transactionManager.lowestRunningXid.Load()
+	if lowestRunningXid != NO_TRANSACTION {
+		horizon = lowestRunningXid
+		for xid := lowestRunningXid; xid < transactionManager.nextXid.Load(); xid++ {
+			tra, ok := transactionManager.transactions[xid]
+			if ok && (tra.State == STARTED || tra.State == ROLLBACKONLY) && tra.SnapShot != nil {
+				horizon = min(horizon, snapShotHorizon(tra.SnapShot))
+			}
+		}
+	}
+	transactionManager.mu.RUnlock()
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for _, it := range t.iterators {
+		if snapShot := it.(*GoSqlTableIterator).SnapShot; snapShot != nil {
+			horizon = min(horizon, snapShotHorizon(snapShot))
+		}
+	}
+	return horizon
+}
+
+func (db *Database) endedAs(xid int64, horizon int64, state TransactionState) bool {
+	if xid == NO_TRANSACTION || xid >= horizon {
+		return false
+	}
+	tra, err := db.GetTransaction(xid)
+	return err == nil && tra.State == state
+}
+
+// VacuumAll vacuums all tables of the database, returns the number of removed versions
+func (db *Database) VacuumAll() int64 {
+	removed := int64(0)
+	for _, t := range db.collectGoSqlTables() {
+		removed += t.Vacuum()
+	}
+	return removed
+}
+
+// Vacuum removes dead versions and tuples of the table, returns the number of removed versions
+func (t *GoSqlTable) Vacuum() int64 {
+	t.changesSinceVacuum.Store(0)
+	horizon := vacuumHorizon(t)
+	t.mu.RLock()
+	tuples := make([]*VersionedTuple, 0, t.data.Size())
+	it := t.data.Iterator()
+	for it.Next() {
+		tuples = append(tuples, it.Value().(*VersionedTuple))
+	}
+	t.mu.RUnlock()
+
+	removed := int64(0)
+	var deadIds []int64
+	for _, tuple := range tuples {
+		tuple.mu.Lock()
+		count, dead := vacuumTuple(t.db, tuple, horizon)
+		if dead {
+			count += len(tuple.Versions)
+			deadIds = append(deadIds, tuple.id)
+		}
+		tuple.mu.Unlock()
+		removed += int64(count)
+	}
+	if len(deadIds) > 0 {
+		// dead tuples are not visible anymore, so they can't be changed meanwhile
+		t.mu.Lock()
+		for _, id := range deadIds {
+			t.data.Remove(id)
+		}
+		t.mu.Unlock()
+	}
+	t.vacuumIndexes()
+	return removed
+}
+
+// removes the dead versions of the tuple, returns their number and if the whole tuple is dead
+func vacuumTuple(db *Database, tuple *VersionedTuple, horizon int64) (int, bool) {
+	removed := 0
+	// versions of rolled back transactions are always the latest ones, one version is kept for
+	// iterators, which are just looking at the tuple
+	for len(tuple.Versions) > 1 && db.endedAs(tuple.Versions[len(tuple.Versions)-1].xmin, horizon, ROLLEDBACK) {
+		tuple.Versions = tuple.Versions[:len(tuple.Versions)-1]
+		removed++
+	}
+	if len(tuple.Versions) == 1 && db.endedAs(tuple.Versions[0].xmin, horizon, ROLLEDBACK) {
+		return removed, true
+	}
+	// the latest version committed before the horizon is visible for all, so the previous ones are not
+	for i := len(tuple.Versions) - 1; i > 0; i-- {
+		if db.endedAs(tuple.Versions[i].xmin, horizon, COMMITTED) {
+			tuple.Versions = slices.Clone(tuple.Versions[i:])
+			removed += i
+			break
+		}
+	}
+	last := &tuple.Versions[len(tuple.Versions)-1]
+	deleted := last.flags&FOR_UPDATE_FLAG == 0 && db.endedAs(last.xmax, horizon, COMMITTED)
+	return removed, deleted
+}
+
+// starts a vacuum in the background, if enough versions might have become dead
+func (t *GoSqlTable) countChange() {
+	if AutoVacuumThreshold > 0 && t.changesSinceVacuum.Add(1) >= AutoVacuumThreshold && t.vacuuming.CompareAndSwap(false, true) {
+		go func() {
+			defer t.vacuuming.Store(false)
+			t.Vacuum()
+		}()
+	}
+}
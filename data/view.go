@@ -0,0 +1,123 @@
+package data
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"slices"
+)
+
+// A view is a select stored in the catalog under a name, like a table and transactionally like it. Its tuples are
+// not stored, the select is executed when a statement expands the view, with the connection of the statement. So
+// the view sees what the statement sees: the snapshot of a REPEATABLE READ or SERIALIZABLE transaction including its
+// own changes, the tuples committed when the view gets read by a READ COMMITTED one.
+// Views can not be changed, the tables they read are not kept from being dropped.
+
+// ViewTuples executes the select of the CREATE VIEW statement definition for the statement baseData, returning the
+// columns and the tuples of the result. Set by the parser, which is able to interpret sql.
+var ViewTuples func(definition string, baseData *StatementBaseData) ([]GoSqlColumn, [][]driver.Value, error)
+
+type View struct {
+	BaseTable
+	Definition string // the CREATE VIEW statement
+}
+
+func NewView(schema string, name string, columns []GoSqlColumn, definition string) *View {
+	return &View{BaseTable{schema, name, columns}, definition}
+}
+
+// Expand executes the select of the view for the statement, the tuples get into a temporary table named like the
+// view, which is not registered. Columns added to the tables read after the view got created are left out.
+func (v *View) Expand(baseData *StatementBaseData) (*TempTable, error) {
+	if slices.Contains(baseData.Views, v) {
+		return nil, fmt.Errorf("infinite recursion detected in view %s", v.TableName)
+	}
+	expanding := StatementBaseData{baseData.Conn, nil, Created, v.Definition, LOCK_FOR_UPDATE, LOCK_WAIT,
+		append(slices.Clone(baseData.Views), v)}
+	columns, tuples, err := ViewTuples(v.Definition, &expanding)
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) < len(v.TableColumns) {
+		return nil, fmt.Errorf("view %s has got %d columns, its select returns %d", v.TableName, len(v.TableColumns), len(columns))
+	}
+	for ix := range tuples {
+		tuples[ix] = tuples[ix][:len(v.TableColumns)]
+	}
+	return &TempTable{v.BaseTable, tuples}, nil
+}
+
+func (v *View) Data() *[][]driver.Value {
+	return nil
+}
+
+// NewIterator iterates over the tuples of the expanded view. The tuples can not be changed or locked, an iterator
+// for that fails.
+func (v *View) NewIterator(baseData *StatementBaseData, forChange bool) TableIterator {
+	if forChange {
+		return &readOnlyIterator{v}
+	}
+	expanded, err := v.Expand(baseData)
+	if err != nil {
+		return &failedIterator{v, err}
+	}
+	return expanded.NewIterator(baseData, false)
+}
+
+func (v *View) Insert(recordValues []driver.Value, conn *GoSqlConnData) int64 {
+	panic("not implemented")
+}
+
+func (v *View) Update(recordId int64, recordValues Tuple, conn *GoSqlConnData) bool {
+	panic("not implemented")
+}
+
+func (v *View) Delete(recordId int64, conn *GoSqlConnData) bool {
+	panic("not implemented")
+}
+
+// an iterator of a table, whose tuples could not be determined
+type failedIterator struct {
+	table Table
+	err   error
+}
+
+func (it *failedIterator) GetTable() Table {
+	return it.table
+}
+
+func (it *failedIterator) Next(check func(Tuple) (bool, error)) (Tuple, bool, error) {
+	return NULL_TUPLE, false, it.err
+}
+
+func (it *failedIterator) Close() {}
+
+// CreateView adds the view to its schema or replaces the one named like it, the transaction of conn sees it from now
+// on, the others after it committed. The caller holds the name of the view exclusively.
+func CreateView(conn *GoSqlConnData, v *View) {
+	tra := conn.Transaction
+	tra.changeCatalog(catalogChange{tra.Cid, v.SchemaName, v.TableName, v, false})
+}
+
+// DropView removes the view from its schema, the other transactions see it until the one of conn committed.
+// The caller holds the name of the view exclusively.
+func DropView(conn *GoSqlConnData, v *View) {
+	tra := conn.Transaction
+	tra.changeCatalog(catalogChange{tra.Cid, v.SchemaName, v.TableName, nil, true})
+}
+
+// CheckReplacing fails, if the view can not be replaced by one having the columns: like in postgres it must keep the
+// columns with their names and types, new ones can be appended.
+func (v *View) CheckReplacing(columns []GoSqlColumn) error {
+	if len(columns) < len(v.TableColumns) {
+		return fmt.Errorf("cannot drop columns from view %s", v.TableName)
+	}
+	for ix, col := range v.TableColumns {
+		if columns[ix].Name != col.Name {
+			return fmt.Errorf("cannot change name of view column %s to %s", col.Name, columns[ix].Name)
+		}
+		if columns[ix].ParserType != col.ParserType {
+			return fmt.Errorf("cannot change data type of view column %s", col.Name)
+		}
+	}
+	return nil
+}
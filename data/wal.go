@@ -0,0 +1,689 @@
+package data
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql/driver"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The write-ahead log records every change done to GoSqlTables together with the end of the
+// transactions doing them. Commits are synced to disk before the transaction is marked as committed,
+// so after a crash the log can be replayed: changes of committed transactions are applied again,
+// changes of transactions without commit record are discarded.
+//
+// Each record is written as frame: uint32 payload length, uint32 crc32 of payload, payload.
+// A torn frame at the end of the file (crash during write) ends the replay.
+//
+// The files of the log belong to a generation, which is named in the file CURRENT. A checkpoint
+// (see checkpoint.go) creates the next generation consisting of a snapshot and a shortened log.
+
+const (
+	walCurrentFileName = "CURRENT"
+	// DefaultCheckpointSize is the size of the log in bytes, which triggers a checkpoint in the background
+	DefaultCheckpointSize = int64(64 << 20)
+)
+
+type walRecordKind byte
+
+const (
+	walStatement walRecordKind = iota + 1 // ddl-statement, replayed by parsing it again
+	walInsert
+	walUpdate
+	walDelete
+	walCommit
+	walRollback
+	walSequence            // value of a sequence, not transactional unless the sequence is not committed yet
+	walTableState          // NextTupleId of a table, written in snapshots
+	walRollbackToSavepoint // keeps only the first counter changes of the transaction
+)
+
+type walRecord struct {
+	kind    walRecordKind
+	xid     int64
+	schema  string // of the table, the search path of a statement
+	table   string
+	sql     string
+	counter int64
+	called  bool // of the value of a sequence
+	recid   int64
+	values  []driver.Value
+}
+
+type writeAheadLog struct {
+	db             *Database
+	dir            string
+	generation     int64
+	file           *os.File
+	writer         *bufio.Writer
+	size           int64
+	statements     []*walRecord           // all ddl-statements committed, they are part of each snapshot
+	pending        map[int64][]*walRecord // ddl-statements of running transactions
+	checkpointSize int64
+	checkpointing  atomic.Bool
+	checkpointMu   sync.Mutex
+	checkpointErr  error // failure of the last background checkpoint, returned by the next Checkpoint or CloseWriteAheadLog
+	err            error // first error encountered during buffered writes, reported at next commit
+	mu             sync.Mutex
+}
+
+// ReplayStatement executes a logged ddl-statement during recovery. Set by the parser which is able to interpret sql.
+var ReplayStatement func(sql string, conn *GoSqlConnData) error
+
+func walFileName(generation int64) string {
+	return fmt.Sprintf("wal-%d.log", generation)
+}
+
+func snapshotFileName(generation int64) string {
+	return fmt.Sprintf("snapshot-%d.dat", generation)
+}
+
+func readCurrentGeneration(dir string) (int64, error) {
+	content, err := os.ReadFile(filepath.Join(dir, walCurrentFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+}
+
+// OpenWriteAheadLog replays snapshot and log found in dir into the database and afterwards appends all its
+// changes to the log. If the log grows beyond checkpointSize bytes, a checkpoint is done in the background, 0
+// switches that off. Opening the directory which is already in use does nothing.
+func (db *Database) OpenWriteAheadLog(dir string, checkpointSize int64) error {
+	db.walMu.Lock()
+	defer db.walMu.Unlock()
+	if act := db.wal.Load(); act != nil {
+		if act.dir == dir {
+			return nil
+		}
+		return fmt.Errorf("write-ahead log already opened in %s", act.dir)
+	}
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return err
+	}
+	generation, err := readCurrentGeneration(dir)
+	if err != nil {
+		return err
+	}
+	replay := newWalReplay(db)
+	snapshot, err := os.Open(filepath.Join(dir, snapshotFileName(generation)))
+	if err == nil {
+		_, err = replay.replay(bufio.NewReader(snapshot))
+		snapshot.Close()
+		if err != nil {
+			return err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	file, err := os.OpenFile(filepath.Join(dir, walFileName(generation)), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	validLen, err := replay.replay(bufio.NewReader(file))
+	if err == nil {
+		err = file.Truncate(validLen)
+	}
+	if err == nil {
+		_, err = file.Seek(validLen, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		return err
+	}
+	db.transactionManager.continueXidsBehind(replay.maxXid)
+	db.wal.Store(&writeAheadLog{db: db, dir: dir, generation: generation, file: file, writer: bufio.NewWriter(file),
+		size: validLen, statements: replay.statements, pending: make(map[int64][]*walRecord), checkpointSize: checkpointSize})
+	return nil
+}
+
+// CloseWriteAheadLog syncs and closes the log of the database. Changes are not logged anymore afterwards.
+func (db *Database) CloseWriteAheadLog() error {
+	db.walMu.Lock()
+	defer db.walMu.Unlock()
+	w := db.wal.Swap(nil)
+	if w == nil {
+		return nil
+	}
+	w.checkpointMu.Lock()
+	defer w.checkpointMu.Unlock()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	err := w.flush(true)
+	closeErr := w.file.Close()
+	if err != nil {
+		return err
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+	return w.checkpointErr
+}
+
+func writeWalFrame(w io.Writer, payload []byte) (int, error) {
+	var header [8]byte
+	binary.LittleEndian.PutUint32(header[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(header[4:8], crc32.ChecksumIEEE(payload))
+	if _, err := w.Write(header[:]); err != nil {
+		return 0, err
+	}
+	if _, err := w.Write(payload); err != nil {
+		return 0, err
+	}
+	return len(header) + len(payload), nil
+}
+
+// returns the payload of the next frame, false if the end of the valid frames is reached
+func readWalFrame(r io.Reader) ([]byte, bool) {
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, false
+	}
+	payload := make([]byte, binary.LittleEndian.Uint32(header[0:4]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, false
+	}
+	if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(header[4:8]) {
+		return nil, false
+	}
+	return payload, true
+}
+
+// appends the record, returns an error if it can not be encoded. Write errors are reported by the next flush.
+func (w *writeAheadLog) append(rec *walRecord) error {
+	payload, err := encodeWalRecord(rec)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return nil
+	}
+	n, err := writeWalFrame(w.writer, payload)
+	if err != nil {
+		w.err = err
+		return nil
+	}
+	w.size += int64(n)
+	switch rec.kind {
+	case walStatement:
+		if rec.xid == NO_TRANSACTION {
+			w.statements = append(w.statements, rec)
+		} else {
+			w.pending[rec.xid] = append(w.pending[rec.xid], rec)
+		}
+	case walCommit:
+		w.statements = append(w.statements, w.pending[rec.xid]...)
+		delete(w.pending, rec.xid)
+	case walRollback:
+		delete(w.pending, rec.xid)
+	case walRollbackToSavepoint:
+		w.pending[rec.xid] = slices.DeleteFunc(w.pending[rec.xid], func(statement *walRecord) bool {
+			return statement.counter >= rec.counter
+		})
+	}
+	return nil
+}
+
+func (w *writeAheadLog) flush(sync bool) error {
+	if w.err != nil {
+		return w.err
+	}
+	if err := w.writer.Flush(); err != nil {
+		w.err = err
+		return err
+	}
+	if sync {
+		if err := w.file.Sync(); err != nil {
+			w.err = err
+			return err
+		}
+	}
+	return nil
+}
+
+// appends the end of the transaction, commits are synced to disk before returning
+func (w *writeAheadLog) endTransaction(xid int64, state TransactionState) error {
+	kind := walCommit
+	if state != COMMITTED {
+		kind = walRollback
+	}
+	err := w.append(&walRecord{kind: kind, xid: xid})
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	err = w.flush(state == COMMITTED)
+	size := w.size
+	w.mu.Unlock()
+	if err == nil && w.checkpointSize > 0 && size > w.checkpointSize && w.checkpointing.CompareAndSwap(false, true) {
+		go func() {
+			defer w.checkpointing.Store(false)
+			if err := w.checkpoint(); err != nil {
+				w.mu.Lock()
+				w.checkpointErr = err
+				w.mu.Unlock()
+			}
+		}()
+	}
+	return err
+}
+
+func logTableChange(kind walRecordKind, t *GoSqlTable, recid int64, values []driver.Value, conn *GoSqlConnData) {
+	w := conn.Database.wal.Load()
+	if w == nil {
+		return
+	}
+	conn.Transaction.ChangeCount++
+	err := w.append(&walRecord{kind: kind, xid: conn.Transaction.Xid, schema: t.SchemaName, table: t.TableName, recid: recid, values: values})
+	if err != nil && conn.Transaction.walErr == nil {
+		conn.Transaction.walErr = err
+	}
+}
+
+// LogStatement records a ddl-statement, which is replayed during recovery using ReplayStatement. A transactional
+// one is part of the running transaction of conn, it is replayed if that committed.
+func LogStatement(conn *GoSqlConnData, sql string, transactional bool) error {
+	w := conn.Database.wal.Load()
+	if w == nil {
+		return nil
+	}
+	if transactional {
+		// counter is the number of changes before, to find the statements rolled back to a savepoint
+		tra := conn.Transaction
+		err := w.append(&walRecord{kind: walStatement, xid: tra.Xid, schema: searchPath(conn), sql: sql, counter: tra.ChangeCount})
+		if err != nil {
+			return err
+		}
+		tra.ChangeCount++
+		return nil
+	}
+	err := w.append(&walRecord{kind: walStatement, schema: searchPath(conn), sql: sql})
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.flush(true)
+}
+
+// the schemas searched by conn separated by commas, recorded with the statements to replay them the same way
+func searchPath(conn *GoSqlConnData) string {
+	return strings.Join(conn.SearchedSchemas(), ",")
+}
+
+func logRollbackToSavepoint(t *Transaction, changeCount int64) {
+	if w := t.Conn.Database.wal.Load(); w != nil {
+		// without values the record can always be encoded
+		_ = w.append(&walRecord{kind: walRollbackToSavepoint, xid: t.Xid, counter: changeCount})
+	}
+}
+
+// logs the end of the transaction, a transaction with a change that could not be logged is rolled back
+func logEndTransaction(t *Transaction, state TransactionState) error {
+	w := t.Conn.Database.wal.Load()
+	if w == nil || t.ChangeCount == 0 {
+		return nil
+	}
+	if t.walErr != nil {
+		w.endTransaction(t.Xid, ROLLEDBACK)
+		return t.walErr
+	}
+	return w.endTransaction(t.Xid, state)
+}
+
+// collects the records of transactions until their commit or rollback is found
+type walReplay struct {
+	db         *Database
+	pending    map[int64][]*walRecord
+	sequences  map[int64][]*walRecord // values of the sequences created by the transactions, not rolled back to savepoints
+	statements []*walRecord
+	maxXid     int64
+}
+
+func newWalReplay(db *Database) *walReplay {
+	return &walReplay{db: db, pending: make(map[int64][]*walRecord), sequences: make(map[int64][]*walRecord)}
+}
+
+// reads all records and applies those of committed transactions, returns the length of the valid part read
+func (rp *walReplay) replay(r io.Reader) (int64, error) {
+	validLen := int64(0)
+	for {
+		payload, ok := readWalFrame(r)
+		if !ok {
+			break
+		}
+		rec, err := decodeWalRecord(payload)
+		if err != nil {
+			return validLen, err
+		}
+		validLen += int64(8 + len(payload))
+		rp.maxXid = max(rp.maxXid, rec.xid)
+		switch rec.kind {
+		case walCommit:
+			for _, pending := range rp.pending[rec.xid] {
+				if pending.kind == walStatement {
+					rp.statements = append(rp.statements, pending)
+				}
+			}
+			err = rp.db.applyWalRecords(append(rp.pending[rec.xid], rp.sequences[rec.xid]...))
+			delete(rp.pending, rec.xid)
+			delete(rp.sequences, rec.xid)
+		case walRollback:
+			delete(rp.pending, rec.xid)
+			delete(rp.sequences, rec.xid)
+		case walRollbackToSavepoint:
+			if int64(len(rp.pending[rec.xid])) > rec.counter {
+				rp.pending[rec.xid] = rp.pending[rec.xid][:rec.counter]
+			}
+		case walTableState:
+			err = rp.db.applyWalRecords([]*walRecord{rec})
+		case walSequence:
+			if rec.xid == NO_TRANSACTION {
+				err = rp.db.applyWalRecords([]*walRecord{rec})
+			} else {
+				rp.sequences[rec.xid] = append(rp.sequences[rec.xid], rec)
+			}
+		case walStatement:
+			if rec.xid == NO_TRANSACTION {
+				rp.statements = append(rp.statements, rec)
+				err = rp.db.applyWalRecords([]*walRecord{rec})
+			} else {
+				rec.counter = int64(len(rp.pending[rec.xid]))
+				rp.pending[rec.xid] = append(rp.pending[rec.xid], rec)
+			}
+		default:
+			rp.pending[rec.xid] = append(rp.pending[rec.xid], rec)
+		}
+		if err != nil {
+			return validLen, err
+		}
+	}
+	return validLen, nil
+}
+
+func walTable(rec *walRecord, conn *GoSqlConnData) (*GoSqlTable, error) {
+	table, ok := lookupTable(conn, rec.schema, rec.table).(*GoSqlTable)
+	if !ok {
+		return nil, fmt.Errorf("recovery: table %s.%s not found", rec.schema, rec.table)
+	}
+	return table, nil
+}
+
+// applies the records of one transaction in a new transaction
+func (db *Database) applyWalRecords(records []*walRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	conn := &GoSqlConnData{Number: -1, DefaultIsolationLevel: COMMITTED_READ, Database: db}
+	err := StartTransaction(conn)
+	if err != nil {
+		return err
+	}
+	for _, rec := range records {
+		if rec.kind == walStatement {
+			if ReplayStatement == nil {
+				err = errors.New("recovery: no statement replay available")
+			} else {
+				schemas := strings.Split(rec.schema, ",")
+				conn.CurrentSchema, conn.SearchPath = schemas[0], schemas[1:]
+				err = ReplayStatement(rec.sql, conn)
+			}
+			if err == nil && conn.Transaction == nil {
+				err = StartTransaction(conn)
+			}
+			if err != nil {
+				break
+			}
+			continue
+		}
+		if rec.kind == walSequence {
+			// not found, if it got dropped meanwhile
+			if s, ok := lookupTable(conn, rec.schema, rec.table).(*Sequence); ok {
+				s.value.Store(&sequenceValue{rec.counter, rec.called})
+			}
+			continue
+		}
+		table, tableErr := walTable(rec, conn)
+		if tableErr != nil {
+			err = tableErr
+			break
+		}
+		switch rec.kind {
+		case walInsert:
+			table.insertWithId(rec.recid, rec.values, conn)
+		case walUpdate:
+			if !table.Update(rec.recid, NewSliceTuple(rec.recid, rec.values), conn) {
+				err = fmt.Errorf("recovery: tuple %d of %s not found for update", rec.recid, rec.table)
+			}
+		case walDelete:
+			if !table.Delete(rec.recid, conn) {
+				err = fmt.Errorf("recovery: tuple %d of %s not found for delete", rec.recid, rec.table)
+			}
+		case walTableState:
+			for {
+				next := table.NextTupleId.Load()
+				if next >= rec.counter || table.NextTupleId.CompareAndSwap(next, rec.counter) {
+					break
+				}
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	if err != nil {
+		EndTransaction(conn, ROLLEDBACK)
+		return err
+	}
+	return EndTransaction(conn, COMMITTED)
+}
+
+const (
+	walNil byte = iota
+	walInt64
+	walFloat64
+	walString
+	walBool
+	walTime
+	walBytes
+)
+
+type walEncoder struct {
+	bytes.Buffer
+}
+
+func (e *walEncoder) varint(i int64) {
+	e.Write(binary.AppendVarint(nil, i))
+}
+
+func (e *walEncoder) bool(b bool) {
+	if b {
+		e.WriteByte(1)
+	} else {
+		e.WriteByte(0)
+	}
+}
+
+func (e *walEncoder) string(s string) {
+	e.varint(int64(len(s)))
+	e.WriteString(s)
+}
+
+func (e *walEncoder) value(v driver.Value) error {
+	switch x := v.(type) {
+	case nil:
+		e.WriteByte(walNil)
+	case int64:
+		e.WriteByte(walInt64)
+		e.varint(x)
+	case int:
+		e.WriteByte(walInt64)
+		e.varint(int64(x))
+	case float64:
+		e.WriteByte(walFloat64)
+		e.Write(binary.LittleEndian.AppendUint64(nil, math.Float64bits(x)))
+	case string:
+		e.WriteByte(walString)
+		e.string(x)
+	case bool:
+		e.WriteByte(walBool)
+		e.bool(x)
+	case time.Time:
+		b, _ := x.MarshalBinary()
+		e.WriteByte(walTime)
+		e.string(string(b))
+	case []byte:
+		e.WriteByte(walBytes)
+		e.string(string(x))
+	default:
+		return fmt.Errorf("value of type %T can not be logged", v)
+	}
+	return nil
+}
+
+func encodeWalRecord(rec *walRecord) ([]byte, error) {
+	e := &walEncoder{}
+	e.WriteByte(byte(rec.kind))
+	e.varint(rec.xid)
+	switch rec.kind {
+	case walStatement:
+		e.string(rec.schema)
+		e.string(rec.sql)
+	case walSequence:
+		e.string(rec.schema)
+		e.string(rec.table)
+		e.varint(rec.counter)
+		e.bool(rec.called)
+	case walTableState:
+		e.string(rec.schema)
+		e.string(rec.table)
+		e.varint(rec.counter)
+	case walRollbackToSavepoint:
+		e.varint(rec.counter)
+	case walInsert, walUpdate, walDelete:
+		e.string(rec.schema)
+		e.string(rec.table)
+		e.varint(rec.recid)
+		e.varint(int64(len(rec.values)))
+		for _, v := range rec.values {
+			if err := e.value(v); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return e.Bytes(), nil
+}
+
+type walDecoder struct {
+	*bytes.Reader
+}
+
+func (d walDecoder) varint() int64 {
+	i, err := binary.ReadVarint(d)
+	if err != nil {
+		panic(err)
+	}
+	return i
+}
+
+func (d walDecoder) string() string {
+	b := make([]byte, d.varint())
+	if _, err := io.ReadFull(d, b); err != nil {
+		panic(err)
+	}
+	return string(b)
+}
+
+func (d walDecoder) byte() byte {
+	b, err := d.ReadByte()
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func (d walDecoder) value() driver.Value {
+	switch tag := d.byte(); tag {
+	case walNil:
+		return nil
+	case walInt64:
+		return d.varint()
+	case walFloat64:
+		var b [8]byte
+		if _, err := io.ReadFull(d, b[:]); err != nil {
+			panic(err)
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(b[:]))
+	case walString:
+		return d.string()
+	case walBool:
+		return d.byte() != 0
+	case walTime:
+		var t time.Time
+		if err := t.UnmarshalBinary([]byte(d.string())); err != nil {
+			panic(err)
+		}
+		return t
+	case walBytes:
+		return []byte(d.string())
+	default:
+		panic(fmt.Sprintf("invalid value tag %d", tag))
+	}
+}
+
+func decodeWalRecord(payload []byte) (rec *walRecord, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			rec, err = nil, fmt.Errorf("invalid wal record: %v", r)
+		}
+	}()
+	d := walDecoder{bytes.NewReader(payload)}
+	rec = &walRecord{kind: walRecordKind(d.byte()), xid: d.varint()}
+	switch rec.kind {
+	case walStatement:
+		rec.schema = d.string()
+		rec.sql = d.string()
+	case walSequence:
+		rec.schema = d.string()
+		rec.table = d.string()
+		rec.counter = d.varint()
+		rec.called = d.byte() != 0
+	case walTableState:
+		rec.schema = d.string()
+		rec.table = d.string()
+		rec.counter = d.varint()
+	case walRollbackToSavepoint:
+		rec.counter = d.varint()
+	case walInsert, walUpdate, walDelete:
+		rec.schema = d.string()
+		rec.table = d.string()
+		rec.recid = d.varint()
+		n := d.varint()
+		for i := int64(0); i < n; i++ {
+			rec.values = append(rec.values, d.value())
+		}
+	case walCommit, walRollback:
+	default:
+		return nil, fmt.Errorf("invalid wal record kind %d", rec.kind)
+	}
+	return rec, nil
+}
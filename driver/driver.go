@@ -1,10 +1,14 @@
 package driver
 
 import (
+	"context"
 	"database/sql"
 	"database/sql/driver"
 	"fmt"
+	"net/url"
 	"reflect"
+	"strconv"
+	"strings"
 	"sync/atomic"
 
 	"github.com/aschoerk/go-sql-mem/data"
@@ -22,19 +26,110 @@ type GoSqlConn struct {
 	Data data.GoSqlConnData
 }
 
+// Open interprets the DSN: "file:<directory>" or a path starting with "/" or "." let the changes be
+// logged into a write-ahead log in that directory, which is replayed when opening the first connection.
+// The query parameter checkpoint_size (e.g. "file:/var/lib/gosql?checkpoint_size=1048576") sets the size
+// of the log in bytes triggering a checkpoint, 0 switches automatic checkpoints off.
+// "memory:<name>" (e.g. "memory:testA") keeps the data in memory only, in the database name, which is
+// independent of the databases of other names, see data.CreateDatabase and data.DestroyDatabase.
+// Every other DSN (e.g. "memory") keeps the data in memory only, in the default database.
 func (d *GoSqlDriver) Open(s string) (driver.Conn, error) {
-	return &GoSqlConn{data.GoSqlConnData{d.connectionNumber.Add(1), nil, true, d.DefaultIsolationLevel, "public"}}, nil
+	var db *data.Database
+	if dir, ok := walDirectory(s); ok {
+		dir, checkpointSize, err := walOptions(dir)
+		if err != nil {
+			return nil, err
+		}
+		db = data.OpenDatabase("file:" + dir)
+		err = db.OpenWriteAheadLog(dir, checkpointSize)
+		if err != nil {
+			return nil, err
+		}
+	} else if name, ok := strings.CutPrefix(s, "memory:"); ok && name != "" {
+		db = data.OpenDatabase(name)
+	} else {
+		db = data.OpenDatabase(data.DEFAULT_DATABASE_NAME)
+	}
+	return &GoSqlConn{data.GoSqlConnData{Number: d.connectionNumber.Add(1), DoAutoCommit: true, DefaultIsolationLevel: d.DefaultIsolationLevel, CurrentSchema: data.PUBLIC_SCHEMA_NAME, LockTimeoutInMs: data.DEFAULT_LOCK_TIMEOUT_MS, Database: db}}, nil
+}
+
+func walDirectory(dsn string) (string, bool) {
+	if strings.HasPrefix(dsn, "file:") {
+		return strings.TrimPrefix(dsn, "file:"), true
+	}
+	if strings.HasPrefix(dsn, "/") || strings.HasPrefix(dsn, ".") {
+		return dsn, true
+	}
+	return "", false
+}
+
+func walOptions(dir string) (string, int64, error) {
+	checkpointSize := data.DefaultCheckpointSize
+	dir, query, found := strings.Cut(dir, "?")
+	if !found {
+		return dir, checkpointSize, nil
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return "", 0, err
+	}
+	if value := values.Get("checkpoint_size"); value != "" {
+		checkpointSize, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "", 0, fmt.Errorf("invalid checkpoint_size %s: %w", value, err)
+		}
+	}
+	return dir, checkpointSize, nil
+}
+
+// the transaction started by Begin, autocommit is switched back on when it ends
+type GoSqlTx struct {
+	conn        *GoSqlConn
+	transaction *data.Transaction
+}
+
+func (t *GoSqlTx) Commit() error {
+	t.conn.Data.DoAutoCommit = true
+	return t.transaction.Commit()
+}
+
+func (t *GoSqlTx) Rollback() error {
+	t.conn.Data.DoAutoCommit = true
+	return t.transaction.Rollback()
 }
 
 func (c *GoSqlConn) Begin() (driver.Tx, error) {
-	c.Data.DoAutoCommit = false
 	if c.Data.Transaction != nil {
 		if c.Data.Transaction.State == data.STARTED || c.Data.Transaction.State == data.ROLLBACKONLY {
 			return nil, fmt.Errorf("Transaction is already started on connection %d", c.Data.Number)
 		}
 	}
+	c.Data.DoAutoCommit = false
 	data.InitTransaction(&c.Data)
-	return c.Data.Transaction, nil
+	return &GoSqlTx{c, c.Data.Transaction}, nil
+}
+
+// BeginTx starts a transaction using the isolation level requested, sql.LevelDefault uses the default of the driver
+func (c *GoSqlConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	var isolationLevel data.TransactionIsolationLevel
+	switch sql.IsolationLevel(opts.Isolation) {
+	case sql.LevelDefault:
+		isolationLevel = c.Data.DefaultIsolationLevel
+	case sql.LevelReadCommitted:
+		isolationLevel = data.COMMITTED_READ
+	case sql.LevelRepeatableRead, sql.LevelSnapshot:
+		isolationLevel = data.REPEATABLE_READ
+	case sql.LevelSerializable:
+		isolationLevel = data.SERIALIZABLE
+	default:
+		return nil, fmt.Errorf("isolation level %s not supported", sql.IsolationLevel(opts.Isolation))
+	}
+	tx, err := c.Begin()
+	if err != nil {
+		return nil, err
+	}
+	c.Data.Transaction.IsolationLevel = isolationLevel
+	return tx, nil
 }
 
 func (c *GoSqlConn) Prepare(query string) (driver.Stmt, error) {
@@ -42,10 +137,52 @@ func (c *GoSqlConn) Prepare(query string) (driver.Stmt, error) {
 	parseResult, res := parser.Parse(query)
 	stmt := parseResult.(data.StatementInterface)
 	stmt.BaseData().Conn = &c.Data
+	stmt.BaseData().Sql = query
 	fmt.Printf("lval: %s, res: %d", reflect.TypeOf(parseResult), res)
 	return parseResult, nil
 }
 
+// ExecContext executes the statement, lock waits of it are cancelled when ctx is done
+func (c *GoSqlConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	stmt, err := c.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	values, err := namedValues(args)
+	if err != nil {
+		return nil, err
+	}
+	c.Data.Ctx = ctx
+	defer func() { c.Data.Ctx = nil }()
+	return stmt.Exec(values)
+}
+
+// QueryContext executes the query, lock waits of it are cancelled when ctx is done
+func (c *GoSqlConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	stmt, err := c.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	values, err := namedValues(args)
+	if err != nil {
+		return nil, err
+	}
+	c.Data.Ctx = ctx
+	defer func() { c.Data.Ctx = nil }()
+	return stmt.Query(values)
+}
+
+func namedValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, fmt.Errorf("named parameter %s not supported", arg.Name)
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
+
 func (c *GoSqlConn) Close() error {
 	if c.Data.Transaction != nil {
 		if c.Data.Transaction.State == data.STARTED || c.Data.Transaction.State == data.ROLLBACKONLY {
@@ -7,16 +7,16 @@ type queryType struct {
 }
 
 type statmentInfo struct {
-	ConnectionId string `json: "connectionId"`
-	StatementId  string `json: "statementId"`
-	NumInput     int    `json: "numInput"`
+	ConnectionId string `json:"connectionId"`
+	StatementId  string `json:"statementId"`
+	NumInput     int    `json:"numInput"`
 }
 
 type ExecResult struct {
-	LastInsertedId       int64 `json: "lastInsertId"`
-	LastInsertedIdError  error `json: "lastInsertIdError"`
-	NumRowsAffected      int64 `json: "rowsAffected"`
-	NumRowsAffectedError error `json: "rowsAffectedError"`
+	LastInsertedId       int64 `json:"lastInsertId"`
+	LastInsertedIdError  error `json:"lastInsertIdError"`
+	NumRowsAffected      int64 `json:"rowsAffected"`
+	NumRowsAffectedError error `json:"rowsAffectedError"`
 }
 
 func (r ExecResult) LastInsertId() (int64, error) {
@@ -28,7 +28,7 @@ func (r ExecResult) RowsAffected() (int64, error) {
 }
 
 type RowsResult struct {
-	Names  []string         `json: "names"`
-	Types  []int            `json: "types"`
-	Values [][]driver.Value `json: "values"`
+	Names  []string         `json:"names"`
+	Types  []int            `json:"types"`
+	Values [][]driver.Value `json:"values"`
 }
@@ -232,7 +232,7 @@ func StringToInt(m *Machine) error {
 		return newError("top element is not a string")
 	}
 
-	intVal, err := strconv.Atoi(str)
+	intVal, err := strconv.ParseInt(str, 10, 64)
 	if err != nil {
 		return err
 	}
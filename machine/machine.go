@@ -79,6 +79,26 @@ func AddPushConstant(m *Machine, val Value) {
 	})
 }
 
+// AddFunction adds the call of f: the n values pushed last are popped and passed to it, its result is pushed
+func AddFunction(m *Machine, n int, f func(args []Value) (Value, error)) {
+	m.AddCommand(func(m *Machine) error {
+		args := make([]Value, n)
+		for ix := n - 1; ix >= 0; ix-- {
+			arg, ok := m.s.Pop()
+			if !ok {
+				return errors.New("empty stack")
+			}
+			args[ix] = arg
+		}
+		res, err := f(args)
+		if err != nil {
+			return err
+		}
+		m.s.Push(res)
+		return nil
+	})
+}
+
 func AddConversion(m *Machine, conversion func(m *Machine) error, preLast bool) {
 	if preLast {
 		m.AddCommandBeforeLast(conversion)
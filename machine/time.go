@@ -185,3 +185,9 @@ func AddSecondsToTime(m *Machine) error {
 
 	return nil
 }
+
+// PushCurrentTimestamp pushes the time of the evaluation, the value of CURRENT_TIMESTAMP
+func PushCurrentTimestamp(m *Machine) error {
+	m.s.Push(time.Now())
+	return nil
+}
@@ -0,0 +1,144 @@
+package parser
+
+import (
+	. "database/sql/driver"
+	"fmt"
+	"slices"
+
+	"github.com/aschoerk/go-sql-mem/data"
+	. "github.com/aschoerk/go-sql-mem/data"
+	. "github.com/aschoerk/go-sql-mem/machine"
+)
+
+type GoSqlAlterTableRequest struct {
+	data.BaseStatement
+	table  GoSqlIdentifier
+	action GoSqlAlterTableAction
+}
+
+// the changes are not transactional, so they are rejected inside a transaction, except for a table created by it
+func (r *GoSqlAlterTableRequest) Exec(args []Value) (Result, error) {
+	goSqlTable, err := lockedTable(r.BaseStatement, r.table, "alter")
+	if err == nil {
+		err = goSqlTable.CheckAlterable(r.Conn, "ALTER TABLE")
+	}
+	if err == nil && goSqlTable.IsMaterializedView() {
+		err = fmt.Errorf("can not alter materialized view %s", r.table.Name())
+	}
+	if err == nil {
+		err = r.alter(goSqlTable)
+	}
+	if err == nil {
+		// logged after the change, since the change might fail
+		err = LogStatement(r.Conn, r.Sql, goSqlTable.CreatedBy(r.Conn))
+	}
+	if err != nil {
+		AbortStatement(r.BaseData())
+		return nil, err
+	}
+	return &GoSqlResult{-1, 0}, EndStatement(r.BaseData())
+}
+
+func (r *GoSqlAlterTableRequest) alter(table *GoSqlTable) error {
+	switch r.action.kind {
+	case ADD:
+		name := r.action.elements.columns[0].Name
+		if _, err := table.FindColumn(name); err == nil && r.action.ifExists == 1 {
+			return nil
+		}
+		return r.addColumn(table)
+	case DROP:
+		if _, err := table.FindColumn(r.action.column); err != nil && r.action.ifExists == 0 {
+			return nil
+		}
+		return table.DropColumn(r.action.column, func(check any) bool {
+			return check.(*GoSqlTerm).refersTo(r.action.column)
+		})
+	case RENAME:
+		return table.RenameColumn(r.action.column, r.action.newName, func(term any) any {
+			return term.(*GoSqlTerm).renamed(r.action.column, r.action.newName)
+		})
+	case ALTER:
+		return r.alterColumnType(table)
+	case TABLE:
+		err := LockTableName(r.Conn, table.SchemaName, table.TableName)
+		if err == nil {
+			err = LockTableName(r.Conn, table.SchemaName, r.action.newName)
+		}
+		if err != nil {
+			return err
+		}
+		return RenameTable(r.Conn, table, r.action.newName)
+	}
+	return nil
+}
+
+// the tuples existing get the value of DEFAULT, they are checked against the constraints of the column
+func (r *GoSqlAlterTableRequest) addColumn(table *GoSqlTable) error {
+	elements := r.action.elements
+	column := elements.columns[0]
+	if column.Spec2 == PRIMARY_AUTOINCREMENT || column.Spec2 == PRIMARY_KEY_COLUMN || column.Spec2 == UNIQUE_COLUMN ||
+		len(elements.foreignKeys) > 0 {
+		return fmt.Errorf("column %s: ADD COLUMN does not support PRIMARY KEY, UNIQUE and REFERENCES", column.Name)
+	}
+	// the table as it will be, to compile DEFAULT and CHECK
+	columns := append(slices.Clone(table.Columns()), column)
+	constraints, err := newColumnConstraints(r.Conn, &TempTable{BaseTable: BaseTable{SchemaName: table.SchemaName, TableName: table.TableName, TableColumns: columns}})
+	if err != nil {
+		return err
+	}
+	value, err := constraints.defaultValue(len(columns) - 1)
+	if err != nil {
+		return err
+	}
+	return table.AddColumn(column, value, func() error {
+		it := table.NewIterator(r.BaseData(), false)
+		defer it.Close()
+		for {
+			tuple, ok, err := it.Next(func(Tuple) (bool, error) { return true, nil })
+			if err != nil || !ok {
+				return err
+			}
+			values := make([]Value, 0, len(columns))
+			for ix := 0; ix < tuple.DataLen(); ix++ {
+				values = append(values, tuple.SafeData(0, ix))
+			}
+			err = constraints.check(NewSliceTuple(tuple.Id(), append(values, value)))
+			if err != nil {
+				return err
+			}
+		}
+	})
+}
+
+// the column keeps its constraints, the values written before get converted, when they are read
+func (r *GoSqlAlterTableRequest) alterColumnType(table *GoSqlTable) error {
+	ix, err := table.FindColumn(r.action.column)
+	if err != nil {
+		return err
+	}
+	old := table.Columns()[ix]
+	column := NewColumn(old.Name, r.action.colType, r.action.length, GoSqlColumnConstraints{old.Spec2, old.NotNull, nil, nil, nil})
+	column.Default = old.Default
+	column.Check = old.Check
+	// DEFAULT and CHECK must fit the new type
+	columns := slices.Clone(table.Columns())
+	columns[ix] = column
+	_, err = newColumnConstraints(r.Conn, &TempTable{BaseTable: BaseTable{SchemaName: table.SchemaName, TableName: table.TableName, TableColumns: columns}})
+	if err != nil {
+		return err
+	}
+	if column.ParserType == old.ParserType {
+		return table.AlterColumnType(old.Name, column, nil)
+	}
+	conversion, err := calcConversion(column.ParserType, old.ParserType)
+	if err != nil {
+		return err
+	}
+	return table.AlterColumnType(old.Name, column, func(value Value) (Value, error) {
+		m := NewMachine(nil)
+		AddPushPlaceHolder(m, 0)
+		m.AddCommand(conversion)
+		return m.Execute([]Value{value}, NULL_TUPLE, NULL_TUPLE)
+	})
+}
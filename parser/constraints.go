@@ -0,0 +1,107 @@
+package parser
+
+import (
+	. "database/sql/driver"
+	"fmt"
+
+	"github.com/aschoerk/go-sql-mem/data"
+	. "github.com/aschoerk/go-sql-mem/data"
+)
+
+// NOT NULL, DEFAULT and CHECK of the columns. The columns keep the terms of DEFAULT and CHECK, they are compiled
+// for each statement inserting or updating tuples, since a machine can only be executed by one statement at a time.
+// A CHECK is violated, if its term is false. Comparisons with NULL are false here, so a check of a nullable
+// column should allow for NULL like CHECK (x IS NULL OR x > 0).
+
+type columnConstraints struct {
+	table    Table
+	defaults []*EvaluationContext // by column, nil if the column has no DEFAULT
+	checks   []*EvaluationContext // by column, nil if the column has no CHECK
+}
+
+func newColumnConstraints(conn *GoSqlConnData, table Table) (*columnConstraints, error) {
+	columns := table.Columns()
+	res := &columnConstraints{table, make([]*EvaluationContext, len(columns)), make([]*EvaluationContext, len(columns))}
+	for ix, col := range columns {
+		if col.Default != nil {
+			e, err := compileDefault(conn, col)
+			if err != nil {
+				return nil, err
+			}
+			res.defaults[ix] = e
+		}
+		if col.Check != nil {
+			e, err := compileCheck(conn, table, col)
+			if err != nil {
+				return nil, err
+			}
+			res.checks[ix] = e
+		}
+	}
+	return res, nil
+}
+
+func compileDefault(conn *GoSqlConnData, col GoSqlColumn) (*EvaluationContext, error) {
+	term := col.Default.(*GoSqlTerm)
+	if term.containsLeaf(IDENTIFIER) || term.containsLeaf(PLACEHOLDER) {
+		return nil, fmt.Errorf("DEFAULT of column %s must not refer to columns or placeholders", col.Name)
+	}
+	placeHolderOffset := 0
+	commands, err := Terms2Commands(conn, []*GoSqlTerm{term}, nil, nil, &placeHolderOffset)
+	if err != nil {
+		return nil, err
+	}
+	e := commands[0]
+	if e.resultType != col.ParserType && e.resultType != NULL {
+		conversion, err := calcConversion(col.ParserType, e.resultType)
+		if err != nil {
+			return nil, fmt.Errorf("DEFAULT of column %s: %w", col.Name, err)
+		}
+		e.m.AddCommand(conversion)
+	}
+	return e, nil
+}
+
+func compileCheck(conn *GoSqlConnData, table Table, col GoSqlColumn) (*EvaluationContext, error) {
+	term := col.Check.(*GoSqlTerm)
+	if term.containsLeaf(PLACEHOLDER) {
+		return nil, fmt.Errorf("CHECK of column %s must not contain placeholders", col.Name)
+	}
+	placeHolderOffset := 0
+	commands, err := Terms2Commands(conn, []*GoSqlTerm{term}, nil, JoinedRecordsFromTable(table), &placeHolderOffset)
+	if err != nil {
+		return nil, err
+	}
+	if commands[0].resultType != BOOLEAN {
+		return nil, fmt.Errorf("CHECK of column %s must be a boolean expression", col.Name)
+	}
+	return commands[0], nil
+}
+
+// the value of the DEFAULT of the column, nil if it has none
+func (c *columnConstraints) defaultValue(colix int) (Value, error) {
+	if c.defaults[colix] == nil {
+		return nil, nil
+	}
+	return c.defaults[colix].m.Execute(nil, data.NULL_TUPLE, data.NULL_TUPLE)
+}
+
+// checks the values of a tuple to be inserted or the new version of a tuple to be updated
+func (c *columnConstraints) check(tuple data.Tuple) error {
+	for ix, col := range c.table.Columns() {
+		if col.NotNull && tuple.SafeData(0, ix) == nil {
+			return fmt.Errorf("%w: column %s of table %s", ErrNotNullViolation, col.Name, c.table.Name())
+		}
+		if c.checks[ix] == nil {
+			continue
+		}
+		res, err := c.checks[ix].m.Execute(nil, tuple, data.NULL_TUPLE)
+		if err != nil {
+			return err
+		}
+		if res == false {
+			return fmt.Errorf("%w %s_%s_check", ErrCheckViolation, c.table.Name(), col.Name)
+		}
+	}
+	return nil
+}
@@ -11,7 +11,18 @@ import (
 	. "github.com/aschoerk/go-sql-mem/data"
 )
 
-func NewColumn(name string, coltype int, length int, spec2 int) GoSqlColumn {
+// the column and its foreign key, if it references another table
+func NewColumnElements(name string, coltype int, length int, constraints GoSqlColumnConstraints) GoSqlTableElements {
+	res := GoSqlTableElements{[]GoSqlColumn{NewColumn(name, coltype, length, constraints)}, nil, nil}
+	if constraints.references != nil {
+		fk := *constraints.references
+		fk.columns = []string{name}
+		res.foreignKeys = append(res.foreignKeys, fk)
+	}
+	return res
+}
+
+func NewColumn(name string, coltype int, length int, constraints GoSqlColumnConstraints) GoSqlColumn {
 	var parserType int
 	switch coltype {
 	case VARCHAR, CHAR, TEXT:
@@ -19,7 +30,46 @@ func NewColumn(name string, coltype int, length int, spec2 int) GoSqlColumn {
 	default:
 		parserType = coltype
 	}
-	return GoSqlColumn{name, coltype, parserType, length, spec2, false}
+	res := GoSqlColumn{Name: name, ColType: coltype, ParserType: parserType, Length: length, Spec2: constraints.spec2, NotNull: constraints.notNull}
+	// a nil term must not become a non nil interface
+	if constraints.defaultValue != nil {
+		res.Default = constraints.defaultValue
+	}
+	if constraints.check != nil {
+		res.Check = constraints.check
+	}
+	return res
+}
+
+// a column of the tables of information_schema
+func informationSchemaColumn(name string, text bool) GoSqlColumn {
+	coltype := INTEGER
+	if text {
+		coltype = TEXT
+	}
+	return NewColumn(name, coltype, -1, GoSqlColumnConstraints{-1, false, nil, nil, nil})
+}
+
+// the name of the type of the column used by information_schema
+func columnTypeName(column GoSqlColumn) string {
+	switch column.ColType {
+	case INTEGER:
+		return "integer"
+	case FLOAT:
+		return "double precision"
+	case CHAR:
+		return "character"
+	case VARCHAR:
+		return "character varying"
+	case TEXT:
+		return "text"
+	case BOOLEAN:
+		return "boolean"
+	case TIMESTAMP:
+		return "timestamp without time zone"
+	default:
+		return fmt.Sprintf("type %d", column.ColType)
+	}
 }
 
 func pointerToString(ptr interface{}) string {
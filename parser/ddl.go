@@ -3,52 +3,562 @@ package parser
 import (
 	. "database/sql/driver"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/aschoerk/go-sql-mem/data"
 	. "github.com/aschoerk/go-sql-mem/data"
 )
 
 type GoSqlCreateTableRequest struct {
+	data.BaseStatement
+	ifExists    int
+	table       *GoSqlTable
+	keys        []GoSqlKey // the keys of the columns followed by the ones of the table
+	foreignKeys []GoSqlForeignKeySpec
+}
+
+func NewCreateTableRequest(ifExists int, name GoSqlIdentifier, elements GoSqlTableElements) *GoSqlCreateTableRequest {
+	var keys []GoSqlKey
+	for _, col := range elements.columns {
+		switch col.Spec2 {
+		case PRIMARY_AUTOINCREMENT, PRIMARY_KEY_COLUMN:
+			keys = append(keys, GoSqlKey{true, []string{col.Name}})
+		case UNIQUE_COLUMN:
+			keys = append(keys, GoSqlKey{false, []string{col.Name}})
+		}
+	}
+	return &GoSqlCreateTableRequest{NewStatementBaseData(), ifExists, NewTable(name, elements.columns), append(keys, elements.keys...), elements.foreignKeys}
+}
+
+type GoSqlCreateIndexRequest struct {
 	data.BaseStatement
 	ifExists int
-	table    *GoSqlTable
+	kind     IndexKind
+	name     string
+	table    GoSqlIdentifier
+	columns  []string
 }
 
-type GoSqlCreateDatabaseRequest struct {
+type GoSqlDropIndexRequest struct {
 	data.BaseStatement
 	ifExists int
 	name     GoSqlIdentifier
 }
 
+type GoSqlDropTableRequest struct {
+	data.BaseStatement
+	ifExists int
+	table    GoSqlIdentifier
+}
+
+type GoSqlTruncateRequest struct {
+	data.BaseStatement
+	table GoSqlIdentifier
+}
+
 type GoSqlCreateSchemaRequest struct {
 	data.BaseStatement
 	ifExists int
 	name     GoSqlIdentifier
 }
 
-func (r *GoSqlCreateDatabaseRequest) Exec(args []Value) (Result, error) {
-	panic("not implemented")
+type GoSqlCreateDatabaseRequest struct {
+	data.BaseStatement
+	ifExists int
+	name     GoSqlIdentifier
 }
 
+type GoSqlDropDatabaseRequest struct {
+	data.BaseStatement
+	ifExists int
+	name     GoSqlIdentifier
+}
+
+type GoSqlDropSchemaRequest struct {
+	data.BaseStatement
+	ifExists int
+	name     GoSqlIdentifier
+	cascade  bool // drops the tables of the schema
+}
+
+// the schema is seen by other transactions, after the one creating it committed
 func (r *GoSqlCreateSchemaRequest) Exec(args []Value) (Result, error) {
-	panic("not implemented")
+	if len(r.name.Parts) != 1 {
+		return nil, fmt.Errorf("invalid schema name %s", r.name.Name())
+	}
+	schema := r.name.Parts[0]
+	err := LockSchemaName(r.Conn, schema)
+	if err != nil {
+		AbortStatement(r.BaseData())
+		return nil, err
+	}
+	if SchemaExists(r.Conn, schema) {
+		if r.ifExists == 1 {
+			return &GoSqlResult{-1, 0}, EndStatement(r.BaseData())
+		}
+		AbortStatement(r.BaseData())
+		return nil, fmt.Errorf("schema %s already exists", schema)
+	}
+	err = LogStatement(r.Conn, r.Sql, true)
+	if err != nil {
+		AbortStatement(r.BaseData())
+		return nil, err
+	}
+	CreateSchema(r.Conn, schema)
+	return &GoSqlResult{-1, 0}, EndStatement(r.BaseData())
+}
+
+// the schema dropped is seen by other transactions, until the one dropping it committed
+func (r *GoSqlDropSchemaRequest) Exec(args []Value) (Result, error) {
+	if len(r.name.Parts) != 1 {
+		return nil, fmt.Errorf("invalid schema name %s", r.name.Name())
+	}
+	schema := r.name.Parts[0]
+	err := LockSchemaName(r.Conn, schema)
+	if err != nil {
+		AbortStatement(r.BaseData())
+		return nil, err
+	}
+	if !SchemaExists(r.Conn, schema) {
+		if r.ifExists == 0 {
+			return &GoSqlResult{-1, 0}, EndStatement(r.BaseData())
+		}
+		AbortStatement(r.BaseData())
+		return nil, fmt.Errorf("schema %s does not exist", schema)
+	}
+	err = DropSchema(r.Conn, schema, r.cascade)
+	if err == nil {
+		err = LogStatement(r.Conn, r.Sql, true)
+	}
+	if err != nil {
+		AbortStatement(r.BaseData())
+		return nil, err
+	}
+	return &GoSqlResult{-1, 0}, EndStatement(r.BaseData())
+}
+
+// the name of the database changed by a statement of conn, databases are neither transactional nor logged.
+// The transaction of conn is started like by every statement, so it can be ended.
+func databaseName(conn *data.GoSqlConnData, id GoSqlIdentifier) (string, error) {
+	if err := StartTransaction(conn); err != nil {
+		return "", err
+	}
+	if len(id.Parts) != 1 {
+		return "", fmt.Errorf("invalid database name %s", id.Name())
+	}
+	if !conn.DoAutoCommit {
+		return "", fmt.Errorf("database %s can not be created or dropped inside a transaction", id.Name())
+	}
+	return id.Parts[0], nil
+}
+
+// the database is independent of the one of the connection, the DSN "memory:<name>" opens it
+func (r *GoSqlCreateDatabaseRequest) Exec(args []Value) (Result, error) {
+	name, err := databaseName(r.Conn, r.name)
+	if err == nil && (r.ifExists != 1 || !DatabaseExists(name)) {
+		_, err = CreateDatabase(name)
+	}
+	if err != nil {
+		AbortStatement(r.BaseData())
+		return nil, err
+	}
+	return &GoSqlResult{-1, 0}, EndStatement(r.BaseData())
+}
+
+// the connections using the database keep it, the next one opened gets a new database
+func (r *GoSqlDropDatabaseRequest) Exec(args []Value) (Result, error) {
+	name, err := databaseName(r.Conn, r.name)
+	if err == nil && name == r.Conn.Database.Name {
+		err = fmt.Errorf("can not drop the database %s in use by the connection", name)
+	}
+	if err == nil && (r.ifExists != 0 || DatabaseExists(name)) {
+		err = DestroyDatabase(name)
+	}
+	if err != nil {
+		AbortStatement(r.BaseData())
+		return nil, err
+	}
+	return &GoSqlResult{-1, 0}, EndStatement(r.BaseData())
 }
 
+// the table is seen by other transactions, after the one creating it committed
 func (r *GoSqlCreateTableRequest) Exec(args []Value) (Result, error) {
 	if r.table.SchemaName == data.DEFAULT_SCHEMA_NAME {
 		r.table.SchemaName = r.Conn.CurrentSchema
 	}
-	// r.BaseStatement.Conn.CurrentSchema
-	if Schemas[r.table.SchemaName] == nil {
-		Schemas[r.table.SchemaName] = make(map[string]Table)
+	err := LockTableName(r.Conn, r.table.SchemaName, r.table.Name())
+	if err != nil {
+		AbortStatement(r.BaseData())
+		return nil, err
 	}
-	_, exists := Schemas[r.table.SchemaName][r.table.Name()]
+	_, exists := data.GetTable(r.BaseStatement, GoSqlIdentifier{Parts: []string{r.table.SchemaName, r.table.Name()}})
 	if exists {
-		if r.ifExists == 1 {
+		if r.ifExists != 1 {
+			AbortStatement(r.BaseData())
 			return GoSqlResult{-1, -1}, fmt.Errorf("tableExpr %s already exists", r.table.Name())
 		}
 	} else {
-		Schemas[r.table.SchemaName][r.table.Name()] = r.table
+		err = r.create()
+		if err != nil {
+			AbortStatement(r.BaseData())
+			return nil, err
+		}
+	}
+	return &GoSqlResult{-1, 0}, EndStatement(r.BaseData())
+}
+
+func (r *GoSqlCreateTableRequest) create() error {
+	// DEFAULT and CHECK are compiled to find errors in them before the table exists
+	_, err := newColumnConstraints(r.Conn, r.table)
+	if err != nil {
+		return err
+	}
+	err = r.createKeys()
+	if err != nil {
+		return err
+	}
+	err = r.createForeignKeys()
+	if err != nil {
+		return err
+	}
+	sequences, err := r.createSequences()
+	if err != nil {
+		return err
+	}
+	err = LogStatement(r.Conn, r.Sql, true)
+	if err != nil {
+		return err
+	}
+	CreateTable(r.Conn, r.table)
+	for _, sequence := range sequences {
+		CreateSequence(r.Conn, sequence)
+	}
+	return nil
+}
+
+// the sequences owned by the autoincrement columns, named like postgres does
+func (r *GoSqlCreateTableRequest) createSequences() ([]*Sequence, error) {
+	var res []*Sequence
+	for _, col := range r.table.Columns() {
+		if col.Spec2 != PRIMARY_AUTOINCREMENT {
+			continue
+		}
+		name, err := r.freeSequenceName(r.table.TableName + "_" + col.Name + "_seq")
+		if err != nil {
+			return nil, err
+		}
+		sequence := NewAutoincrementSequence(r.table.SchemaName, name, sequenceColumns())
+		r.table.OwnSequence(col.Name, sequence)
+		res = append(res, sequence)
+	}
+	return res, nil
+}
+
+// the name or the name followed by the lowest number, which is not used by another relation of the schema. The name
+// returned is held exclusively.
+func (r *GoSqlCreateTableRequest) freeSequenceName(name string) (string, error) {
+	res := name
+	for i := 1; ; i++ {
+		err := LockTableName(r.Conn, r.table.SchemaName, res)
+		if err != nil {
+			return "", err
+		}
+		_, exists := data.GetTable(r.BaseStatement, GoSqlIdentifier{Parts: []string{r.table.SchemaName, res}})
+		if !exists && res != r.table.TableName {
+			return res, nil
+		}
+		res = name + strconv.Itoa(i)
+	}
+}
+
+// the unique indexes backing the PRIMARY KEY and UNIQUE constraints, named like postgres does
+func (r *GoSqlCreateTableRequest) createKeys() error {
+	for _, key := range r.keys {
+		var columns []int
+		for _, name := range key.columns {
+			ix, err := r.table.FindColumn(name)
+			if err != nil {
+				return err
+			}
+			columns = append(columns, ix)
+			if key.primary {
+				r.table.TableColumns[ix].NotNull = true
+			}
+		}
+		kind := UNIQUE_CONSTRAINT
+		name := r.table.TableName + "_" + strings.Join(key.columns, "_") + "_key"
+		if key.primary {
+			kind = PRIMARY_KEY_CONSTRAINT
+			name = r.table.TableName + "_pkey"
+		}
+		name = freeIndexName(r.Conn.Database, r.table, name)
+		err := r.table.CreateIndex(name, kind, columns, r.Conn)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *GoSqlCreateTableRequest) createForeignKeys() error {
+	for _, spec := range r.foreignKeys {
+		parent, err := r.referencedTable(spec.parent)
+		if err != nil {
+			return err
+		}
+		var columns, parentColumns []int
+		for _, name := range spec.columns {
+			ix, err := r.table.FindColumn(name)
+			if err != nil {
+				return err
+			}
+			columns = append(columns, ix)
+		}
+		for _, name := range spec.parentColumns {
+			ix, err := parent.FindColumn(name)
+			if err != nil {
+				return err
+			}
+			parentColumns = append(parentColumns, ix)
+		}
+		if spec.parentColumns == nil {
+			for _, index := range parent.Indexes() {
+				if index.Kind == PRIMARY_KEY_CONSTRAINT {
+					parentColumns = index.Columns
+				}
+			}
+			if parentColumns == nil {
+				return fmt.Errorf("there is no primary key of table %s", parent.TableName)
+			}
+		}
+		if len(columns) != len(parentColumns) {
+			return fmt.Errorf("the number of referencing and referenced columns of the foreign key of %s differ", r.table.TableName)
+		}
+		name := r.table.TableName + "_" + strings.Join(spec.columns, "_") + "_fkey"
+		err = AddForeignKey(&GoSqlForeignKey{Name: name, Child: r.table, Columns: columns, Parent: parent, ParentColumns: parentColumns, OnDelete: spec.onDelete, OnUpdate: spec.onUpdate})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// the parent of a foreign key, which may be the table created itself
+func (r *GoSqlCreateTableRequest) referencedTable(id GoSqlIdentifier) (*GoSqlTable, error) {
+	if id.Name() == r.table.TableName || id.Name() == r.table.SchemaName+"."+r.table.TableName {
+		return r.table, nil
+	}
+	table, exists := data.GetTable(r.BaseStatement, id)
+	if !exists {
+		return nil, fmt.Errorf("referenced table %s does not exist", id.Name())
+	}
+	goSqlTable, ok := table.(*GoSqlTable)
+	if !ok || goSqlTable.IsMaterializedView() {
+		return nil, fmt.Errorf("can not reference table %s", id.Name())
+	}
+	// the parent can not be dropped, before the table got committed
+	err := goSqlTable.Lock(r.Conn)
+	if err != nil {
+		return nil, err
+	}
+	return goSqlTable, nil
+}
+
+// the table locked shared for the transaction of the statement, so no other transaction drops it meanwhile
+func lockedTable(stmt data.BaseStatement, id GoSqlIdentifier, action string) (*GoSqlTable, error) {
+	table, exists := data.GetTable(stmt, id)
+	if !exists {
+		return nil, fmt.Errorf("Unknown Table %s", id.Name())
+	}
+	goSqlTable, ok := table.(*GoSqlTable)
+	if !ok {
+		return nil, fmt.Errorf("can not %s table %s", action, id.Name())
+	}
+	err := goSqlTable.Lock(stmt.Conn)
+	if err != nil {
+		return nil, err
+	}
+	return goSqlTable, nil
+}
+
+// true if the tuples of the table can be changed by INSERT, UPDATE, DELETE and TRUNCATE
+func isChangeable(table data.Table) bool {
+	t, ok := table.(*GoSqlTable)
+	return ok && !t.IsMaterializedView()
+}
+
+// the name or the name followed by the lowest number, no other index of the schema is named like
+func freeIndexName(db *Database, table *GoSqlTable, name string) string {
+	res := name
+	for i := 1; ; i++ {
+		other, _ := db.FindIndex(table.SchemaName, res)
+		if other == nil && table.FindIndex(res) == nil {
+			return res
+		}
+		res = name + strconv.Itoa(i)
+	}
+}
+
+func (r *GoSqlCreateIndexRequest) Exec(args []Value) (Result, error) {
+	goSqlTable, err := lockedTable(r.BaseStatement, r.table, "create index on")
+	if err == nil {
+		err = goSqlTable.CheckAlterable(r.Conn, "CREATE INDEX")
+	}
+	if err != nil {
+		AbortStatement(r.BaseData())
+		return nil, err
+	}
+	if other, _ := r.Conn.Database.FindIndex(goSqlTable.SchemaName, r.name); other != nil {
+		if r.ifExists == 1 {
+			return &GoSqlResult{-1, 0}, EndStatement(r.BaseData())
+		}
+		AbortStatement(r.BaseData())
+		return nil, fmt.Errorf("index %s already exists", r.name)
+	}
+	err = r.create(goSqlTable)
+	if err != nil {
+		AbortStatement(r.BaseData())
+		return nil, err
+	}
+	return &GoSqlResult{-1, 0}, EndStatement(r.BaseData())
+}
+
+func (r *GoSqlCreateIndexRequest) create(table *GoSqlTable) error {
+	var columns []int
+	for _, name := range r.columns {
+		ix, err := table.FindColumn(name)
+		if err != nil {
+			return err
+		}
+		columns = append(columns, ix)
+	}
+	err := table.CreateIndex(r.name, r.kind, columns, r.Conn)
+	if err != nil {
+		return err
+	}
+	// logged after the index got created, since the creation of a unique index might fail
+	err = LogStatement(r.Conn, r.Sql, table.CreatedBy(r.Conn))
+	if err != nil {
+		table.DropIndex(r.name)
+		return err
+	}
+	return nil
+}
+
+func (r *GoSqlDropIndexRequest) Exec(args []Value) (Result, error) {
+	schemas := r.Conn.SearchedSchemas()
+	name := r.name.Parts[0]
+	if len(r.name.Parts) > 1 {
+		schemas = r.name.Parts[:1]
+		name = r.name.Parts[1]
+	}
+	var table *GoSqlTable
+	var index *GoSqlIndex
+	for _, schema := range schemas {
+		if table, index = r.Conn.Database.FindIndex(schema, name); table != nil {
+			break
+		}
+	}
+	if table == nil {
+		if r.ifExists == 0 {
+			return &GoSqlResult{-1, 0}, EndStatement(r.BaseData())
+		}
+		AbortStatement(r.BaseData())
+		return nil, fmt.Errorf("index %s does not exist", r.name.Name())
+	}
+	if index.IsConstraint() {
+		AbortStatement(r.BaseData())
+		return nil, fmt.Errorf("cannot drop index %s, the constraint of table %s requires it", name, table.TableName)
+	}
+	err := table.Lock(r.Conn)
+	if err == nil {
+		err = table.CheckAlterable(r.Conn, "DROP INDEX")
+	}
+	if err == nil {
+		err = LogStatement(r.Conn, r.Sql, table.CreatedBy(r.Conn))
+	}
+	if err != nil {
+		AbortStatement(r.BaseData())
+		return nil, err
+	}
+	table.DropIndex(name)
+	return &GoSqlResult{-1, 0}, EndStatement(r.BaseData())
+}
+
+// the table dropped is seen by other transactions, until the one dropping it committed
+func (r *GoSqlDropTableRequest) Exec(args []Value) (Result, error) {
+	schema, name := TableSchema(r.Conn, r.table)
+	err := LockTableName(r.Conn, schema, name)
+	if err != nil {
+		AbortStatement(r.BaseData())
+		return nil, err
+	}
+	table, exists := data.GetTable(r.BaseStatement, GoSqlIdentifier{Parts: []string{schema, name}})
+	if !exists {
+		if r.ifExists == 0 {
+			return &GoSqlResult{-1, 0}, EndStatement(r.BaseData())
+		}
+		AbortStatement(r.BaseData())
+		return nil, fmt.Errorf("Unknown Table %s", r.table.Name())
+	}
+	goSqlTable, ok := table.(*GoSqlTable)
+	if !ok || goSqlTable.IsMaterializedView() {
+		AbortStatement(r.BaseData())
+		return nil, fmt.Errorf("can not drop table %s", r.table.Name())
+	}
+	err = DropTable(r.Conn, goSqlTable)
+	if err == nil {
+		err = LogStatement(r.Conn, r.Sql, true)
+	}
+	if err != nil {
+		AbortStatement(r.BaseData())
+		return nil, err
+	}
+	return &GoSqlResult{-1, 0}, EndStatement(r.BaseData())
+}
+
+// the tuples are deleted like by DELETE, so TRUNCATE is rolled back like it
+func (r *GoSqlTruncateRequest) Exec(args []Value) (Result, error) {
+	table, exists := data.GetTable(r.BaseStatement, r.table)
+	if !exists {
+		return nil, fmt.Errorf("Unknown Table %s", r.table.Name())
+	}
+	goSqlTable, ok := table.(*GoSqlTable)
+	if !ok || goSqlTable.IsMaterializedView() {
+		return nil, fmt.Errorf("can not truncate table %s", r.table.Name())
+	}
+	count, err := goSqlTable.Truncate(r.BaseData())
+	if err != nil {
+		AbortStatement(r.BaseData())
+		return nil, err
+	}
+	return GoSqlResult{-1, count}, EndStatement(r.BaseData())
+}
+
+func init() {
+	ReplayStatement = replayStatement
+	InformationSchemaColumn = informationSchemaColumn
+	ColumnTypeName = columnTypeName
+	ViewTuples = viewTuples
+}
+
+// executes a ddl-statement found in the write-ahead log during recovery
+func replayStatement(sql string, conn *GoSqlConnData) error {
+	parseResult, res := Parse(sql)
+	if res != 0 {
+		return fmt.Errorf("recovery: could not parse %s", sql)
+	}
+	stmt, ok := parseResult.(StatementInterface)
+	if !ok {
+		return fmt.Errorf("recovery: unexpected statement %s", sql)
+	}
+	if createView, ok := stmt.(*GoSqlCreateMaterializedViewRequest); ok {
+		// the tuples it got filled with are replayed
+		createView.populate = false
 	}
-	return &GoSqlResult{-1, 0}, nil
+	stmt.BaseData().Conn = conn
+	stmt.BaseData().Sql = sql
+	_, err := stmt.Exec(nil)
+	return err
 }
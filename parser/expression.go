@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"slices"
 	"time"
 
 	"github.com/aschoerk/go-sql-mem/data"
@@ -19,11 +20,12 @@ type EvaluationContext struct {
 	lastPlaceHolderIndex int            // describes when encountering placeholders from which offset the index to adapt
 	t                    *JoinedRecords // describes the record types
 	resultType           int            // here the evaluation describes the type of the result (INTEGER, FLOAT, VARCHAR, TIMESTAMP)
+	conn                 *GoSqlConnData // of the statement executing the machine, used by the sequence functions
 	// name                 *string
 }
 
 func NewEvaluationContext(args []Value, lastPlaceHolderIndex int) EvaluationContext {
-	return EvaluationContext{NewMachine(args), lastPlaceHolderIndex, nil, -1}
+	return EvaluationContext{NewMachine(args), lastPlaceHolderIndex, nil, -1, nil}
 }
 
 // represents parts of expressions
@@ -41,6 +43,9 @@ func (t *GoSqlTerm) FindPlaceHolders(res []*GoSqlTerm) []*GoSqlTerm {
 	if t.leaf != nil && t.leaf.token == PLACEHOLDER {
 		return append(res, t)
 	}
+	if t.leaf != nil && t.leaf.token == OVER {
+		return t.leaf.ptr.(*GoSqlWindowFunction).placeHolders(res)
+	}
 	if t.left != nil {
 		res = t.left.FindPlaceHolders(res)
 	}
@@ -50,10 +55,56 @@ func (t *GoSqlTerm) FindPlaceHolders(res []*GoSqlTerm) []*GoSqlTerm {
 	return res
 }
 
+// true if a leaf of the term is of the token, like IDENTIFIER or PLACEHOLDER
+func (t *GoSqlTerm) containsLeaf(token int) bool {
+	if t.leaf != nil {
+		return t.leaf.token == token
+	}
+	return t.left != nil && t.left.containsLeaf(token) || t.right != nil && t.right.containsLeaf(token)
+}
+
+// true if an identifier of the term names the column
+func (t *GoSqlTerm) refersTo(column string) bool {
+	if t.leaf != nil {
+		if t.leaf.token != IDENTIFIER {
+			return false
+		}
+		parts := t.leaf.ptr.(GoSqlIdentifier).Parts
+		return parts[len(parts)-1] == column
+	}
+	return t.left != nil && t.left.refersTo(column) || t.right != nil && t.right.refersTo(column)
+}
+
+// a copy of the term, whose identifiers naming the column name it newName
+func (t *GoSqlTerm) renamed(column string, newName string) *GoSqlTerm {
+	if t == nil {
+		return nil
+	}
+	res := &GoSqlTerm{t.operator, t.left.renamed(column, newName), t.right.renamed(column, newName), t.leaf}
+	if t.leaf != nil && t.leaf.token == IDENTIFIER {
+		parts := slices.Clone(t.leaf.ptr.(GoSqlIdentifier).Parts)
+		if parts[len(parts)-1] == column {
+			parts[len(parts)-1] = newName
+			res.leaf = &Ptr{GoSqlIdentifier{Parts: parts}, IDENTIFIER}
+		}
+	}
+	return res
+}
+
 func FindPlaceHoldersInSelect(statement *GoSqlSelectRequest) []*GoSqlTerm {
 	var res = make([]*GoSqlTerm, 0)
+	if statement.with != nil {
+		for _, table := range statement.with.tables {
+			res = append(res, FindPlaceHoldersInSelect(table.query)...)
+		}
+	}
+	if statement.setOperation != nil {
+		res = statement.setOperation.placeHolders(res)
+	}
 	for _, slentry := range statement.selectList {
-		res = slentry.expression.FindPlaceHolders(res)
+		if slentry.expression != nil { // nil for *
+			res = slentry.expression.FindPlaceHolders(res)
+		}
 	}
 	if statement.where != nil {
 		res = statement.where.FindPlaceHolders(res)
@@ -61,6 +112,9 @@ func FindPlaceHoldersInSelect(statement *GoSqlSelectRequest) []*GoSqlTerm {
 	if statement.having != nil {
 		res = statement.having.FindPlaceHolders(res)
 	}
+	for _, term := range statement.limit.terms() {
+		res = term.FindPlaceHolders(res)
+	}
 
 	return res
 }
@@ -122,13 +176,17 @@ func initPlaceHolders(terms []*GoSqlTerm, args []driver.Value, placeHolderOffset
 	}
 }
 
-func Terms2Commands(terms []*GoSqlTerm, args []driver.Value, inputTable *JoinedRecords, placeHolderOffset *int) ([]*EvaluationContext, error) {
+func Terms2Commands(conn *GoSqlConnData, terms []*GoSqlTerm, args []driver.Value, inputTable *JoinedRecords, placeHolderOffset *int) ([]*EvaluationContext, error) {
+	currentPlaceholderIndex := -1
+	if placeHolderOffset != nil {
+		currentPlaceholderIndex = *placeHolderOffset - 1 // the placeholders before the offset belong to terms translated earlier
+	}
 	initPlaceHolders(terms, args, placeHolderOffset)
-	currentPlaceholderIndex := -1 // TODO: check if -1 is right here
 	var res []*EvaluationContext
 	for _, term := range terms {
 		e := NewEvaluationContext(args, currentPlaceholderIndex)
 		e.t = inputTable
+		e.conn = conn
 		resultType, err := term.toMachine(&e)
 		e.resultType = resultType
 		if err != nil {
@@ -217,8 +275,9 @@ func HandleNot(e *EvaluationContext, typeToken int) (int, error) {
 		AddConversion(e.m, IntToBoolean, false)
 	case FLOAT:
 		AddConversion(e.m, FloatToBoolean, false)
-	case BOOLEAN:
+	case STRING:
 		AddConversion(e.m, StringToBoolean, false)
+	case BOOLEAN:
 	default:
 		return -1, fmt.Errorf("unsupported type: %T", typeToken)
 	}
@@ -265,6 +324,9 @@ func (term *GoSqlTerm) handleLeaf(e *EvaluationContext) (int, error) {
 		if err != nil {
 			return -1, err
 		}
+		if p == nil {
+			return NULL, nil
+		}
 		return CategorizePointer(p)
 	}
 	if term.leaf.token == IDENTIFIER {
@@ -281,6 +343,13 @@ func (term *GoSqlTerm) handleLeaf(e *EvaluationContext) (int, error) {
 			return coltype, nil
 		}
 	}
+	if term.leaf.token == CURRENT_TIMESTAMP {
+		e.m.AddCommand(PushCurrentTimestamp)
+		return TIMESTAMP, nil
+	}
+	if term.leaf.token == OVER {
+		return term.leaf.ptr.(*GoSqlWindowFunction).toMachine(e)
+	}
 	AddPushConstant(e.m, term.leaf.ptr)
 	return term.leaf.token, nil
 }
@@ -289,8 +358,12 @@ func (term *GoSqlTerm) toMachine(e *EvaluationContext) (int, error) {
 	if term.leaf != nil {
 		return term.handleLeaf(e)
 	}
+	switch term.operator {
+	case NEXTVAL, CURRVAL, SETVAL:
+		return term.sequenceFunction(e)
+	}
 	if term.right == nil {
-		tmp, err := term.right.toMachine(e)
+		tmp, err := term.left.toMachine(e)
 		if err != nil {
 			return -1, err
 		}
@@ -310,6 +383,9 @@ func (term *GoSqlTerm) toMachine(e *EvaluationContext) (int, error) {
 		if term.left == nil {
 			panic("term left is nil")
 		}
+		if term.operator == BETWEEN {
+			return term.betweenToMachine(e)
+		}
 		leftType, leftError := term.left.toMachine(e)
 
 		if leftError != nil {
@@ -319,32 +395,75 @@ func (term *GoSqlTerm) toMachine(e *EvaluationContext) (int, error) {
 		if rightError != nil {
 			return -1, rightError
 		}
-		newLeftType, newRightType, destType, typeError := commonAndDestType(leftType, rightType, term.operator)
+		return e.addOperation(term.operator, leftType, rightType)
+	}
+}
 
-		if typeError != nil {
-			return -1, typeError
-		}
-		if leftType != newLeftType {
-			c, err := calcConversion(newLeftType, leftType)
-			if err != nil {
-				return -1, err
-			}
-			AddConversion(e.m, c, true)
-		}
-		if rightType != newRightType {
-			c, err := calcConversion(newRightType, rightType)
-			if err != nil {
-				return -1, err
-			}
-			AddConversion(e.m, c, false)
+// x BETWEEN a AND b is calculated as x >= a AND x <= b, x is pushed twice using the same placeholders
+func (term *GoSqlTerm) betweenToMachine(e *EvaluationContext) (int, error) {
+	start := e.lastPlaceHolderIndex
+	leftType, err := term.left.toMachine(e)
+	if err != nil {
+		return -1, err
+	}
+	lowType, err := term.right.left.toMachine(e)
+	if err != nil {
+		return -1, err
+	}
+	_, err = e.addOperation(GREATER_OR_EQUAL, leftType, lowType)
+	if err != nil {
+		return -1, err
+	}
+	end := e.lastPlaceHolderIndex
+	e.lastPlaceHolderIndex = start
+	_, err = term.left.toMachine(e)
+	if err != nil {
+		return -1, err
+	}
+	e.lastPlaceHolderIndex = end
+	highType, err := term.right.right.toMachine(e)
+	if err != nil {
+		return -1, err
+	}
+	_, err = e.addOperation(LESS_OR_EQUAL, leftType, highType)
+	if err != nil {
+		return -1, err
+	}
+	return e.addOperation(AND, BOOLEAN, BOOLEAN)
+}
+
+// adds the conversions and the command of the operator to the operands already pushed
+func (e *EvaluationContext) addOperation(operator int, leftType int, rightType int) (int, error) {
+	// NULL is pushed as nil, the commands handle it as value of the type of the other operand
+	if leftType == NULL {
+		leftType = rightType
+	} else if rightType == NULL {
+		rightType = leftType
+	}
+	newLeftType, newRightType, destType, typeError := commonAndDestType(leftType, rightType, operator)
+	if typeError != nil {
+		return -1, typeError
+	}
+	if leftType != newLeftType {
+		c, err := calcConversion(newLeftType, leftType)
+		if err != nil {
+			return -1, err
 		}
-		c, err := calcOperationCommand(term.operator, destType, newLeftType, newRightType)
+		AddConversion(e.m, c, true)
+	}
+	if rightType != newRightType {
+		c, err := calcConversion(newRightType, rightType)
 		if err != nil {
 			return -1, err
 		}
-		e.m.AddCommand(c)
-		return destType, nil
+		AddConversion(e.m, c, false)
+	}
+	c, err := calcOperationCommand(operator, destType, newLeftType, newRightType)
+	if err != nil {
+		return -1, err
 	}
+	e.m.AddCommand(c)
+	return destType, nil
 }
 
 func calcOperationCommand(opType int, destType int, leftType int, rightType int) (Command, error) {
@@ -568,7 +687,6 @@ func commonAndDestType(a, b int, opType int) (int, int, int, error) {
 			return -1, -1, -1, errors.New("Like needs a string operand")
 		}
 		return STRING, STRING, BOOLEAN, nil
-	// TODO: BETWEEN
 	default:
 		return -1, -1, -1, fmt.Errorf("unsupported operator type: %d", opType)
 	}
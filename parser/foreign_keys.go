@@ -0,0 +1,163 @@
+package parser
+
+import (
+	. "database/sql/driver"
+	"slices"
+
+	"github.com/aschoerk/go-sql-mem/data"
+)
+
+// The foreign keys are enforced by the statements changing tuples, like postgres does by its triggers:
+//   - an inserted tuple and the new version of an updated one lock their parents FOR KEY SHARE, if there is none
+//     visible, the foreign key is violated. A parent inserted by a running transaction is not visible.
+//   - a deleted tuple and one getting another key look for their children and do the referential action of the
+//     foreign key. The children are looked for by a cross check, so the children inserted after the snapshot
+//     of a REPEATABLE READ or SERIALIZABLE transaction are found as well, see data.NewKeyIterator.
+// NO ACTION is handled like RESTRICT, since the checks are not deferred.
+
+// the tuples of the table, whose columns have the values of key, locked by strength
+func findByKey(baseData *data.StatementBaseData, table *data.GoSqlTable, columns []int, key []Value, strength data.LockStrength, crossCheck bool) ([]data.Tuple, error) {
+	lockData := &data.StatementBaseData{Conn: baseData.Conn, State: data.Executing, LockStrength: strength, LockWait: baseData.LockWait}
+	it := table.NewKeyIterator(lockData, true, columns, key, crossCheck)
+	defer it.Close()
+	var res []data.Tuple
+	for {
+		tuple, ok, err := it.Next(func(tuple data.Tuple) (bool, error) {
+			return data.MatchesKey(tuple, columns, key), nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return res, nil
+		}
+		res = append(res, tuple)
+	}
+}
+
+// checks, that the parents referenced by the values of a tuple to be inserted or by the new version of a tuple
+// to be updated exist. old is nil for an insert, except is a foreign key not to be checked.
+func checkParents(baseData *data.StatementBaseData, table *data.GoSqlTable, old data.Tuple, tuple data.Tuple, except *data.GoSqlForeignKey) error {
+	for _, fk := range table.ForeignKeys() {
+		key := data.Key(tuple, fk.Columns)
+		if fk == except || slices.Contains(key, nil) || old != nil && data.MatchesKey(old, fk.Columns, key) {
+			continue
+		}
+		parents, err := findByKey(baseData, fk.Parent, fk.ParentColumns, key, data.LOCK_FOR_KEY_SHARE, false)
+		if err != nil {
+			return err
+		}
+		if len(parents) == 0 {
+			return fk.Violation("insert or update of table %s with key %v", table.TableName, key)
+		}
+	}
+	return nil
+}
+
+// does the referential actions of the foreign keys referencing a tuple to be deleted or to be updated to tuple.
+// tuple is nil for a delete. Tables the transaction does not see, have no tuples it could refer to.
+func referentialActions(baseData *data.StatementBaseData, table *data.GoSqlTable, old data.Tuple, tuple data.Tuple) error {
+	for _, fk := range table.ReferencingKeys() {
+		key := data.Key(old, fk.ParentColumns)
+		if !fk.Child.VisibleFor(baseData.Conn) || slices.Contains(key, nil) || tuple != nil && data.MatchesKey(tuple, fk.ParentColumns, key) {
+			continue
+		}
+		action := fk.OnDelete
+		if tuple != nil {
+			action = fk.OnUpdate
+		}
+		strength := data.LOCK_FOR_NO_KEY_UPDATE
+		switch {
+		case action == data.FK_NO_ACTION || action == data.FK_RESTRICT:
+			strength = data.LOCK_FOR_KEY_SHARE
+		case action == data.FK_CASCADE && tuple == nil || slices.ContainsFunc(fk.Columns, fk.Child.IsKeyColumn):
+			strength = data.LOCK_FOR_UPDATE
+		}
+		children, err := findByKey(baseData, fk.Child, fk.Columns, key, strength, true)
+		if err != nil {
+			return err
+		}
+		if len(children) == 0 {
+			continue
+		}
+		switch action {
+		case data.FK_NO_ACTION, data.FK_RESTRICT:
+			return fk.Violation("update or delete of table %s with key %v", table.TableName, key)
+		case data.FK_CASCADE:
+			if tuple == nil {
+				for _, child := range children {
+					err = referentialActions(baseData, fk.Child, child, nil)
+					if err != nil {
+						return err
+					}
+					fk.Child.Delete(child.Id(), baseData.Conn)
+				}
+				continue
+			}
+			err = updateChildren(baseData, fk, children, data.Key(tuple, fk.ParentColumns), true)
+		case data.FK_SET_NULL:
+			err = updateChildren(baseData, fk, children, make([]Value, len(fk.Columns)), false)
+		case data.FK_SET_DEFAULT:
+			var values []Value
+			values, err = defaultValues(baseData.Conn, fk.Child, fk.Columns)
+			if err == nil {
+				err = updateChildren(baseData, fk, children, values, false)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func defaultValues(conn *data.GoSqlConnData, table *data.GoSqlTable, columns []int) ([]Value, error) {
+	constraints, err := newColumnConstraints(conn, table)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]Value, len(columns))
+	for i, col := range columns {
+		res[i], err = constraints.defaultValue(col)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// sets the referencing columns of the children to values. If cascaded the values are the new key of the parent,
+// which is not visible yet.
+func updateChildren(baseData *data.StatementBaseData, fk *data.GoSqlForeignKey, children []data.Tuple, values []Value, cascaded bool) error {
+	constraints, err := newColumnConstraints(baseData.Conn, fk.Child)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		newChild := child.Clone()
+		for i, col := range fk.Columns {
+			newChild.SetData(0, col, values[i])
+		}
+		err = constraints.check(newChild)
+		if err != nil {
+			return err
+		}
+		var except *data.GoSqlForeignKey
+		if cascaded {
+			except = fk
+		}
+		err = checkParents(baseData, fk.Child, child, newChild, except)
+		if err != nil {
+			return err
+		}
+		err = referentialActions(baseData, fk.Child, child, newChild)
+		if err != nil {
+			return err
+		}
+		_, err = fk.Child.UpdateChecked(child.Id(), newChild, baseData.Conn)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
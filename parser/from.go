@@ -40,6 +40,8 @@ type GoSqlFromHandler struct {
 	fromExprs      []*FromExpr
 	equalJoinParts []equalJoinPart
 	joinedRecord   JoinedRecords
+	views          map[*data.View]data.Table // expanded once per statement
+	commonTables   map[string]*data.TempTable
 }
 
 // describes a table expression in the From-Part including the preceding jointype, if there is one
@@ -67,10 +69,30 @@ type FromExpr struct {
 	tableExprs []*TableExpr
 }
 
-func (g *GoSqlFromHandler) identifyTable(identifier GoSqlAsIdentifier) (*TableExpr, bool) {
+// a view is replaced by the tuples of its select, as seen by the statement. A common table of the WITH of the select
+// hides a table of the same name.
+func (g *GoSqlFromHandler) identifyTable(identifier GoSqlAsIdentifier) (*TableExpr, error) {
 	alias := identifier.Alias
+	if len(identifier.Id.Parts) == 1 {
+		if commonTable, ok := g.commonTables[identifier.Id.Parts[0]]; ok {
+			return &TableExpr{0, commonTable, false, []int{}, alias, nil}, nil
+		}
+	}
 	table, exists := data.GetTable(g.baseStmt, identifier.Id)
-	return &TableExpr{0, table, false, []int{}, alias, nil}, exists
+	if !exists {
+		return nil, fmt.Errorf("tableExpr %v does not exist", identifier)
+	}
+	if view, ok := table.(*data.View); ok {
+		if g.views[view] == nil {
+			expanded, err := view.Expand(g.baseStmt.BaseData())
+			if err != nil {
+				return nil, err
+			}
+			g.views[view] = expanded
+		}
+		table = g.views[view]
+	}
+	return &TableExpr{0, table, false, []int{}, alias, nil}, nil
 }
 
 func (g *GoSqlFromHandler) Init(selectStatement *GoSqlSelectRequest) []error {
@@ -122,6 +144,7 @@ func sortView(arr *TableViewData) {
 // creates the TableViewData for a table, as returned by the TableIterator (so should fit to the current snapshot)
 // sorted!
 func getTableView(cols []int, isOuter bool, it data.TableIterator) (*TableViewData, error) {
+	defer it.Close()
 	var tuples = make([]data.Tuple, 0)
 	for {
 		tuple, found, err := it.Next(func(value data.Tuple) (bool, error) {
@@ -464,9 +487,11 @@ func (g *GoSqlFromHandler) checkAndInitDataStructures(selectStatement *GoSqlSele
 	g.fromExprs = make([]*FromExpr, 0)
 	var asDefinitions = make(map[string]*TableExpr)
 	var tableMap = make(map[data.Table]data.Table)
+	g.views = make(map[*data.View]data.Table)
+	g.commonTables = selectStatement.commonTables
 	idHandler := func(id GoSqlAsIdentifier) *TableExpr {
-		if joinExpr, exists := g.identifyTable(id); !exists {
-			errs = append(errs, fmt.Errorf("tableExpr %v does not exist", id))
+		if joinExpr, err := g.identifyTable(id); err != nil {
+			errs = append(errs, err)
 			return nil
 		} else {
 			fromExpr := g.fromExprs[len(g.fromExprs)-1]
@@ -494,9 +519,11 @@ func (g *GoSqlFromHandler) checkAndInitDataStructures(selectStatement *GoSqlSele
 		idHandler(spec.Id)
 		for _, joinSpec := range spec.JoinSpecs {
 			joinExpr := idHandler(joinSpec.JoinedTable)
-			if joinExpr != nil {
-				joinExpr.joinType = joinSpec.JoinMode
+			if joinExpr == nil {
+				// the error is reported already
+				continue
 			}
+			joinExpr.joinType = joinSpec.JoinMode
 			if joinExpr.joinType == CROSS && joinSpec.JoinCondition != nil {
 				errs = append(errs, fmt.Errorf("cross join condition defined for tableExpr %v", spec.Id))
 			} else {
@@ -555,7 +582,7 @@ func matchIdToJoinExpr(baseStmt data.BaseStatement, id *data.GoSqlIdentifier, ex
 		}
 		return true, colix, nil
 	} else if len(id.Parts) == 2 {
-		if id.Parts[0] == expr.alias || expr.table.Schema() == baseStmt.Conn.CurrentSchema && id.Parts[0] == expr.table.Name() {
+		if id.Parts[0] == expr.alias || slices.Contains(baseStmt.Conn.SearchedSchemas(), expr.table.Schema()) && id.Parts[0] == expr.table.Name() {
 			colix, err := expr.table.FindColumn(id.Parts[1])
 			if err != nil {
 				return false, 0, err
@@ -20,7 +20,7 @@ type GoSqlInsertRequest struct {
 
 func NewInsertRequest(tableName GoSqlIdentifier, columns []string, values [][]*GoSqlTerm) *GoSqlInsertRequest {
 	return &GoSqlInsertRequest{
-		data.BaseStatement{data.StatementBaseData{nil, nil, data.Parsed}},
+		data.BaseStatement{StatementBaseData: data.StatementBaseData{State: data.Parsed}},
 		tableName.Parts[0],
 		columns,
 		values,
@@ -41,9 +41,11 @@ func (r *GoSqlInsertRequest) NumInput() int {
 }
 
 func (r *GoSqlInsertRequest) Exec(args []Value) (Result, error) {
-	table, exists := data.GetTable(r.BaseStatement, GoSqlIdentifier{[]string{r.tableName}})
+	table, exists := data.GetTable(r.BaseStatement, GoSqlIdentifier{Parts: []string{r.tableName}})
 	if !exists {
 		return nil, fmt.Errorf("Unknown Table %s", r.tableName)
+	} else if !isChangeable(table) {
+		return nil, fmt.Errorf("can not insert into table %s", r.tableName)
 	} else {
 		if r.State == Closed {
 			return nil, errors.New("Statement already closed")
@@ -53,9 +55,13 @@ func (r *GoSqlInsertRequest) Exec(args []Value) (Result, error) {
 		var lastInsertedId int64 = -1
 		var rowsAffected int64 = 0
 		if r.State == Parsed {
+			constraints, err := newColumnConstraints(r.Conn, table)
+			if err != nil {
+				return nil, err
+			}
 			for _, insertvalues := range r.values {
 				evaluationContexts := make([]*EvaluationContext, len(table.Columns()))
-				evaluationResults, err := Terms2Commands(insertvalues, args, nil, &placeHolderOffset)
+				evaluationResults, err := Terms2Commands(r.Conn, insertvalues, args, nil, &placeHolderOffset)
 				if err != nil {
 					return nil, err
 				}
@@ -66,7 +72,7 @@ func (r *GoSqlInsertRequest) Exec(args []Value) (Result, error) {
 					})
 					if ix >= 0 { // column handled by insertlist
 						e := evaluationResults[ix]
-						if e.resultType != col.ParserType {
+						if e.resultType != col.ParserType && e.resultType != NULL {
 							conversionCommand, err := calcConversion(col.ColType, e.resultType)
 							if err != nil {
 								return nil, err
@@ -94,13 +100,42 @@ func (r *GoSqlInsertRequest) Exec(args []Value) (Result, error) {
 						switch columnDef.Spec2 {
 						case PRIMARY_AUTOINCREMENT:
 							{
-								id := table.(*GoSqlTable).Increment(columnDef.Name)
+								sequence := table.(*GoSqlTable).ColumnSequence(columnDef.Name)
+								if sequence == nil {
+									AbortStatement(r.BaseData())
+									return nil, fmt.Errorf("column %s of table %s has got no sequence", columnDef.Name, table.Name())
+								}
+								id, err := sequence.NextValue(r.Conn)
+								if err != nil {
+									AbortStatement(r.BaseData())
+									return nil, err
+								}
 								tuple[colix] = id
 							}
+						default:
+							res, err := constraints.defaultValue(colix)
+							if err != nil {
+								AbortStatement(r.BaseData())
+								return nil, err
+							}
+							tuple[colix] = res
 						}
 					}
 				}
-				lastInsertedId = table.(*GoSqlTable).Insert(tuple, r.Conn)
+				err := constraints.check(NewSliceTuple(-1, tuple))
+				if err == nil {
+					err = checkParents(r.BaseData(), table.(*GoSqlTable), nil, NewSliceTuple(-1, tuple), nil)
+				}
+				if err != nil {
+					AbortStatement(r.BaseData())
+					return nil, err
+				}
+				id, err := table.(*GoSqlTable).InsertChecked(tuple, r.Conn)
+				if err != nil {
+					AbortStatement(r.BaseData())
+					return nil, err
+				}
+				lastInsertedId = id
 				rowsAffected++
 			}
 			r.State = Executing
@@ -63,6 +63,9 @@ func (j *JoinedRecords) allIdentifiersAndTypes() ([]data.GoSqlIdentifier, []int)
 	moreThanOne := len(j.tableExpr) > 1
 	for _, table := range j.tableExpr {
 		for _, col := range table.table.Columns() {
+			if col.Hidden {
+				continue // dropped by ALTER TABLE
+			}
 			if table.alias != "" {
 				idRes = append(idRes, data.GoSqlIdentifier{Parts: []string{table.alias, col.Name}})
 			} else {
@@ -115,9 +118,14 @@ func (r *JoinedRecords) identifyId(identifier data.GoSqlIdentifier) (int, int, i
 type JoinedRecordTable struct {
 }
 
-func (r *JoinedRecords) getTableIterator(statement data.BaseStatement) data.TableIterator {
-	if len(r.records) == 0 && len(r.tableExpr) == 1 {
-		return r.tableExpr[0].table.NewIterator(statement.BaseData(), false)
+func (r *JoinedRecords) isSingleTable() bool {
+	return len(r.records) == 0 && len(r.tableExpr) == 1
+}
+
+// forUpdate locks the tuples returned, only possible for single tables, which are scanned by index if where allows
+func (r *JoinedRecords) getTableIterator(statement data.BaseStatement, forUpdate bool, where *GoSqlTerm) data.TableIterator {
+	if r.isSingleTable() {
+		return r.newTableIterator(statement.BaseData(), forUpdate, where)
 	}
 	var viewColumns []data.GoSqlColumn
 	for _, e := range r.tableExpr {
@@ -140,10 +148,19 @@ func (j *JoinedRecordsIterator) GetTable() data.Table {
 }
 
 func (j *JoinedRecordsIterator) Next(f func(tuple data.Tuple) (bool, error)) (data.Tuple, bool, error) {
-	if j.ix < len(j.j.records) {
+	for j.ix < len(j.j.records) {
+		candidate := &JoinedRecord{j.j.records[j.ix]}
 		j.ix++
-		return &JoinedRecord{j.j.records[j.ix-1]}, true, nil
-	} else {
-		return nil, false, nil
+		found, err := f(candidate)
+		if err != nil {
+			return nil, false, err
+		}
+		if found {
+			return candidate, true, nil
+		}
 	}
+	return nil, false, nil
 }
+
+// the records got joined already, the iterators of their tables are done
+func (j *JoinedRecordsIterator) Close() {}
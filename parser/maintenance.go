@@ -0,0 +1,40 @@
+package parser
+
+import (
+	. "database/sql/driver"
+	"fmt"
+
+	"github.com/aschoerk/go-sql-mem/data"
+)
+
+type GoSqlCheckpointRequest struct {
+	data.BaseStatement
+}
+
+type GoSqlVacuumRequest struct {
+	data.BaseStatement
+	table data.GoSqlIdentifier // all tables if empty
+}
+
+func (r *GoSqlCheckpointRequest) Exec(args []Value) (Result, error) {
+	err := r.Conn.Database.Checkpoint()
+	if err != nil {
+		return nil, err
+	}
+	return GoSqlResult{-1, 0}, nil
+}
+
+func (r *GoSqlVacuumRequest) Exec(args []Value) (Result, error) {
+	if len(r.table.Parts) == 0 {
+		return GoSqlResult{-1, r.Conn.Database.VacuumAll()}, nil
+	}
+	table, exists := data.GetTable(r.BaseStatement, r.table)
+	if !exists {
+		return nil, fmt.Errorf("Unknown Table %s", r.table.Name())
+	}
+	goSqlTable, ok := table.(*data.GoSqlTable)
+	if !ok {
+		return nil, fmt.Errorf("can not vacuum table %s", r.table.Name())
+	}
+	return GoSqlResult{-1, goSqlTable.Vacuum()}, nil
+}
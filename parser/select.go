@@ -15,18 +15,39 @@ import (
 
 type GoSqlSelectRequest struct {
 	data.BaseStatement
-	allDistinct int
-	selectList  []SelectListEntry
-	from        []*GoSqlFromSpec
-	where       *GoSqlTerm
-	groupBy     []*GoSqlTerm
-	having      *GoSqlTerm
-	orderBy     []GoSqlOrderBy
-	forupdate   int
+	allDistinct  int
+	selectList   []SelectListEntry
+	from         []*GoSqlFromSpec
+	where        *GoSqlTerm
+	groupBy      []*GoSqlTerm
+	having       *GoSqlTerm
+	orderBy      []GoSqlOrderBy
+	limit        GoSqlLimit
+	rowLock      GoSqlRowLock
+	setOperation *GoSqlSetOperation // combining the tuples of two selects, instead of selecting from tables
+	with         *GoSqlWith
+	commonTables map[string]*data.TempTable // materialized common tables visible to the select, by name
 }
 
+// Exec executes the query and reads its rows, RowsAffected returns their number
 func (r *GoSqlSelectRequest) Exec(args []Value) (Result, error) {
-	panic("not implemented")
+	rows, err := r.Query(args)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	dest := make([]Value, len(rows.Columns()))
+	var count int64 = 0
+	for {
+		err = rows.Next(dest)
+		if err == io.EOF {
+			return GoSqlResult{-1, count}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		count++
+	}
 }
 
 type SLName struct {
@@ -50,7 +71,8 @@ func extractAggregation(t *GoSqlTerm) ([]*GoSqlTerm, bool) {
 	}
 	usesIdentifiers := false
 	if t.leaf != nil {
-		usesIdentifiers = t.leaf.token == IDENTIFIER
+		// like an attribute, a window function has got a value per tuple
+		usesIdentifiers = t.leaf.token == IDENTIFIER || t.leaf.token == OVER
 	}
 	var res []*GoSqlTerm = nil
 	if t.left != nil {
@@ -73,6 +95,9 @@ func extractAggregation(t *GoSqlTerm) ([]*GoSqlTerm, bool) {
 func collectAggregation(r *GoSqlSelectRequest) ([]AggTermsBySelectListEntry, error) {
 	var aggregationsTerms []AggTermsBySelectListEntry
 	for ix, sl := range r.selectList {
+		if sl.Asterisk { // no aggregation, all columns
+			continue
+		}
 		res, usesIdentifiers := extractAggregation(sl.expression)
 		if res != nil && len(res) > 0 {
 			if usesIdentifiers {
@@ -120,7 +145,7 @@ func buildAggregationSelectList(r *GoSqlSelectRequest) ([]*GoSqlTerm, []SLName,
 					if t.operator != COUNT {
 						return nil, nil, nil, errors.New("expecting only COUNT alias function if parameter is asterisk")
 					} else {
-						res = append(res, &GoSqlTerm{-1, nil, nil, &Ptr{data.GoSqlIdentifier{[]string{data.VersionedRecordId}}, IDENTIFIER}})
+						res = append(res, &GoSqlTerm{-1, nil, nil, &Ptr{data.GoSqlIdentifier{Parts: []string{data.VersionedRecordId}}, IDENTIFIER}})
 						resNames = append(resNames, SLName{name, false})
 					}
 				}
@@ -180,6 +205,15 @@ func buildSelectList(table *JoinedRecords, r *GoSqlSelectRequest) ([]*GoSqlTerm,
 
 func (r *GoSqlSelectRequest) Query(args []Value) (Rows, error) {
 	if r.State == data.Created {
+		// the placeholders of the common tables come first
+		var err error
+		args, err = r.materializeWith(args)
+		if err != nil {
+			return nil, err
+		}
+		if r.setOperation != nil {
+			return r.querySetOperation(args)
+		}
 		fromHandler := GoSqlFromHandler{}
 		errs := fromHandler.Init(r)
 		if errs != nil && len(errs) > 0 {
@@ -195,7 +229,6 @@ func (r *GoSqlSelectRequest) Query(args []Value) (Rows, error) {
 		r.State = data.Parsed
 		// determine source-tuple ()
 		// create machines
-		termNum := len(r.selectList)
 
 		var whereExecutionContext = -1
 		var havingExecutionContext = -1
@@ -212,7 +245,13 @@ func (r *GoSqlSelectRequest) Query(args []Value) (Rows, error) {
 			}
 		}
 
+		windows, err := r.windowFunctions()
+		if err != nil {
+			return nil, err
+		}
+
 		sizeSelectList = len(terms)
+		termNum := len(terms) // behind the select list and the columns added for ORDER BY or *
 		if r.where != nil {
 			terms = append(terms, r.where)
 			whereExecutionContext = termNum
@@ -223,18 +262,50 @@ func (r *GoSqlSelectRequest) Query(args []Value) (Rows, error) {
 			havingExecutionContext = termNum
 			termNum++
 		}
+		limitExecutionContext := len(terms)
+		terms = append(terms, r.limit.terms()...)
 		placeHolderOffset := 0
 		var evaluationContexts []*EvaluationContext
-		evaluationResults, err := Terms2Commands(terms, args, &joinedRecord, &placeHolderOffset)
+		evaluationResults, err := Terms2Commands(r.Conn, terms, args, &joinedRecord, &placeHolderOffset)
 		if err != nil {
 			return nil, err
 		}
 		evaluationContexts = append(evaluationContexts, evaluationResults...)
-		it := joinedRecord.getTableIterator(r.BaseStatement)
-		temptable, err := r.createAndFillTempTable(r, it, evaluationContexts, args, &names, whereExecutionContext, havingExecutionContext, sizeSelectList, r.forupdate)
+		offset, count, err := r.limitValues(evaluationContexts[limitExecutionContext:], args)
 		if err != nil {
 			return nil, err
 		}
+		// an aggregation limits the tuple aggregated, not the ones scanned
+		scanOffset, scanCount := offset, count
+		if len(aggTermsBySelectListEntry) != 0 {
+			scanOffset, scanCount = 0, -1
+		}
+		if r.rowLock.strength != 0 {
+			if len(aggTermsBySelectListEntry) != 0 {
+				return nil, errors.New("FOR UPDATE and FOR SHARE are not allowed with aggregate functions")
+			}
+			if !joinedRecord.isSingleTable() {
+				return nil, errors.New("FOR UPDATE and FOR SHARE are only supported for single tables")
+			}
+		}
+		r.LockStrength = r.rowLock.lockStrength()
+		r.LockWait = r.rowLock.wait
+		it := joinedRecord.getTableIterator(r.BaseStatement, r.rowLock.strength != 0, r.where)
+		var stage *windowStage
+		if len(windows) > 0 {
+			stage = newWindowStage(windows)
+		}
+		temptable, err := r.createAndFillTempTable(r, it, evaluationContexts, args, &names, whereExecutionContext, havingExecutionContext, sizeSelectList, r.rowLock.strength, scanOffset, scanCount, stage)
+		if err != nil {
+			return nil, err
+		}
+		if r.rowLock.strength != 0 {
+			// in autocommit mode the locks are released at once
+			err = data.EndStatement(r.BaseData())
+			if err != nil {
+				return nil, err
+			}
+		}
 
 		if len(aggTermsBySelectListEntry) == 0 {
 			r.State = data.Executing
@@ -278,11 +349,11 @@ func (r *GoSqlSelectRequest) Query(args []Value) (Rows, error) {
 				terms = append(terms, aTerm.sl.expression)
 				names = append(names, SLName{fmt.Sprintf("%d", ix), false})
 			}
-			evaluationResults, err := Terms2Commands(terms, args, nil, nil) // TODO: fix placeholderhandling when group or agg
+			evaluationResults, err := Terms2Commands(r.Conn, terms, args, nil, nil) // TODO: fix placeholderhandling when group or agg
 			if err != nil {
 				return nil, err
 			}
-			aggTmpTable := createTempTable(evaluationResults, &names, len(names))
+			aggTmpTable := createTempTable(r.Conn.Database, evaluationResults, &names, len(names))
 			var resTuple []Value
 			for _, ev := range evaluationResults {
 				res, err := ev.m.Execute(args, data.NULL_TUPLE, data.NULL_TUPLE)
@@ -293,6 +364,7 @@ func (r *GoSqlSelectRequest) Query(args []Value) (Rows, error) {
 			}
 			tempTable := aggTmpTable
 			*tempTable.Data() = append(*tempTable.Data(), resTuple)
+			*tempTable.Data() = limitTuples(*tempTable.Data(), offset, count)
 			r.State = data.Executing
 			return &GoSqlRows{r, aggTmpTable.Name(), &names, 0}, nil
 		}
@@ -561,14 +633,14 @@ func doCount(table data.Table, aggTermCount int, term *GoSqlTerm) error {
 	return nil
 }
 
-func createTempTable(evaluationContexts []*EvaluationContext, names *[]SLName, sizeSelectList int) data.Table {
+func createTempTable(db *data.Database, evaluationContexts []*EvaluationContext, names *[]SLName, sizeSelectList int) data.Table {
 	var cols []data.GoSqlColumn
 	for ix, execution := range evaluationContexts {
 		if ix < sizeSelectList {
-			cols = append(cols, data.GoSqlColumn{(*names)[ix].name, execution.resultType, execution.resultType, 0, 0, (*names)[ix].hidden})
+			cols = append(cols, data.GoSqlColumn{Name: (*names)[ix].name, ColType: execution.resultType, ParserType: execution.resultType, Hidden: (*names)[ix].hidden})
 		}
 	}
-	return data.NewTempTable(cols)
+	return db.NewTempTable(cols)
 }
 
 func (r *GoSqlSelectRequest) createAndFillTempTable(
@@ -580,10 +652,39 @@ func (r *GoSqlSelectRequest) createAndFillTempTable(
 	whereExecutionContext int,
 	havingExecutionContext int,
 	sizeSelectList int,
-	forUpdate int) (data.Table, error) {
-
-	tempTable := createTempTable(evaluationContexts, names, sizeSelectList)
+	forUpdate int,
+	offset int64,
+	count int64,
+	windows *windowStage) (data.Table, error) {
+
+	// the scan might stop before the iterator found the last tuple
+	defer it.Close()
+	tempTable := createTempTable(r.Conn.Database, evaluationContexts, names, sizeSelectList)
+	var compare func(a, b []Value) int
+	if query.orderBy != nil {
+		e, err := OrderBy2Commands(&query.orderBy, tempTable)
+		if err != nil {
+			return nil, err
+		}
+		compare = func(a, b []Value) int {
+			res, err := e.m.Execute(args, data.NewSliceTuple(-1, a), data.NewSliceTuple(-1, b))
+			if err != nil {
+				panic(err.Error())
+			}
+			return res.(int)
+		}
+	}
+	// without ORDER BY the scan skips the tuples before offset and stops after the last one returned, with ORDER BY
+	// only the first offset + count tuples in order are kept
+	bound := int64(-1)
+	if count >= 0 && offset <= math.MaxInt64-count {
+		bound = offset + count
+	}
+	skipped := int64(0)
 	for {
+		if compare == nil && count >= 0 && int64(len(*tempTable.Data())) >= count {
+			break
+		}
 		tuple, ok, err := it.Next(func(tupleData data.Tuple) (bool, error) {
 			if whereExecutionContext != -1 {
 				whereCheck := evaluationContexts[whereExecutionContext]
@@ -612,35 +713,109 @@ func (r *GoSqlSelectRequest) createAndFillTempTable(
 			query.State = data.EndOfRows
 			break
 		}
-		err = calcTuple(tempTable, args, evaluationContexts, tuple, sizeSelectList)
+		if windows != nil {
+			// the select list is evaluated once the window functions are computed over all tuples
+			err = windows.add(tuple)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if compare == nil && skipped < offset {
+			skipped++
+			continue
+		}
+		err = calcTuple(tempTable, args, evaluationContexts, tuple, data.NULL_TUPLE, sizeSelectList)
 		if err != nil {
 			return nil, err
 		}
+		if compare != nil && bound >= 0 {
+			*tempTable.Data() = keepFirst(*tempTable.Data(), compare, bound)
+		}
 	}
-	if query.orderBy != nil {
-		e, err := OrderBy2Commands(&query.orderBy, tempTable)
+	if windows != nil {
+		err := windows.fill(tempTable, args, evaluationContexts, sizeSelectList)
 		if err != nil {
 			return nil, err
 		}
-		slices.SortFunc(
-			*tempTable.Data(),
-			func(a, b []Value) int {
-				res, err := e.m.Execute(args, data.NewSliceTuple(-1, a), data.NewSliceTuple(-1, b))
-				if err != nil {
-					panic(err.Error())
-				}
-				return res.(int)
-			},
-		)
+		if compare == nil {
+			*tempTable.Data() = limitTuples(*tempTable.Data(), offset, count)
+		}
+	}
+	if compare != nil {
+		if bound < 0 || windows != nil {
+			slices.SortFunc(*tempTable.Data(), compare)
+		}
+		*tempTable.Data() = limitTuples(*tempTable.Data(), offset, count)
 	}
 	return tempTable, nil
 }
 
-func calcTuple(tempTable data.Table, args []Value, evaluationContexts []*EvaluationContext, tuple data.Tuple, sizeSelectList int) error {
+// sorts the tuple appended last into the ones before, which are in order, and keeps the first bound of them
+func keepFirst(tuples [][]Value, compare func(a, b []Value) int, bound int64) [][]Value {
+	last := len(tuples) - 1
+	tuple := tuples[last]
+	// behind the ones equal to it
+	ix, _ := slices.BinarySearchFunc(tuples[:last], tuple, func(a, b []Value) int {
+		if compare(a, b) <= 0 {
+			return -1
+		}
+		return 1
+	})
+	copy(tuples[ix+1:], tuples[ix:last])
+	tuples[ix] = tuple
+	if int64(len(tuples)) > bound {
+		tuples = tuples[:bound]
+	}
+	return tuples
+}
+
+// the tuples left after skipping offset ones, at most count of them unless count is negative
+func limitTuples(tuples [][]Value, offset int64, count int64) [][]Value {
+	if offset >= int64(len(tuples)) {
+		return nil
+	}
+	tuples = tuples[offset:]
+	if count >= 0 && count < int64(len(tuples)) {
+		tuples = tuples[:count]
+	}
+	return tuples
+}
+
+// the offset and count of the limit evaluated by their machines, count is -1 if all tuples are returned
+func (r *GoSqlSelectRequest) limitValues(evaluationContexts []*EvaluationContext, args []Value) (int64, int64, error) {
+	offset, count := int64(0), int64(-1)
+	for ix, term := range r.limit.terms() {
+		res, err := evaluationContexts[ix].m.Execute(args, data.NULL_TUPLE, data.NULL_TUPLE)
+		if err != nil {
+			return 0, 0, err
+		}
+		name := "LIMIT"
+		if term == r.limit.offset {
+			name = "OFFSET"
+		}
+		value, ok := res.(int64)
+		if !ok {
+			return 0, 0, fmt.Errorf("argument of %s must be an integer", name)
+		}
+		if value < 0 {
+			return 0, 0, fmt.Errorf("%s must not be negative", name)
+		}
+		if term == r.limit.offset {
+			offset = value
+		} else {
+			count = value
+		}
+	}
+	return offset, count, nil
+}
+
+// windowValues holds the values of the window functions computed for the tuple
+func calcTuple(tempTable data.Table, args []Value, evaluationContexts []*EvaluationContext, tuple data.Tuple, windowValues data.Tuple, sizeSelectList int) error {
 	var destTuple []Value
 	for ix, execution := range evaluationContexts {
 		if ix < sizeSelectList {
-			res, err := execution.m.Execute(args, tuple, data.NULL_TUPLE)
+			res, err := execution.m.Execute(args, tuple, windowValues)
 			if err != nil {
 				return err
 			} else {
@@ -670,17 +845,17 @@ func (rows *GoSqlRows) Columns() []string {
 }
 
 func (rows *GoSqlRows) ResultTable() data.Table {
-	table := data.GetTempTable(rows.temptableName)
+	table := rows.query.Conn.Database.GetTempTable(rows.temptableName)
 	return table
 }
 
 func (rows *GoSqlRows) Close() error {
-	data.DeleteTempTable(rows.temptableName)
+	rows.query.Conn.Database.DeleteTempTable(rows.temptableName)
 	return nil
 }
 
 func (rows *GoSqlRows) Next(dest []Value) error {
-	table := data.GetTempTable(rows.temptableName)
+	table := rows.query.Conn.Database.GetTempTable(rows.temptableName)
 	if len(*table.Data()) <= rows.tableix {
 		rows.query.State = data.EndOfRows
 		return io.EOF
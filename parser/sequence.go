@@ -0,0 +1,183 @@
+package parser
+
+import (
+	. "database/sql/driver"
+	"fmt"
+	"math"
+
+	"github.com/aschoerk/go-sql-mem/data"
+	. "github.com/aschoerk/go-sql-mem/data"
+	. "github.com/aschoerk/go-sql-mem/machine"
+)
+
+type GoSqlCreateSequenceRequest struct {
+	data.BaseStatement
+	ifExists int
+	name     GoSqlIdentifier
+	options  []GoSqlSequenceOption
+}
+
+type GoSqlDropSequenceRequest struct {
+	data.BaseStatement
+	ifExists int
+	name     GoSqlIdentifier
+}
+
+var sequenceFunctionNames = map[int]string{NEXTVAL: "nextval", CURRVAL: "currval", SETVAL: "setval"}
+
+// the columns a sequence is read with
+func sequenceColumns() []GoSqlColumn {
+	constraints := GoSqlColumnConstraints{-1, true, nil, nil, nil}
+	return []GoSqlColumn{NewColumn("last_value", INTEGER, 0, constraints), NewColumn("is_called", BOOLEAN, 0, constraints)}
+}
+
+// the sequence is seen by other transactions, after the one creating it committed
+func (r *GoSqlCreateSequenceRequest) Exec(args []Value) (Result, error) {
+	schema, name := TableSchema(r.Conn, r.name)
+	err := LockTableName(r.Conn, schema, name)
+	if err != nil {
+		AbortStatement(r.BaseData())
+		return nil, err
+	}
+	_, exists := data.GetTable(r.BaseStatement, GoSqlIdentifier{Parts: []string{schema, name}})
+	if exists {
+		if r.ifExists == 1 {
+			return &GoSqlResult{-1, 0}, EndStatement(r.BaseData())
+		}
+		AbortStatement(r.BaseData())
+		return nil, fmt.Errorf("relation %s already exists", r.name.Name())
+	}
+	sequence, err := r.sequence(schema, name)
+	if err == nil {
+		err = LogStatement(r.Conn, r.Sql, true)
+	}
+	if err != nil {
+		AbortStatement(r.BaseData())
+		return nil, err
+	}
+	CreateSequence(r.Conn, sequence)
+	return &GoSqlResult{-1, 0}, EndStatement(r.BaseData())
+}
+
+// the options not given default like in postgres: an ascending sequence counts from 1, a descending one from -1
+func (r *GoSqlCreateSequenceRequest) sequence(schema string, name string) (*Sequence, error) {
+	options := make(map[int]GoSqlSequenceOption)
+	given := make(map[int]bool)
+	for _, option := range r.options {
+		if given[option.option] {
+			return nil, fmt.Errorf("conflicting or redundant options of sequence %s", name)
+		}
+		given[option.option] = true
+		if !option.none {
+			options[option.option] = option
+		}
+	}
+	increment := int64(1)
+	if option, ok := options[INCREMENT]; ok {
+		increment = option.value
+	}
+	minValue, maxValue := int64(1), int64(math.MaxInt64)
+	if increment < 0 {
+		minValue, maxValue = math.MinInt64, -1
+	}
+	if option, ok := options[MINVALUE]; ok {
+		minValue = option.value
+	}
+	if option, ok := options[MAXVALUE]; ok {
+		maxValue = option.value
+	}
+	start := minValue
+	if increment < 0 {
+		start = maxValue
+	}
+	if option, ok := options[START]; ok {
+		start = option.value
+	}
+	return NewSequence(schema, name, sequenceColumns(), start, increment, minValue, maxValue, options[CYCLE].value == 1)
+}
+
+// the sequence dropped is seen by other transactions, until the one dropping it committed
+func (r *GoSqlDropSequenceRequest) Exec(args []Value) (Result, error) {
+	schema, name := TableSchema(r.Conn, r.name)
+	err := LockTableName(r.Conn, schema, name)
+	if err != nil {
+		AbortStatement(r.BaseData())
+		return nil, err
+	}
+	table, exists := data.GetTable(r.BaseStatement, GoSqlIdentifier{Parts: []string{schema, name}})
+	if !exists {
+		if r.ifExists == 0 {
+			return &GoSqlResult{-1, 0}, EndStatement(r.BaseData())
+		}
+		AbortStatement(r.BaseData())
+		return nil, fmt.Errorf("sequence %s does not exist", r.name.Name())
+	}
+	sequence, ok := table.(*Sequence)
+	if !ok {
+		AbortStatement(r.BaseData())
+		return nil, fmt.Errorf("%s is not a sequence", r.name.Name())
+	}
+	err = DropSequence(r.Conn, sequence)
+	if err == nil {
+		err = LogStatement(r.Conn, r.Sql, true)
+	}
+	if err != nil {
+		AbortStatement(r.BaseData())
+		return nil, err
+	}
+	return &GoSqlResult{-1, 0}, EndStatement(r.BaseData())
+}
+
+// nextval(name), currval(name) and setval(name, value [, is_called]). The sequence is looked up by its name, when the
+// function gets called, with the connection of the statement.
+func (term *GoSqlTerm) sequenceFunction(e *EvaluationContext) (int, error) {
+	function := sequenceFunctionNames[term.operator]
+	if e.conn == nil {
+		return -1, fmt.Errorf("%s is not allowed here", function)
+	}
+	args := []*GoSqlTerm{term.left}
+	if term.right != nil {
+		args = append(args, term.right.left)
+		if term.right.right != nil {
+			args = append(args, term.right.right)
+		}
+	}
+	for ix, argType := range []int{STRING, INTEGER, BOOLEAN}[:len(args)] {
+		resultType, err := args[ix].toMachine(e)
+		if err != nil {
+			return -1, err
+		}
+		if resultType != argType {
+			return -1, fmt.Errorf("invalid type of argument %d of %s", ix+1, function)
+		}
+	}
+	conn := e.conn
+	operator := term.operator
+	AddFunction(e.m, len(args), func(values []Value) (Value, error) {
+		if values[0] == nil {
+			return nil, nil
+		}
+		sequence, err := FindSequence(conn, values[0].(string))
+		if err != nil {
+			return nil, err
+		}
+		var value int64
+		switch operator {
+		case NEXTVAL:
+			value, err = sequence.NextValue(conn)
+		case CURRVAL:
+			value, err = sequence.CurrentValue(conn)
+		default:
+			var ok bool
+			if value, ok = values[1].(int64); !ok {
+				return nil, fmt.Errorf("setval: invalid value %v", values[1])
+			}
+			err = sequence.SetValue(conn, value, len(values) < 3 || values[2] == true)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return value, nil
+	})
+	return INTEGER, nil
+}
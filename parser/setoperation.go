@@ -0,0 +1,208 @@
+package parser
+
+import (
+	. "database/sql/driver"
+	"fmt"
+	"slices"
+
+	. "github.com/aschoerk/go-sql-mem/data"
+	. "github.com/aschoerk/go-sql-mem/machine"
+)
+
+// UNION, INTERSECT or EXCEPT of two selects, the select holding it returns the combined tuples. Its ORDER BY and
+// LIMIT apply to them, they name the columns of the left select.
+type GoSqlSetOperation struct {
+	operator int // UNION, INTERSECT or EXCEPT
+	all      bool
+	left     *GoSqlSelectRequest
+	right    *GoSqlSelectRequest
+}
+
+func NewSetOperation(operator int, all bool, left *GoSqlSelectRequest, right *GoSqlSelectRequest) *GoSqlSelectRequest {
+	return &GoSqlSelectRequest{NewStatementBaseData(), ALL, nil, nil, nil, nil, nil, nil, GoSqlLimit{nil, nil, false},
+		GoSqlRowLock{0, LOCK_WAIT}, &GoSqlSetOperation{operator, all, left, right}, nil, nil}
+}
+
+func (o *GoSqlSetOperation) name() string {
+	switch o.operator {
+	case INTERSECT:
+		return "INTERSECT"
+	case EXCEPT:
+		return "EXCEPT"
+	default:
+		return "UNION"
+	}
+}
+
+// the tuples of the set operation, ordered and limited like the select holding it requests
+func (r *GoSqlSelectRequest) querySetOperation(args []Value) (Rows, error) {
+	if r.rowLock.strength != 0 {
+		return nil, fmt.Errorf("FOR UPDATE and FOR SHARE are not allowed with %s", r.setOperation.name())
+	}
+	columns, tuples, err := r.setOperation.tuples(r, args)
+	if err != nil {
+		return nil, err
+	}
+	// the placeholders of the limit follow the ones of the selects
+	limitArgs := args[len(r.setOperation.placeHolders(nil)):]
+	placeHolderOffset := 0
+	evaluationContexts, err := Terms2Commands(r.Conn, r.limit.terms(), limitArgs, nil, &placeHolderOffset)
+	if err != nil {
+		return nil, err
+	}
+	offset, count, err := r.limitValues(evaluationContexts, limitArgs)
+	if err != nil {
+		return nil, err
+	}
+	var cols []GoSqlColumn
+	var names []SLName
+	for _, column := range columns {
+		cols = append(cols, GoSqlColumn{Name: column.Name, ColType: column.ParserType, ParserType: column.ParserType})
+		names = append(names, SLName{column.Name, false})
+	}
+	tempTable := r.Conn.Database.NewTempTable(cols)
+	if r.orderBy != nil {
+		e, err := OrderBy2Commands(&r.orderBy, tempTable)
+		if err != nil {
+			r.Conn.Database.DeleteTempTable(tempTable.Name())
+			return nil, err
+		}
+		slices.SortFunc(tuples, func(a, b []Value) int {
+			res, err := e.m.Execute(args, NewSliceTuple(-1, a), NewSliceTuple(-1, b))
+			if err != nil {
+				panic(err.Error())
+			}
+			return res.(int)
+		})
+	}
+	*tempTable.Data() = limitTuples(tuples, offset, count)
+	r.State = Executing
+	return &GoSqlRows{r, tempTable.Name(), &names, 0}, nil
+}
+
+// the placeholders of both selects in the order they are written
+func (o *GoSqlSetOperation) placeHolders(res []*GoSqlTerm) []*GoSqlTerm {
+	res = append(res, FindPlaceHoldersInSelect(o.left)...)
+	return append(res, FindPlaceHoldersInSelect(o.right)...)
+}
+
+// the columns named like the ones of the left select and the tuples combined, the values of both selects are
+// converted to the common type of their columns
+func (o *GoSqlSetOperation) tuples(query *GoSqlSelectRequest, args []Value) ([]GoSqlColumn, [][]Value, error) {
+	leftArgs := len(FindPlaceHoldersInSelect(o.left))
+	leftColumns, leftTuples, err := operandTuples(query, o.left, args[:leftArgs])
+	if err != nil {
+		return nil, nil, err
+	}
+	rightColumns, rightTuples, err := operandTuples(query, o.right, args[leftArgs:])
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(leftColumns) != len(rightColumns) {
+		return nil, nil, fmt.Errorf("each %s query must have the same number of columns", o.name())
+	}
+	columns := slices.Clone(leftColumns)
+	for ix, left := range leftColumns {
+		right := rightColumns[ix]
+		if left.ParserType == right.ParserType {
+			continue
+		}
+		common, _, _, err := commonAndDestType(left.ParserType, right.ParserType, EQUAL)
+		if err == nil {
+			err = convertColumn(leftTuples, ix, common, left.ParserType)
+		}
+		if err == nil {
+			err = convertColumn(rightTuples, ix, common, right.ParserType)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s types %s and %s cannot be matched", o.name(), columnTypeName(left),
+				columnTypeName(right))
+		}
+		colType := common
+		if colType == STRING {
+			colType = TEXT
+		}
+		columns[ix] = NewColumn(left.Name, colType, 0, GoSqlColumnConstraints{-1, false, nil, nil, nil})
+	}
+	return columns, o.combine(leftTuples, rightTuples), nil
+}
+
+// the visible columns and the tuples of an operand, executed for the statement of the query
+func operandTuples(query *GoSqlSelectRequest, operand *GoSqlSelectRequest, args []Value) ([]GoSqlColumn, [][]Value, error) {
+	operand.commonTables = query.commonTables
+	if operand.setOperation != nil {
+		return operand.setOperation.tuples(query, args)
+	}
+	operand.StatementBaseData = StatementBaseData{Conn: query.Conn, State: Created, Sql: query.Sql, LockStrength: LOCK_FOR_UPDATE, LockWait: LOCK_WAIT, Views: query.Views}
+	return selectTuples(operand, args)
+}
+
+// converts the values of the column from type orgType to destType
+func convertColumn(tuples [][]Value, ix int, destType int, orgType int) error {
+	if destType == orgType {
+		return nil
+	}
+	conversion, err := calcConversion(destType, orgType)
+	if err != nil {
+		return err
+	}
+	m := NewMachine(nil)
+	AddPushPlaceHolder(m, 0)
+	m.AddCommand(conversion)
+	for _, values := range tuples {
+		if values[ix] == nil {
+			continue
+		}
+		values[ix], err = m.Execute([]Value{values[ix]}, NULL_TUPLE, NULL_TUPLE)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// the tuples of the set operation, duplicates are removed unless it is ALL. Tuples equal in all values including
+// NULLs are duplicates.
+func (o *GoSqlSetOperation) combine(left [][]Value, right [][]Value) [][]Value {
+	var res [][]Value
+	if o.operator == UNION {
+		res = append(left, right...)
+		if !o.all {
+			res = distinctTuples(res)
+		}
+		return res
+	}
+	// the number of times the tuples occur in right, which are not yet matched
+	inRight := make(map[string]int)
+	for _, values := range right {
+		inRight[ValuesKey(values)]++
+	}
+	for _, values := range left {
+		key := ValuesKey(values)
+		matched := inRight[key] > 0
+		if matched && o.all {
+			inRight[key]--
+		}
+		if matched == (o.operator == INTERSECT) {
+			res = append(res, values)
+		}
+	}
+	if !o.all {
+		res = distinctTuples(res)
+	}
+	return res
+}
+
+// the first of the tuples equal to each other, in their order
+func distinctTuples(tuples [][]Value) [][]Value {
+	seen := make(map[string]bool)
+	var res [][]Value
+	for _, values := range tuples {
+		key := ValuesKey(values)
+		if !seen[key] {
+			seen[key] = true
+			res = append(res, values)
+		}
+	}
+	return res
+}
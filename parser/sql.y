@@ -7,6 +7,7 @@ package parser
 
 import (
     "bufio"
+    "strconv"
     "strings"
     "time"
     . "github.com/aschoerk/go-sql-mem/data"
@@ -24,8 +25,6 @@ type yyLexerEx interface {
 
 %union{
     value float64
-    column GoSqlColumn
-    columns []GoSqlColumn
     int int
     boolean bool
     token int
@@ -50,26 +49,61 @@ type yyLexerEx interface {
     parseResult driver.Stmt
     identifier GoSqlIdentifier
     identifier_list []string
-    joined_table *GoSqlJoinedTable
-    table_reference *GoSqlTableReference
+    fromSpec *GoSqlFromSpec
+    fromSpecs []*GoSqlFromSpec
+    asIdentifier GoSqlAsIdentifier
+    rowLock GoSqlRowLock
+    tableElements GoSqlTableElements
+    columnConstraints GoSqlColumnConstraints
+    foreignKey GoSqlForeignKeySpec
+    alterTableAction GoSqlAlterTableAction
+    referentialAction ReferentialAction
+    indexKind IndexKind
+    lockWait LockWaitPolicy
+    sequenceOptions []GoSqlSequenceOption
+    sequenceOption GoSqlSequenceOption
+    limit GoSqlLimit
+    with *GoSqlWith
+    commonTables []*GoSqlCommonTable
+    commonTable *GoSqlCommonTable
+    window GoSqlWindow
+    windowOrder GoSqlWindowOrder
+    windowOrders []GoSqlWindowOrder
+    frame *GoSqlFrame
+    frameBound GoSqlFrameBound
 }
 
 // DDL
 %token CREATE DATABASE SCHEMA ALTER TABLE ADD AS IF NOT EXISTS PRIMARY KEY AUTOINCREMENT POPEN PCLOSE COMMA
 %token ON
+%token CHECKPOINT VACUUM
+%token DROP INDEX UNIQUE TRUNCATE
+%token DEFAULT CHECK CURRENT_TIMESTAMP
+%token REFERENCES FOREIGN RESTRICT CASCADE NO ACTION
+%token COLUMN RENAME TYPE
+%token VIEW REPLACE MATERIALIZED REFRESH CONCURRENTLY
+%token SEQUENCE INCREMENT MINVALUE MAXVALUE START WITH CYCLE NEXTVAL CURRVAL SETVAL
+%token RECURSIVE
+%token OVER PARTITION RANGE UNBOUNDED PRECEDING FOLLOWING CURRENT
+%token ROW_NUMBER RANK DENSE_RANK LAG LEAD FIRST_VALUE LAST_VALUE
 %token <token> CHAR VARCHAR INTEGER FLOAT TEXT BOOLEAN TIMESTAMP FOR
 // DML
-%token SELECT DISTINCT ALL FROM WHERE GROUP BY HAVING ORDER ASC DESC UNION BETWEEN BETWEEN_AND AND IN INSERT UPDATE SET DELETE INTO VALUES
+%token SELECT DISTINCT ALL FROM WHERE GROUP BY HAVING ORDER ASC DESC UNION INTERSECT EXCEPT BETWEEN BETWEEN_AND AND IN INSERT UPDATE SET DELETE INTO VALUES
 %token LESS_OR_EQUAL GREATER_OR_EQUAL NOT_EQUAL PLUS MINUS LESS EQUAL GREATER ASTERISK DIVIDE AND OR LIKE MOD
 %token NUM ISNULL ISNOTNULL NULL IS 
 %token <token> COUNT SUM AVG MIN MAX
 %token <int> BEGIN_TOKEN COMMIT ROLLBACK TRANSACTION AUTOCOMMIT ON OFF
+%token SAVEPOINT RELEASE TO USE
+%token NOWAIT SKIP LOCKED SHARE
+%token LIMIT OFFSET FETCH FIRST NEXT ROW ROWS ONLY
 %token <int> DECIMAL_INTEGER_NUMBER POSITIVE_DECIMAL_INTEGER_NUMBER 
 %token <string> IDENTIFIER PLACEHOLDER STRING
 %token <float64> FLOATING_POINT_NUMBER
 %token <time> TIME_STAMP
 %token <boolean> TRUE, FALSE
 
+%left UNION EXCEPT
+%left INTERSECT
 %left JOIN INNER CROSS LEFT RIGHT FULL OUTER NATURAL
 %left IS
 %left IN
@@ -87,28 +121,48 @@ type yyLexerEx interface {
 
 
 
-%type <column> column
-%type <columns> columns
-%type <fieldList> field_list
+%type <tableElements> table_elements column table_constraint
+%type <foreignKey> references referential_actions
+%type <referentialAction> referential_action
+%type <alterTableAction> alter_table_action
+%type <fieldList> field_list opt_view_columns
 %type <termLists> term_lists
 
-%type <token> column_type aggregate_function_name
-%type <int>  opt_column_length column_specification2 if_exists_predicate distinct_all
+%type <token> column_type aggregate_function_name window_function_name frame_unit
+%type <int>  opt_column_length if_exists_predicate if_exists distinct_all sequence_number
+%type <sequenceOptions> sequence_options
+%type <sequenceOption> sequence_option
+%type <boolean> opt_drop_behavior opt_or_replace opt_concurrently opt_all opt_recursive
+%type <columnConstraints> column_constraints
+%type <indexKind> opt_unique
 %type <ptr> const_expression like_term 
-%type <termList> term_list opt_group_by 
-%type <parseResult> statement ddl_statement dml_statement create_table insert update delete connection_level
-%type <selectStatement> select
+%type <termList> term_list opt_group_by opt_term_list opt_partition_by
+%type <window> window_spec
+%type <windowOrder> window_order
+%type <windowOrders> window_order_list opt_window_order_by
+%type <frame> opt_frame
+%type <frameBound> frame_bound
+%type <parseResult> statement ddl_statement dml_statement create_table create_index alter_table insert update delete connection_level maintenance_statement
+%type <selectStatement> select query_expression simple_select
+%type <with> opt_with_clause
+%type <commonTables> common_tables
+%type <commonTable> common_table
 %type <selectList> select_list
 %type <selectListEntry> select_list_entry
-%type <string> select_list_entry_alias
+%type <string> select_list_entry_alias set_value name non_reserved_keyword name
 %type <term> term nonboolean_term opt_where opt_having aggregate_function_parameter
 %type <orderByEntry> order_by_entry
 %type <orderByEntryList> order_by_entry_list opt_order_by
-%type <token> order_by_direction opt_for_update
+%type <token> order_by_direction
+%type <rowLock> opt_for_update
+%type <limit> opt_limit
+%type <term> limit_value opt_limit_value opt_offset opt_fetch_first fetch_first
+%type <lockWait> opt_lock_wait
 %type <updateSpec> update_spec
 %type <updateSpecs> update_specs
-%type <joined_table> joined_table
-%type <table_reference> table_reference
+%type <fromSpec> joined_table
+%type <fromSpecs> from_list
+%type <asIdentifier> table_reference
 %type <token> outer_join_type
 %type <token> join_type
 %type <identifier> identifier
@@ -127,6 +181,16 @@ statement: ddl_statement
             { $$ = $1 }
         | dml_statement
             { $$ = $1 }
+        | maintenance_statement
+            { $$ = $1 }
+
+maintenance_statement:
+        CHECKPOINT
+            { $$ = &GoSqlCheckpointRequest{NewStatementBaseData()} }
+        | VACUUM
+            { $$ = &GoSqlVacuumRequest{NewStatementBaseData(), GoSqlIdentifier{}} }
+        | VACUUM identifier
+            { $$ = &GoSqlVacuumRequest{NewStatementBaseData(), $2} }
 
 ddl_statement:
         CREATE DATABASE if_exists_predicate identifier
@@ -135,26 +199,188 @@ ddl_statement:
             { $$ = &GoSqlCreateSchemaRequest{NewStatementBaseData(), $3, $4} }
         | create_table
             { $$ = $1 }
+        | create_index
+            { $$ = $1 }
+        | alter_table
+            { $$ = $1 }
+        | DROP INDEX if_exists_predicate identifier
+            { $$ = &GoSqlDropIndexRequest{NewStatementBaseData(), $3, $4} }
+        | DROP TABLE if_exists_predicate identifier
+            { $$ = &GoSqlDropTableRequest{NewStatementBaseData(), $3, $4} }
+        | TRUNCATE opt_table identifier
+            { $$ = &GoSqlTruncateRequest{NewStatementBaseData(), $3} }
+        | DROP SCHEMA if_exists_predicate identifier opt_drop_behavior
+            { $$ = &GoSqlDropSchemaRequest{NewStatementBaseData(), $3, $4, $5} }
+        | DROP DATABASE if_exists_predicate identifier
+            { $$ = &GoSqlDropDatabaseRequest{NewStatementBaseData(), $3, $4} }
+        | CREATE opt_or_replace VIEW identifier opt_view_columns AS select
+            { $$ = &GoSqlCreateViewRequest{NewStatementBaseData(), $2, $4, $5, $7} }
+        | DROP VIEW if_exists_predicate identifier
+            { $$ = &GoSqlDropViewRequest{NewStatementBaseData(), $3, $4, false} }
+        | CREATE MATERIALIZED VIEW if_exists_predicate identifier opt_view_columns AS select
+            { $$ = &GoSqlCreateMaterializedViewRequest{GoSqlCreateViewRequest{NewStatementBaseData(), false, $5, $6, $8}, $4, true} }
+        | REFRESH MATERIALIZED VIEW opt_concurrently identifier
+            { $$ = &GoSqlRefreshMaterializedViewRequest{NewStatementBaseData(), $4, $5} }
+        | DROP MATERIALIZED VIEW if_exists_predicate identifier
+            { $$ = &GoSqlDropViewRequest{NewStatementBaseData(), $4, $5, true} }
+        | CREATE SEQUENCE if_exists_predicate identifier sequence_options
+            { $$ = &GoSqlCreateSequenceRequest{NewStatementBaseData(), $3, $4, $5} }
+        | DROP SEQUENCE if_exists_predicate identifier
+            { $$ = &GoSqlDropSequenceRequest{NewStatementBaseData(), $3, $4} }
 
 
 create_table:
-    CREATE TABLE if_exists_predicate identifier POPEN columns PCLOSE
+    CREATE TABLE if_exists_predicate identifier POPEN table_elements PCLOSE
         {
-        $$ = &GoSqlCreateTableRequest {NewStatementBaseData(),$3, NewTable($4, $6) }
+        $$ = NewCreateTableRequest($3, $4, $6)
         }
 
-columns: column
-       { $$ = []GoSqlColumn{$1}}
-    | columns COMMA column
-      { $$ = append($1, $3) }
-
-column: IDENTIFIER column_type opt_column_length column_specification2
-    { $$ = NewColumn($1, $2, $3, $4) }
+create_index:
+    CREATE opt_unique INDEX if_exists_predicate name ON identifier POPEN field_list PCLOSE
+        {
+        $$ = &GoSqlCreateIndexRequest {NewStatementBaseData(), $4, $2, $5, $7, $9 }
+        }
 
+alter_table:
+    ALTER TABLE identifier alter_table_action
+        {
+        $$ = &GoSqlAlterTableRequest {NewStatementBaseData(), $3, $4 }
+        }
 
-column_specification2: /* EMPTY */
-    { $$ = -1}
-    | PRIMARY KEY AUTOINCREMENT { $$ = PRIMARY_AUTOINCREMENT }
+/* COLUMN is optional, but can be the name of a column too. ALTER column TYPE would be ambiguous, without COLUMN
+   the keywords can not be used as name there. */
+alter_table_action:
+      ADD COLUMN if_exists_predicate column
+        { $$ = GoSqlAlterTableAction{ADD, $3, "", "", $4, 0, 0} }
+    | ADD column
+        { $$ = GoSqlAlterTableAction{ADD, -1, "", "", $2, 0, 0} }
+    | ADD if_exists column
+        { $$ = GoSqlAlterTableAction{ADD, $2, "", "", $3, 0, 0} }
+    | DROP COLUMN if_exists_predicate name
+        { $$ = GoSqlAlterTableAction{DROP, $3, $4, "", GoSqlTableElements{}, 0, 0} }
+    | DROP name
+        { $$ = GoSqlAlterTableAction{DROP, -1, $2, "", GoSqlTableElements{}, 0, 0} }
+    | DROP if_exists name
+        { $$ = GoSqlAlterTableAction{DROP, $2, $3, "", GoSqlTableElements{}, 0, 0} }
+    | RENAME COLUMN name TO name
+        { $$ = GoSqlAlterTableAction{RENAME, -1, $3, $5, GoSqlTableElements{}, 0, 0} }
+    | RENAME name TO name
+        { $$ = GoSqlAlterTableAction{RENAME, -1, $2, $4, GoSqlTableElements{}, 0, 0} }
+    | ALTER COLUMN name TYPE column_type opt_column_length
+        { $$ = GoSqlAlterTableAction{ALTER, -1, $3, "", GoSqlTableElements{}, $5, $6} }
+    | ALTER IDENTIFIER TYPE column_type opt_column_length
+        { $$ = GoSqlAlterTableAction{ALTER, -1, $2, "", GoSqlTableElements{}, $4, $5} }
+    | RENAME TO name
+        { $$ = GoSqlAlterTableAction{TABLE, -1, "", $3, GoSqlTableElements{}, 0, 0} }
+
+opt_table: /* EMPTY */
+    | TABLE
+
+opt_drop_behavior:
+        { $$ = false }
+    | RESTRICT
+        { $$ = false }
+    | CASCADE
+        { $$ = true }
+
+opt_or_replace:
+        { $$ = false }
+    | OR REPLACE
+        { $$ = true }
+
+opt_concurrently:
+        { $$ = false }
+    | CONCURRENTLY
+        { $$ = true }
+
+opt_view_columns:
+        { $$ = nil }
+    | POPEN field_list PCLOSE
+        { $$ = $2 }
+
+sequence_options: /* EMPTY */
+        { $$ = nil }
+    | sequence_options sequence_option
+        { $$ = append($1, $2) }
+
+sequence_option: INCREMENT opt_by sequence_number
+        { $$ = GoSqlSequenceOption{INCREMENT, int64($3), false} }
+    | MINVALUE sequence_number
+        { $$ = GoSqlSequenceOption{MINVALUE, int64($2), false} }
+    | NO MINVALUE
+        { $$ = GoSqlSequenceOption{MINVALUE, 0, true} }
+    | MAXVALUE sequence_number
+        { $$ = GoSqlSequenceOption{MAXVALUE, int64($2), false} }
+    | NO MAXVALUE
+        { $$ = GoSqlSequenceOption{MAXVALUE, 0, true} }
+    | START opt_with sequence_number
+        { $$ = GoSqlSequenceOption{START, int64($3), false} }
+    | CYCLE
+        { $$ = GoSqlSequenceOption{CYCLE, 1, false} }
+    | NO CYCLE
+        { $$ = GoSqlSequenceOption{CYCLE, 0, false} }
+
+opt_by: /* EMPTY */
+    | BY
+
+opt_with: /* EMPTY */
+    | WITH
+
+sequence_number: POSITIVE_DECIMAL_INTEGER_NUMBER
+    | MINUS POSITIVE_DECIMAL_INTEGER_NUMBER
+        { $$ = -$2 }
+
+opt_unique:
+        { $$ = PLAIN_INDEX }
+    | UNIQUE
+        { $$ = UNIQUE_INDEX }
+
+table_elements: column
+    | table_constraint
+    | table_elements COMMA column
+      { $$ = $1.append($3) }
+    | table_elements COMMA table_constraint
+      { $$ = $1.append($3) }
+
+table_constraint: PRIMARY KEY POPEN field_list PCLOSE
+      { $$ = GoSqlTableElements{nil, []GoSqlKey{{true, $4}}, nil} }
+    | UNIQUE POPEN field_list PCLOSE
+      { $$ = GoSqlTableElements{nil, []GoSqlKey{{false, $3}}, nil} }
+    | FOREIGN KEY POPEN field_list PCLOSE references
+      { $6.columns = $4; $$ = GoSqlTableElements{nil, nil, []GoSqlForeignKeySpec{$6}} }
+
+references: REFERENCES identifier referential_actions
+      { $$ = $3; $$.parent = $2 }
+    | REFERENCES identifier POPEN field_list PCLOSE referential_actions
+      { $$ = $6; $$.parent = $2; $$.parentColumns = $4 }
+
+referential_actions: /* EMPTY */
+      { $$ = GoSqlForeignKeySpec{} }
+    | referential_actions ON DELETE referential_action
+      { $$ = $1; $$.onDelete = $4 }
+    | referential_actions ON UPDATE referential_action
+      { $$ = $1; $$.onUpdate = $4 }
+
+referential_action: NO ACTION { $$ = FK_NO_ACTION }
+    | RESTRICT { $$ = FK_RESTRICT }
+    | CASCADE { $$ = FK_CASCADE }
+    | SET NULL { $$ = FK_SET_NULL }
+    | SET DEFAULT { $$ = FK_SET_DEFAULT }
+
+column: name column_type opt_column_length column_constraints
+    { $$ = NewColumnElements($1, $2, $3, $4) }
+
+
+column_constraints: /* EMPTY */
+    { $$ = GoSqlColumnConstraints{-1, false, nil, nil, nil} }
+    | column_constraints PRIMARY KEY AUTOINCREMENT { $$ = $1; $$.spec2 = PRIMARY_AUTOINCREMENT }
+    | column_constraints PRIMARY KEY { $$ = $1; $$.spec2 = PRIMARY_KEY_COLUMN }
+    | column_constraints UNIQUE { $$ = $1; $$.spec2 = UNIQUE_COLUMN }
+    | column_constraints NOT NULL { $$ = $1; $$.notNull = true }
+    | column_constraints NULL { $$ = $1; $$.notNull = false }
+    | column_constraints DEFAULT nonboolean_term { $$ = $1; $$.defaultValue = $3 }
+    | column_constraints CHECK POPEN term PCLOSE { $$ = $1; $$.check = $4 }
+    | column_constraints references { $$ = $1; references := $2; $$.references = &references }
 
 column_type: INTEGER | TEXT | VARCHAR | BOOLEAN | TIMESTAMP | FLOAT
 
@@ -164,7 +390,10 @@ opt_column_length:
 
 if_exists_predicate: /* empty */
         { $$ = -1 }
-    | IF EXISTS
+    | if_exists
+
+if_exists:
+      IF EXISTS
         { $$ = 0 }
     | IF NOT EXISTS
         { $$ = 1 }
@@ -183,24 +412,51 @@ dml_statement:
 
 connection_level:
       BEGIN_TOKEN
-      { $$ = NewConnectionLevelRequest($1,-1)}
+      { $$ = NewConnectionLevelRequest(BEGIN_TOKEN,-1)}
     | BEGIN_TOKEN TRANSACTION
-      { $$ = NewConnectionLevelRequest($1,-1)}
+      { $$ = NewConnectionLevelRequest(BEGIN_TOKEN,-1)}
     | COMMIT
-      { $$ = NewConnectionLevelRequest($1,-1)}
+      { $$ = NewConnectionLevelRequest(COMMIT,-1)}
     | COMMIT TRANSACTION
-      { $$ = NewConnectionLevelRequest($1,-1)}
+      { $$ = NewConnectionLevelRequest(COMMIT,-1)}
     | ROLLBACK
-      { $$ = NewConnectionLevelRequest($1,-1)}
+      { $$ = NewConnectionLevelRequest(ROLLBACK,-1)}
     | ROLLBACK TRANSACTION
-      { $$ = NewConnectionLevelRequest($1,-1)}
+      { $$ = NewConnectionLevelRequest(ROLLBACK,-1)}
     | SET AUTOCOMMIT ON
-      { $$ = NewConnectionLevelRequest($2,$3)}
+      { $$ = NewConnectionLevelRequest(AUTOCOMMIT,ON)}
     | SET AUTOCOMMIT OFF
-      { $$ = NewConnectionLevelRequest($2,$3)}
+      { $$ = NewConnectionLevelRequest(AUTOCOMMIT,OFF)}
+    | SET name EQUAL set_value
+      { $$ = NewSetRequest($2, $4)}
+    | SET name TO set_value
+      { $$ = NewSetRequest($2, $4)}
+    | SET SCHEMA set_value
+      { $$ = NewSetRequest("search_path", $3)}
+    | USE name
+      { $$ = NewSetRequest("search_path", $2)}
+    | SAVEPOINT name
+      { $$ = NewSavepointRequest(SAVEPOINT, -1, $2)}
+    | RELEASE name
+      { $$ = NewSavepointRequest(RELEASE, -1, $2)}
+    | RELEASE SAVEPOINT name
+      { $$ = NewSavepointRequest(RELEASE, -1, $3)}
+    | ROLLBACK TO name
+      { $$ = NewSavepointRequest(ROLLBACK, SAVEPOINT, $3)}
+    | ROLLBACK TO SAVEPOINT name
+      { $$ = NewSavepointRequest(ROLLBACK, SAVEPOINT, $4)}
+    | ROLLBACK TRANSACTION TO SAVEPOINT name
+      { $$ = NewSavepointRequest(ROLLBACK, SAVEPOINT, $5)}
+
+set_value: POSITIVE_DECIMAL_INTEGER_NUMBER
+      { $$ = strconv.Itoa($1)}
+    | STRING
+      { $$ = $1}
+    | field_list
+      { $$ = strings.Join($1, ",")}
 
 delete: DELETE FROM table_reference opt_where
-    { $$ = &GoSqlDeleteRequest{NewStatementBaseData(),$3, $4}}            
+    { $$ = &GoSqlDeleteRequest{NewStatementBaseData(), []*GoSqlFromSpec{{$3, nil}}, $4}}
 
 update: UPDATE table_reference SET update_specs opt_where
     { $$ = NewUpdateRequest($2, $4, $5) }
@@ -215,15 +471,59 @@ update_spec: identifier EQUAL term
    { $$ = GoSqlUpdateSpec{ $1, $3 }}
 
 
-select: SELECT 
+select: opt_with_clause
+        query_expression
+        opt_order_by
+        opt_limit
+        opt_for_update
+  {
+    $$ = $2
+    $$.with = $1
+    $$.orderBy = $3
+    $$.limit = $4
+    $$.rowLock = $5
+  }
+
+opt_with_clause:
+   { $$ = nil }
+  | WITH opt_recursive common_tables
+   { $$ = &GoSqlWith{$2, $3} }
+
+opt_recursive:
+   { $$ = false }
+  | RECURSIVE
+   { $$ = true }
+
+common_tables: common_table
+   { $$ = []*GoSqlCommonTable{$1} }
+  | common_tables COMMA common_table
+   { $$ = append($1, $3) }
+
+common_table: IDENTIFIER opt_view_columns AS POPEN select PCLOSE
+   { $$ = &GoSqlCommonTable{$1, $2, $5} }
+
+query_expression: simple_select
+  | query_expression UNION opt_all query_expression
+  { $$ = NewSetOperation(UNION, $3, $1, $4) }
+  | query_expression INTERSECT opt_all query_expression
+  { $$ = NewSetOperation(INTERSECT, $3, $1, $4) }
+  | query_expression EXCEPT opt_all query_expression
+  { $$ = NewSetOperation(EXCEPT, $3, $1, $4) }
+
+simple_select: SELECT 
           distinct_all select_list
-        FROM table_reference
+        FROM from_list
         opt_where 
         opt_group_by 
         opt_having
-        opt_order_by
-        opt_for_update
-  { $$ = &GoSqlSelectRequest { NewStatementBaseData(), $2, $3, $5, $6, $7, $8, $9, $10 }}
+  { $$ = &GoSqlSelectRequest { NewStatementBaseData(), $2, $3, $5, $6, $7, $8, nil, GoSqlLimit{nil, nil, false}, GoSqlRowLock{0, LOCK_WAIT}, nil, nil, nil }}
+
+opt_all:
+   { $$ = false }
+  | ALL
+   { $$ = true }
+  | DISTINCT
+   { $$ = false }
 
 distinct_all: 
    { $$ = ALL }
@@ -246,35 +546,33 @@ select_list_entry:
 
 select_list_entry_alias: 
     { $$ = "" }
-    | AS IDENTIFIER
+    | AS name
     { $$ = $2}
 
 table_reference:
     identifier
-    { $$ = &GoSqlTableReference{Id: $1} }
-    | POPEN select PCLOSE
-    { $$ = &GoSqlTableReference{Select: $2} }
-    | POPEN joined_table PCLOSE
-    { $$ = &GoSqlTableReference{JoinedTable: $2} }
-    | table_reference AS IDENTIFIER
-    {
-      $1.As = $3
-      $$ = $1
-    }
-    | table_reference IDENTIFIER
-    {
-          $1.As = $2
-          $$ = $1
-    }
+    { $$ = GoSqlAsIdentifier{$1, ""} }
+    | identifier AS name
+    { $$ = GoSqlAsIdentifier{$1, $3} }
+    | identifier name
+    { $$ = GoSqlAsIdentifier{$1, $2} }
+    ;
+
+from_list:
+    joined_table
+    { $$ = []*GoSqlFromSpec{$1} }
+    | from_list COMMA joined_table
+    { $$ = append($1, $3) }
     ;
 
 joined_table:
     table_reference
-    { $$ = nil }
-    | joined_table COMMA table_reference
-    { $$ = nil }
+    { $$ = &GoSqlFromSpec{$1, nil} }
     | joined_table join_type JOIN table_reference join_specification
-    { $$ = nil }
+    {
+      $1.JoinSpecs = append($1.JoinSpecs, GoSqlJoinSpec{$2, $4, $5})
+      $$ = $1
+    }
     ;
 
 join_specification:
@@ -330,8 +628,22 @@ nonboolean_term:
     { $$ = &GoSqlTerm{ DIVIDE, $1, $3, nil }}
   | term MOD term
     { $$ = &GoSqlTerm{ MOD, $1, $3, nil }}
+  | CURRENT_TIMESTAMP
+    { $$ = &GoSqlTerm{-1, nil, nil, &Ptr{nil, CURRENT_TIMESTAMP}} }
   |  aggregate_function_name POPEN aggregate_function_parameter PCLOSE
     { $$ = &GoSqlTerm{$1, $3, nil, nil} }
+  | aggregate_function_name POPEN aggregate_function_parameter PCLOSE OVER POPEN window_spec PCLOSE
+    { $$ = NewWindowAggregate($1, $3, $7) }
+  | window_function_name POPEN opt_term_list PCLOSE OVER POPEN window_spec PCLOSE
+    { $$ = NewWindowFunction($1, $3, $7) }
+  | NEXTVAL POPEN term PCLOSE
+    { $$ = &GoSqlTerm{NEXTVAL, $3, nil, nil} }
+  | CURRVAL POPEN term PCLOSE
+    { $$ = &GoSqlTerm{CURRVAL, $3, nil, nil} }
+  | SETVAL POPEN term COMMA term PCLOSE
+    { $$ = &GoSqlTerm{SETVAL, $3, &GoSqlTerm{-1, $5, nil, nil}, nil} }
+  | SETVAL POPEN term COMMA term COMMA term PCLOSE
+    { $$ = &GoSqlTerm{SETVAL, $3, &GoSqlTerm{-1, $5, $7, nil}, nil} }
 
 aggregate_function_name: 
     COUNT
@@ -346,6 +658,68 @@ aggregate_function_name:
     { $$ = MAX }
 
 
+window_function_name:
+    ROW_NUMBER
+    { $$ = ROW_NUMBER }
+  | RANK
+    { $$ = RANK }
+  | DENSE_RANK
+    { $$ = DENSE_RANK }
+  | LAG
+    { $$ = LAG }
+  | LEAD
+    { $$ = LEAD }
+  | FIRST_VALUE
+    { $$ = FIRST_VALUE }
+  | LAST_VALUE
+    { $$ = LAST_VALUE }
+
+window_spec: opt_partition_by opt_window_order_by opt_frame
+    { $$ = GoSqlWindow{$1, $2, $3} }
+
+opt_partition_by:
+    { $$ = nil }
+  | PARTITION BY term_list
+    { $$ = $3 }
+
+opt_window_order_by:
+    { $$ = nil }
+  | ORDER BY window_order_list
+    { $$ = $3 }
+
+window_order_list: window_order
+    { $$ = []GoSqlWindowOrder{$1} }
+  | window_order_list COMMA window_order
+    { $$ = append($1, $3) }
+
+window_order: term order_by_direction
+    { $$ = GoSqlWindowOrder{$1, $2} }
+
+opt_frame:
+    { $$ = nil }
+  | frame_unit frame_bound
+    { $$ = &GoSqlFrame{$1, $2, GoSqlFrameBound{CURRENT, false, 0}} }
+  | frame_unit BETWEEN frame_bound BETWEEN_AND frame_bound
+    { $$ = &GoSqlFrame{$1, $3, $5} }
+
+frame_unit:
+    ROWS
+    { $$ = ROWS }
+  | RANGE
+    { $$ = RANGE }
+
+frame_bound:
+    UNBOUNDED PRECEDING
+    { $$ = GoSqlFrameBound{PRECEDING, true, 0} }
+  | POSITIVE_DECIMAL_INTEGER_NUMBER PRECEDING
+    { $$ = GoSqlFrameBound{PRECEDING, false, int64($1)} }
+  | CURRENT ROW
+    { $$ = GoSqlFrameBound{CURRENT, false, 0} }
+  | POSITIVE_DECIMAL_INTEGER_NUMBER FOLLOWING
+    { $$ = GoSqlFrameBound{FOLLOWING, false, int64($1)} }
+  | UNBOUNDED FOLLOWING
+    { $$ = GoSqlFrameBound{FOLLOWING, true, 0} }
+
 aggregate_function_parameter:  
     distinct_all term 
     { $$ = &GoSqlTerm{$1, $2, nil, nil} }
@@ -402,10 +776,60 @@ opt_order_by:
   | ORDER BY order_by_entry_list
   { $$ = $3}
 
+opt_limit:
+  { $$ = GoSqlLimit{nil, nil, false} }
+  | LIMIT limit_value opt_offset
+  { $$ = GoSqlLimit{$2, $3, false} }
+  | LIMIT ALL opt_offset
+  { $$ = GoSqlLimit{nil, $3, false} }
+  | OFFSET limit_value opt_row_rows opt_fetch_first
+  { $$ = GoSqlLimit{$4, $2, true} }
+  | fetch_first
+  { $$ = GoSqlLimit{$1, nil, false} }
+
+opt_offset:
+  { $$ = nil }
+  | OFFSET limit_value opt_row_rows
+  { $$ = $2 }
+
+opt_fetch_first:
+  { $$ = nil }
+  | fetch_first
+
+fetch_first: FETCH first_next opt_limit_value row_rows ONLY
+  { $$ = $3 }
+
+first_next: FIRST | NEXT
+
+row_rows: ROW | ROWS
+
+opt_row_rows:
+  | row_rows
+
+opt_limit_value:
+  { $$ = &GoSqlTerm{-1, nil, nil, &Ptr{int64(1), INTEGER}} }
+  | limit_value
+
+limit_value: POSITIVE_DECIMAL_INTEGER_NUMBER
+  { $$ = &GoSqlTerm{-1, nil, nil, &Ptr{int64($1), INTEGER}} }
+  | PLACEHOLDER
+  { $$ = &GoSqlTerm{-1, nil, nil, &Ptr{$1, PLACEHOLDER}} }
+
 opt_for_update:
-  { $$ = 0 }
-  | FOR UPDATE
-  { $$ = $1 }
+  { $$ = GoSqlRowLock{0, LOCK_WAIT} }
+  | FOR UPDATE opt_lock_wait
+  { $$ = GoSqlRowLock{UPDATE, $3} }
+  | FOR SHARE opt_lock_wait
+  { $$ = GoSqlRowLock{SHARE, $3} }
+  | FOR KEY SHARE opt_lock_wait
+  { $$ = GoSqlRowLock{KEY, $4} }
+
+opt_lock_wait:
+  { $$ = LOCK_WAIT }
+  | NOWAIT
+  { $$ = LOCK_NOWAIT }
+  | SKIP LOCKED
+  { $$ = LOCK_SKIP_LOCKED }
 
 
 order_by_direction:
@@ -415,15 +839,60 @@ order_by_direction:
   | DESC
     { $$ = DESC}
 
-identifier_list:
+/* the keywords, which are not reserved, can be used as names */
+name:
    IDENTIFIER
+   | non_reserved_keyword
+
+non_reserved_keyword:
+   COLUMN
+   { $$ = "column" }
+   | TYPE
+   { $$ = "type" }
+   | START
+   { $$ = "start" }
+   | INCREMENT
+   { $$ = "increment" }
+   | CYCLE
+   { $$ = "cycle" }
+   | FIRST
+   { $$ = "first" }
+   | NEXT
+   { $$ = "next" }
+   | ROW
+   { $$ = "row" }
+   | ROWS
+   { $$ = "rows" }
+   | ONLY
+   { $$ = "only" }
+   | RANGE
+   { $$ = "range" }
+   | CURRENT
+   { $$ = "current" }
+   | ROW_NUMBER
+   { $$ = "row_number" }
+   | RANK
+   { $$ = "rank" }
+   | DENSE_RANK
+   { $$ = "dense_rank" }
+   | LAG
+   { $$ = "lag" }
+   | LEAD
+   { $$ = "lead" }
+   | FIRST_VALUE
+   { $$ = "first_value" }
+   | LAST_VALUE
+   { $$ = "last_value" }
+
+identifier_list:
+   name
    { $$ = []string{$1} }
-   | identifier_list DOT IDENTIFIER
+   | identifier_list DOT name
    { $$ = append($1,$3) }
 
 identifier:
    identifier_list
-   { $$ = GoSqlIdentifier{$1} }
+   { $$ = GoSqlIdentifier{Parts: $1} }
 
 order_by_entry: identifier order_by_direction
         { $$ = GoSqlOrderBy {$1, $2 } }
@@ -451,9 +920,9 @@ term_lists: POPEN term_list PCLOSE
   | term_lists COMMA POPEN term_list PCLOSE
    { $$ = append($1,$4) }
 
-field_list: IDENTIFIER
+field_list: name
         { $$ = []string{$1} }
-    | field_list COMMA IDENTIFIER
+    | field_list COMMA name
         { $$ = append($1, $3) }
 
 term_list: term
@@ -461,6 +930,10 @@ term_list: term
     | term_list COMMA term
     { $$ = append($1, $3)}
 
+opt_term_list:
+    { $$ = nil }
+    | term_list
+
 const_expression:
     PLACEHOLDER
     { $$ = &Ptr {$1,PLACEHOLDER} }
@@ -470,12 +943,20 @@ const_expression:
     { $$ = &Ptr {int64($1),INTEGER} }
     | POSITIVE_DECIMAL_INTEGER_NUMBER
     { $$ = &Ptr {int64($1),INTEGER} }
+    | MINUS POSITIVE_DECIMAL_INTEGER_NUMBER
+    { $$ = &Ptr {-int64($2),INTEGER} }
     | FLOATING_POINT_NUMBER
     { $$ = &Ptr {$1,FLOAT} }
+    | MINUS FLOATING_POINT_NUMBER
+    { $$ = &Ptr {-$2,FLOAT} }
     | STRING
     { $$ = &Ptr {$1,STRING} }
     | TIME_STAMP
     { $$ = &Ptr {$1,TIMESTAMP} }
+    | BOOLEAN
+    { $$ = &Ptr {$<boolean>1,BOOLEAN} }
+    | NULL
+    { $$ = &Ptr {nil,NULL} }
 
 
 
@@ -28,11 +28,103 @@ type GoSqlConstantValue struct {
 	Timestamp *time.Time
 }
 
+// FOR UPDATE, FOR SHARE or FOR KEY SHARE clause of a select, strength is UPDATE, SHARE, KEY or 0 if there is none
+type GoSqlRowLock struct {
+	strength int
+	wait     data.LockWaitPolicy
+}
+
+func (l GoSqlRowLock) lockStrength() data.LockStrength {
+	switch l.strength {
+	case SHARE:
+		return data.LOCK_FOR_SHARE
+	case KEY:
+		return data.LOCK_FOR_KEY_SHARE
+	default:
+		return data.LOCK_FOR_UPDATE
+	}
+}
+
+// the specifications following the type of a column of a CREATE TABLE
+type GoSqlColumnConstraints struct {
+	spec2        int
+	notNull      bool
+	defaultValue *GoSqlTerm
+	check        *GoSqlTerm
+	references   *GoSqlForeignKeySpec
+}
+
+// the columns and PRIMARY KEY, UNIQUE or FOREIGN KEY constraints of a CREATE TABLE
+type GoSqlTableElements struct {
+	columns     []data.GoSqlColumn
+	keys        []GoSqlKey
+	foreignKeys []GoSqlForeignKeySpec
+}
+
+func (e GoSqlTableElements) append(other GoSqlTableElements) GoSqlTableElements {
+	return GoSqlTableElements{append(e.columns, other.columns...), append(e.keys, other.keys...), append(e.foreignKeys, other.foreignKeys...)}
+}
+
+type GoSqlKey struct {
+	primary bool
+	columns []string
+}
+
+// the referenced columns are the primary key of the parent, if there are none
+type GoSqlForeignKeySpec struct {
+	columns       []string
+	parent        data.GoSqlIdentifier
+	parentColumns []string
+	onDelete      data.ReferentialAction
+	onUpdate      data.ReferentialAction
+}
+
+// an action of ALTER TABLE, kind is ADD, DROP, RENAME or ALTER a column or TABLE to rename the table
+type GoSqlAlterTableAction struct {
+	kind     int
+	ifExists int
+	column   string
+	newName  string
+	elements GoSqlTableElements // the column added
+	colType  int
+	length   int
+}
+
+// an option of CREATE SEQUENCE, option is INCREMENT, MINVALUE, MAXVALUE, START or CYCLE
+type GoSqlSequenceOption struct {
+	option int
+	value  int64 // 1 for CYCLE, 0 for NO CYCLE
+	none   bool  // NO MINVALUE or NO MAXVALUE, the default
+}
+
 type GoSqlOrderBy struct {
 	Name      driver.Value
 	direction int
 }
 
+// LIMIT and OFFSET or OFFSET and FETCH FIRST of a select, the terms are integer constants or placeholders,
+// count is nil if all tuples are returned
+type GoSqlLimit struct {
+	count       *GoSqlTerm
+	offset      *GoSqlTerm
+	offsetFirst bool // OFFSET is written before FETCH FIRST
+}
+
+// the terms of the limit in the order they are written, which is the one of their placeholders
+func (l GoSqlLimit) terms() []*GoSqlTerm {
+	var res []*GoSqlTerm
+	if l.offsetFirst && l.offset != nil {
+		res = append(res, l.offset)
+	}
+	if l.count != nil {
+		res = append(res, l.count)
+	}
+	if !l.offsetFirst && l.offset != nil {
+		res = append(res, l.offset)
+	}
+	return res
+}
+
 type GoSqlUpdateSpec struct {
 	Name data.GoSqlIdentifier
 	term *GoSqlTerm
@@ -51,3 +143,22 @@ type GoSqlJoinedTable struct {
 	TableReferenceRight *GoSqlTableReference
 	condition           *GoSqlTerm
 }
+
+// a table of the from clause and its alias, if there is one
+type GoSqlAsIdentifier struct {
+	Id    data.GoSqlIdentifier
+	Alias string
+}
+
+// a table joined to the ones before it, JoinMode is 0 for JOIN without join type
+type GoSqlJoinSpec struct {
+	JoinMode      int
+	JoinedTable   GoSqlAsIdentifier
+	JoinCondition *GoSqlTerm
+}
+
+// a table of the from clause followed by the tables joined to it
+type GoSqlFromSpec struct {
+	Id        GoSqlAsIdentifier
+	JoinSpecs []GoSqlJoinSpec
+}
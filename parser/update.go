@@ -5,6 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aschoerk/go-sql-mem/data"
 )
@@ -22,8 +25,7 @@ type GoSqlUpdateRequest struct {
 
 func NewUpdateRequest(tableName GoSqlAsIdentifier, updates []GoSqlUpdateSpec, where *GoSqlTerm) *GoSqlUpdateRequest {
 	return &GoSqlUpdateRequest{
-		data.BaseStatement{
-			data.StatementBaseData{}},
+		data.BaseStatement{},
 		[]*GoSqlFromSpec{{tableName, nil}}, updates, where,
 		nil, nil, nil, nil,
 	}
@@ -32,13 +34,24 @@ func NewUpdateRequest(tableName GoSqlAsIdentifier, updates []GoSqlUpdateSpec, wh
 type GoSqlConnectionLevelRequest struct {
 	data.BaseStatement
 	token1, token2 int
+	name           string // of the savepoint or of the parameter to set
+	value          string // of the parameter to set
 }
 
 func NewConnectionLevelRequest(token1 int, token2 int) *GoSqlConnectionLevelRequest {
+	return NewSavepointRequest(token1, token2, "")
+}
+
+func NewSavepointRequest(token1 int, token2 int, name string) *GoSqlConnectionLevelRequest {
+	return &GoSqlConnectionLevelRequest{
+		data.BaseStatement{},
+		token1, token2, name, ""}
+}
+
+func NewSetRequest(name string, value string) *GoSqlConnectionLevelRequest {
 	return &GoSqlConnectionLevelRequest{
-		data.BaseStatement{
-			data.StatementBaseData{}},
-		token1, token2}
+		data.BaseStatement{},
+		SET, -1, name, value}
 }
 
 type GoSqlDeleteRequest struct {
@@ -53,6 +66,9 @@ func (r *GoSqlUpdateRequest) initStruct() error {
 		if !exists {
 			return fmt.Errorf("Unknown Table %s", r.tableName[0].Id.Id)
 		}
+		if !isChangeable(tmptable) {
+			return fmt.Errorf("can not update table %s", tmptable.Name())
+		}
 		r.table = tmptable
 		r.terms = []*GoSqlTerm{}
 		r.placeHolders = []*GoSqlTerm{} // the terms identified alias placeholders by parser
@@ -83,17 +99,62 @@ func (r *GoSqlConnectionLevelRequest) Exec(args []Value) (Result, error) {
 		if r.Conn.Transaction != nil && r.Conn.Transaction.IsStarted() {
 			return nil, errors.New("transaction already started")
 		}
+		// like the driver's Begin, the statements up to COMMIT or ROLLBACK are part of the transaction
+		r.Conn.DoAutoCommit = false
 		data.InitTransaction(r.Conn)
 	case COMMIT:
 		if r.Conn.Transaction == nil || !r.Conn.Transaction.IsStarted() {
 			return nil, errors.New("transaction is not started")
 		}
-		data.EndTransaction(r.Conn, data.COMMITTED)
+		r.Conn.DoAutoCommit = true
+		err := data.EndTransaction(r.Conn, data.COMMITTED)
+		if err != nil {
+			return nil, err
+		}
 	case ROLLBACK:
+		if r.token2 == SAVEPOINT {
+			if r.Conn.Transaction == nil {
+				return nil, errors.New("ROLLBACK TO SAVEPOINT can only be used in transactions")
+			}
+			err := r.Conn.Transaction.RollbackToSavepoint(r.name)
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
 		if r.Conn.Transaction == nil || !r.Conn.Transaction.IsStarted() {
 			return nil, errors.New("transaction is not started")
 		}
-		data.EndTransaction(r.Conn, data.ROLLEDBACK)
+		r.Conn.DoAutoCommit = true
+		err := data.EndTransaction(r.Conn, data.ROLLEDBACK)
+		if err != nil {
+			return nil, err
+		}
+	case SAVEPOINT:
+		if r.Conn.Transaction == nil {
+			return nil, errors.New("SAVEPOINT can only be used in transactions")
+		}
+		err := data.StartTransaction(r.Conn)
+		if err != nil {
+			return nil, err
+		}
+		err = r.Conn.Transaction.Savepoint(r.name)
+		if err != nil {
+			return nil, err
+		}
+	case RELEASE:
+		if r.Conn.Transaction == nil {
+			return nil, errors.New("RELEASE SAVEPOINT can only be used in transactions")
+		}
+		err := r.Conn.Transaction.ReleaseSavepoint(r.name)
+		if err != nil {
+			return nil, err
+		}
+	case SET:
+		err := r.setParameter()
+		if err != nil {
+			return nil, err
+		}
 	case AUTOCOMMIT:
 		switch r.token2 {
 		case ON:
@@ -128,7 +189,7 @@ func (r *GoSqlUpdateRequest) Exec(args []Value) (Result, error) {
 		return nil, fmt.Errorf("expected %d placeholders, but got %d args", len(r.placeHolders), len(args))
 	}
 	placeHolderOffset := 0
-	commands, err := Terms2Commands(r.terms, args, JoinedRecordsFromTable(r.table), &placeHolderOffset)
+	commands, err := Terms2Commands(r.Conn, r.terms, args, JoinedRecordsFromTable(r.table), &placeHolderOffset)
 	if err != nil {
 		return nil, err
 	}
@@ -136,7 +197,7 @@ func (r *GoSqlUpdateRequest) Exec(args []Value) (Result, error) {
 	for ix, command := range commands {
 		resultType := command.resultType
 		destType := r.table.Columns()[r.columnixs[ix]].ParserType
-		if resultType != destType {
+		if resultType != destType && resultType != NULL {
 			conversion, err := calcConversion(destType, resultType)
 			if err != nil {
 				return nil, err
@@ -144,27 +205,29 @@ func (r *GoSqlUpdateRequest) Exec(args []Value) (Result, error) {
 			command.m.AddCommand(conversion)
 		}
 	}
-	whereCommands, err := Terms2Commands([]*GoSqlTerm{r.where}, args, JoinedRecordsFromTable(r.table), &placeHolderOffset)
+	check, err := whereCheck(r.Conn, r.where, args, r.table, &placeHolderOffset)
 	if err != nil {
 		return nil, err
 	}
 
+	constraints, err := newColumnConstraints(r.Conn, r.table)
+	if err != nil {
+		return nil, err
+	}
 	results := make([]Value, len(r.columnixs))
 
+	// an update leaving the key as it is does not conflict with FOR KEY SHARE
+	r.LockStrength = data.LOCK_FOR_NO_KEY_UPDATE
+	for _, colix := range r.columnixs {
+		if r.table.(*data.GoSqlTable).IsKeyColumn(colix) {
+			r.LockStrength = data.LOCK_FOR_UPDATE
+		}
+	}
 	affectedRows := 0
-	it := r.table.NewIterator(r.BaseData(), true)
+	it := JoinedRecordsFromTable(r.table).newTableIterator(r.BaseData(), true, r.where)
+	defer it.Close()
 	for {
-		tuple, ok, err := it.Next(func(tupleData data.Tuple) (bool, error) {
-			res, err := whereCommands[0].m.Execute(args, tupleData, data.NULL_TUPLE)
-			if err != nil {
-				return false, err
-			}
-			whereResult, ok := res.(bool)
-			if !ok {
-				return false, fmt.Errorf("expected boolean expression alias where term")
-			}
-			return whereResult, nil
-		})
+		tuple, ok, err := it.Next(check)
 		if err != nil {
 			return nil, err
 		}
@@ -183,12 +246,49 @@ func (r *GoSqlUpdateRequest) Exec(args []Value) (Result, error) {
 		for ix, result := range results {
 			resultTuple.SetData(0, r.columnixs[ix], result)
 		}
-		r.table.Update(tuple.Id(), resultTuple, r.Conn)
+		err = constraints.check(resultTuple)
+		if err == nil {
+			err = checkParents(r.BaseData(), r.table.(*data.GoSqlTable), tuple, resultTuple, nil)
+		}
+		if err == nil {
+			err = referentialActions(r.BaseData(), r.table.(*data.GoSqlTable), tuple, resultTuple)
+		}
+		if err != nil {
+			data.AbortStatement(r.BaseData())
+			return nil, err
+		}
+		_, err = r.table.(*data.GoSqlTable).UpdateChecked(tuple.Id(), resultTuple, r.Conn)
+		if err != nil {
+			data.AbortStatement(r.BaseData())
+			return nil, err
+		}
 	}
 	data.EndStatement(&r.StatementBaseData)
 	return GoSqlResult{-1, int64(affectedRows)}, nil
 }
 
+// returns the check of the where term for the tuples of the table, all tuples pass if there is none
+func whereCheck(conn *data.GoSqlConnData, where *GoSqlTerm, args []Value, table data.Table, placeHolderOffset *int) (func(data.Tuple) (bool, error), error) {
+	if where == nil {
+		return func(data.Tuple) (bool, error) { return true, nil }, nil
+	}
+	whereCommands, err := Terms2Commands(conn, []*GoSqlTerm{where}, args, JoinedRecordsFromTable(table), placeHolderOffset)
+	if err != nil {
+		return nil, err
+	}
+	return func(tupleData data.Tuple) (bool, error) {
+		res, err := whereCommands[0].m.Execute(args, tupleData, data.NULL_TUPLE)
+		if err != nil {
+			return false, err
+		}
+		whereResult, ok := res.(bool)
+		if !ok {
+			return false, fmt.Errorf("expected boolean expression alias where term")
+		}
+		return whereResult, nil
+	}, nil
+}
+
 func (r *GoSqlDeleteRequest) NumInput() int {
 	if r.where != nil {
 		placeHolders := r.where.FindPlaceHolders([]*GoSqlTerm{})
@@ -203,43 +303,84 @@ func (r *GoSqlDeleteRequest) Exec(args []Value) (Result, error) {
 	if !exists {
 		return nil, fmt.Errorf("Unknown Table %v", r.from)
 	}
-	var placeHolders []*GoSqlTerm
-	if r.where != nil {
-		placeHolders = r.where.FindPlaceHolders(placeHolders)
+	if !isChangeable(table) {
+		return nil, fmt.Errorf("can not delete from table %s", table.Name())
 	}
 	placeHolderOffset := 0
-	whereCommands, err := Terms2Commands([]*GoSqlTerm{r.where}, args, JoinedRecordsFromTable(table), &placeHolderOffset)
+	check, err := whereCheck(r.Conn, r.where, args, table, &placeHolderOffset)
 	if err != nil {
 		return nil, err
 	}
 	affectedRows := 0
-	var todelete []int64
-	it := table.NewIterator(r.BaseData(), true)
+	var todelete []data.Tuple
+	it := JoinedRecordsFromTable(table).newTableIterator(r.BaseData(), true, r.where)
+	defer it.Close()
 	for {
-		tuple, ok, err := it.Next(func(tupleData data.Tuple) (bool, error) {
-			res, err := whereCommands[0].m.Execute(args, tupleData, data.NULL_TUPLE)
-			if err != nil {
-				return false, err
-			}
-			whereResult, ok := res.(bool)
-			if !ok {
-				return false, fmt.Errorf("expected boolean expression alias where term")
-			}
-			return whereResult, nil
-		})
+		tuple, ok, err := it.Next(check)
 		if err != nil {
 			return nil, err
 		}
 		if !ok {
 			break
 		}
-		todelete = append(todelete, tuple.Id())
+		todelete = append(todelete, tuple)
 	}
 	slices.Reverse(todelete)
-	for _, id := range todelete {
-		table.Delete(id, r.Conn)
+	for _, tuple := range todelete {
+		err = referentialActions(r.BaseData(), table.(*data.GoSqlTable), tuple, nil)
+		if err != nil {
+			data.AbortStatement(r.BaseData())
+			return nil, err
+		}
+		table.Delete(tuple.Id(), r.Conn)
 		affectedRows++
 	}
 	data.EndStatement(&r.StatementBaseData)
 	return GoSqlResult{-1, int64(affectedRows)}, nil
 }
+
+func (r *GoSqlConnectionLevelRequest) setParameter() error {
+	switch r.name {
+	case "lock_timeout":
+		timeout, err := parseMilliseconds(r.value)
+		if err != nil {
+			return fmt.Errorf("invalid value %s for lock_timeout: %w", r.value, err)
+		}
+		r.Conn.LockTimeoutInMs = timeout
+		if r.Conn.Transaction != nil {
+			r.Conn.Transaction.MaxLockTimeInMs = timeout
+		}
+	case "search_path":
+		// the schemas separated by commas, the first one is the current schema
+		var schemas []string
+		for _, schema := range strings.Split(r.value, ",") {
+			schema = strings.TrimSpace(schema)
+			if !data.SchemaExists(r.Conn, schema) {
+				return fmt.Errorf("schema %s does not exist", schema)
+			}
+			schemas = append(schemas, schema)
+		}
+		r.Conn.CurrentSchema, r.Conn.SearchPath = schemas[0], schemas[1:]
+	default:
+		return fmt.Errorf("unknown parameter %s", r.name)
+	}
+	return nil
+}
+
+// milliseconds as plain number or as duration like 2s or 500ms
+func parseMilliseconds(value string) (int64, error) {
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if ms < 0 {
+			return 0, errors.New("must not be negative")
+		}
+		return ms, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, errors.New("must not be negative")
+	}
+	return d.Milliseconds(), nil
+}
@@ -0,0 +1,293 @@
+package parser
+
+import (
+	. "database/sql/driver"
+	"errors"
+	"fmt"
+
+	"github.com/aschoerk/go-sql-mem/data"
+	. "github.com/aschoerk/go-sql-mem/data"
+)
+
+type GoSqlCreateViewRequest struct {
+	data.BaseStatement
+	orReplace bool
+	name      GoSqlIdentifier
+	columns   []string // naming the columns of the view instead of the select list
+	query     *GoSqlSelectRequest
+}
+
+type GoSqlCreateMaterializedViewRequest struct {
+	GoSqlCreateViewRequest
+	ifExists int
+	populate bool // false during recovery, where the tuples inserted are replayed
+}
+
+type GoSqlRefreshMaterializedViewRequest struct {
+	data.BaseStatement
+	concurrently bool
+	name         GoSqlIdentifier
+}
+
+type GoSqlDropViewRequest struct {
+	data.BaseStatement
+	ifExists     int
+	name         GoSqlIdentifier
+	materialized bool
+}
+
+// the view is seen by other transactions, after the one creating it committed. Its select is executed once to find
+// errors in it and to determine the columns.
+func (r *GoSqlCreateViewRequest) Exec(args []Value) (Result, error) {
+	view, err := r.create()
+	if err == nil {
+		err = LogStatement(r.Conn, r.Sql, true)
+	}
+	if err != nil {
+		AbortStatement(r.BaseData())
+		return nil, err
+	}
+	CreateView(r.Conn, view)
+	return &GoSqlResult{-1, 0}, EndStatement(r.BaseData())
+}
+
+func (r *GoSqlCreateViewRequest) create() (*View, error) {
+	err := r.checkQuery()
+	if err != nil {
+		return nil, err
+	}
+	schema, name := TableSchema(r.Conn, r.name)
+	err = LockTableName(r.Conn, schema, name)
+	if err != nil {
+		return nil, err
+	}
+	existing, exists := data.GetTable(r.BaseStatement, GoSqlIdentifier{Parts: []string{schema, name}})
+	replaced, isView := existing.(*View)
+	if exists && !isView {
+		return nil, fmt.Errorf("table %s already exists", r.name.Name())
+	}
+	if exists && !r.orReplace {
+		return nil, fmt.Errorf("view %s already exists", r.name.Name())
+	}
+	columns, _, err := r.queryTuples()
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		err = replaced.CheckReplacing(columns)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return NewView(schema, name, columns, r.Sql), nil
+}
+
+func (r *GoSqlCreateViewRequest) checkQuery() error {
+	if r.query.rowLock.strength != 0 {
+		return errors.New("FOR UPDATE and FOR SHARE are not allowed in views")
+	}
+	if r.query.NumInput() > 0 {
+		return errors.New("placeholders are not allowed in views")
+	}
+	return nil
+}
+
+// executes the select of the view for the statement
+func (r *GoSqlCreateViewRequest) queryTuples() ([]GoSqlColumn, [][]Value, error) {
+	r.query.StatementBaseData = StatementBaseData{Conn: r.Conn, State: Created, Sql: r.Sql, LockStrength: LOCK_FOR_UPDATE, LockWait: LOCK_WAIT}
+	columns, tuples, err := selectTuples(r.query, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	columns, err = nameColumns("view "+r.name.Name(), r.columns, columns)
+	return columns, tuples, err
+}
+
+// the columns of the relation named like the column list following its name, if there is one
+func nameColumns(relation string, names []string, columns []GoSqlColumn) ([]GoSqlColumn, error) {
+	if names == nil {
+		return columns, nil
+	}
+	if len(names) > len(columns) {
+		return nil, fmt.Errorf("%s names more columns than its select returns", relation)
+	}
+	for ix, name := range names {
+		for _, other := range names[:ix] {
+			if other == name {
+				return nil, fmt.Errorf("column %s specified more than once", name)
+			}
+		}
+		columns[ix].Name = name
+	}
+	return columns, nil
+}
+
+// the materialized view is created like a table, filled with the tuples of its select
+func (r *GoSqlCreateMaterializedViewRequest) Exec(args []Value) (Result, error) {
+	err := r.checkQuery()
+	if err != nil {
+		return nil, err
+	}
+	schema, name := TableSchema(r.Conn, r.name)
+	err = LockTableName(r.Conn, schema, name)
+	if err != nil {
+		AbortStatement(r.BaseData())
+		return nil, err
+	}
+	_, exists := data.GetTable(r.BaseStatement, GoSqlIdentifier{Parts: []string{schema, name}})
+	if exists {
+		if r.ifExists == 1 {
+			return &GoSqlResult{-1, 0}, EndStatement(r.BaseData())
+		}
+		AbortStatement(r.BaseData())
+		return nil, fmt.Errorf("table %s already exists", r.name.Name())
+	}
+	err = r.createMaterialized(GoSqlIdentifier{Parts: []string{schema, name}})
+	if err != nil {
+		AbortStatement(r.BaseData())
+		return nil, err
+	}
+	return &GoSqlResult{-1, 0}, EndStatement(r.BaseData())
+}
+
+func (r *GoSqlCreateMaterializedViewRequest) createMaterialized(name GoSqlIdentifier) error {
+	columns, tuples, err := r.queryTuples()
+	if err != nil {
+		return err
+	}
+	err = LogStatement(r.Conn, r.Sql, true)
+	if err != nil {
+		return err
+	}
+	table := NewMaterializedView(name, columns, r.Sql)
+	CreateTable(r.Conn, table)
+	if !r.populate {
+		return nil
+	}
+	for _, values := range tuples {
+		_, err = table.InsertChecked(values, r.Conn)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// the tuples refreshed are seen by other transactions, after the one refreshing committed
+func (r *GoSqlRefreshMaterializedViewRequest) Exec(args []Value) (Result, error) {
+	table, exists := data.GetTable(r.BaseStatement, r.name)
+	if !exists {
+		return nil, fmt.Errorf("materialized view %s does not exist", r.name.Name())
+	}
+	goSqlTable, ok := table.(*GoSqlTable)
+	if !ok || !goSqlTable.IsMaterializedView() {
+		return nil, fmt.Errorf("%s is not a materialized view", r.name.Name())
+	}
+	// locked before the select, so it sees the tuples of a refresh waited for
+	err := LockTableName(r.Conn, goSqlTable.SchemaName, goSqlTable.TableName)
+	var tuples [][]Value
+	if err == nil {
+		baseData := StatementBaseData{Conn: r.Conn, State: Created, Sql: goSqlTable.Definition, LockStrength: LOCK_FOR_UPDATE, LockWait: LOCK_WAIT}
+		_, tuples, err = viewTuples(goSqlTable.Definition, &baseData)
+	}
+	if err == nil {
+		err = goSqlTable.Refresh(r.BaseData(), tuples, r.concurrently)
+	}
+	if err != nil {
+		AbortStatement(r.BaseData())
+		return nil, err
+	}
+	return &GoSqlResult{-1, 0}, EndStatement(r.BaseData())
+}
+
+// the view dropped is seen by other transactions, until the one dropping it committed
+func (r *GoSqlDropViewRequest) Exec(args []Value) (Result, error) {
+	kind := "view"
+	if r.materialized {
+		kind = "materialized view"
+	}
+	schema, name := TableSchema(r.Conn, r.name)
+	err := LockTableName(r.Conn, schema, name)
+	if err != nil {
+		AbortStatement(r.BaseData())
+		return nil, err
+	}
+	table, exists := data.GetTable(r.BaseStatement, GoSqlIdentifier{Parts: []string{schema, name}})
+	if !exists {
+		if r.ifExists == 0 {
+			return &GoSqlResult{-1, 0}, EndStatement(r.BaseData())
+		}
+		AbortStatement(r.BaseData())
+		return nil, fmt.Errorf("%s %s does not exist", kind, r.name.Name())
+	}
+	view, isView := table.(*View)
+	materialized, isTable := table.(*GoSqlTable)
+	if r.materialized && !(isTable && materialized.IsMaterializedView()) || !r.materialized && !isView {
+		AbortStatement(r.BaseData())
+		return nil, fmt.Errorf("%s is not a %s", r.name.Name(), kind)
+	}
+	err = LogStatement(r.Conn, r.Sql, true)
+	if err == nil && r.materialized {
+		err = DropTable(r.Conn, materialized)
+	}
+	if err != nil {
+		AbortStatement(r.BaseData())
+		return nil, err
+	}
+	if !r.materialized {
+		DropView(r.Conn, view)
+	}
+	return &GoSqlResult{-1, 0}, EndStatement(r.BaseData())
+}
+
+// executes the select of a view for the statement expanding it, see ViewTuples
+func viewTuples(definition string, baseData *StatementBaseData) ([]GoSqlColumn, [][]Value, error) {
+	parseResult, res := Parse(definition)
+	if res != 0 {
+		return nil, nil, fmt.Errorf("could not parse view definition %s", definition)
+	}
+	var createView *GoSqlCreateViewRequest
+	switch stmt := parseResult.(type) {
+	case *GoSqlCreateViewRequest:
+		createView = stmt
+	case *GoSqlCreateMaterializedViewRequest:
+		createView = &stmt.GoSqlCreateViewRequest
+	default:
+		return nil, nil, fmt.Errorf("unexpected view definition %s", definition)
+	}
+	createView.query.StatementBaseData = *baseData
+	return selectTuples(createView.query, nil)
+}
+
+// the visible columns and the tuples returned by the query
+func selectTuples(query *GoSqlSelectRequest, args []Value) ([]GoSqlColumn, [][]Value, error) {
+	rows, err := query.Query(args)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	table := rows.(*GoSqlRows).ResultTable()
+	var columns []GoSqlColumn
+	var visible []int
+	for ix, col := range table.Columns() {
+		if col.Hidden {
+			continue
+		}
+		// typed like the columns of a table
+		colType := col.ColType
+		if colType == STRING {
+			colType = TEXT
+		}
+		columns = append(columns, NewColumn(col.Name, colType, 0, GoSqlColumnConstraints{-1, false, nil, nil, nil}))
+		visible = append(visible, ix)
+	}
+	var tuples [][]Value
+	for _, tuple := range *table.Data() {
+		values := make([]Value, len(visible))
+		for ix, col := range visible {
+			values[ix] = tuple[col]
+		}
+		tuples = append(tuples, values)
+	}
+	return columns, tuples, nil
+}
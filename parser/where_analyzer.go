@@ -1,5 +1,171 @@
 package parser
 
-func (r *GoSqlSelectRequest) analyzeWhere() {
+import (
+	. "database/sql/driver"
+	"time"
 
+	"github.com/aschoerk/go-sql-mem/data"
+)
+
+// The conjuncts of a where clause comparing a column with a constant or a placeholder restrict the keys an index
+// scan has to look at. The index making use of most of them is chosen, the whole where clause is evaluated for
+// the tuples found anyway. Placeholders must have got their values by Terms2Commands before.
+
+type columnRestriction struct {
+	equal         Value
+	lower         Value
+	upper         Value
+	lowerIncluded bool
+	upperIncluded bool
+}
+
+func conjuncts(term *GoSqlTerm, res []*GoSqlTerm) []*GoSqlTerm {
+	if term.operator == AND {
+		return conjuncts(term.right, conjuncts(term.left, res))
+	}
+	return append(res, term)
+}
+
+// the column of the single table, if the term is one of its identifiers
+func (r *JoinedRecords) columnOf(term *GoSqlTerm) (int, int, bool) {
+	if term == nil || term.leaf == nil || term.leaf.token != IDENTIFIER {
+		return -1, -1, false
+	}
+	id := term.leaf.ptr.(data.GoSqlIdentifier)
+	if id.Parts[0] == data.VersionedRecordId {
+		return -1, -1, false
+	}
+	tableIx, ix, parserType, err := r.identifyId(id)
+	if err != nil || tableIx != 0 {
+		return -1, -1, false
+	}
+	return ix, parserType, true
+}
+
+// the value of a constant or placeholder, if it can be compared to the values of the column without conversion
+func constantOf(term *GoSqlTerm, parserType int) (Value, bool) {
+	if term == nil || term.leaf == nil || term.leaf.token == IDENTIFIER {
+		return nil, false
+	}
+	switch term.leaf.ptr.(type) {
+	case int64:
+		return term.leaf.ptr, parserType == INTEGER
+	case float64:
+		return term.leaf.ptr, parserType == FLOAT
+	case string:
+		return term.leaf.ptr, parserType == STRING
+	case time.Time:
+		return term.leaf.ptr, parserType == TIMESTAMP
+	}
+	return nil, false
+}
+
+var flippedComparison = map[int]int{
+	EQUAL:            EQUAL,
+	LESS:             GREATER,
+	LESS_OR_EQUAL:    GREATER_OR_EQUAL,
+	GREATER:          LESS,
+	GREATER_OR_EQUAL: LESS_OR_EQUAL,
+}
+
+func (c *columnRestriction) restrict(operator int, value Value) {
+	switch operator {
+	case EQUAL:
+		c.equal = value
+	case LESS, LESS_OR_EQUAL:
+		if c.upper == nil {
+			c.upper = value
+			c.upperIncluded = operator == LESS_OR_EQUAL
+		}
+	case GREATER, GREATER_OR_EQUAL:
+		if c.lower == nil {
+			c.lower = value
+			c.lowerIncluded = operator == GREATER_OR_EQUAL
+		}
+	}
+}
+
+// the restrictions of the columns of the single table by the where clause
+func (r *JoinedRecords) analyzeWhere(where *GoSqlTerm) map[int]*columnRestriction {
+	res := make(map[int]*columnRestriction)
+	restriction := func(col int) *columnRestriction {
+		if res[col] == nil {
+			res[col] = &columnRestriction{}
+		}
+		return res[col]
+	}
+	for _, term := range conjuncts(where, nil) {
+		if term.operator == BETWEEN {
+			col, parserType, ok := r.columnOf(term.left)
+			if !ok {
+				continue
+			}
+			lower, lowerOk := constantOf(term.right.left, parserType)
+			upper, upperOk := constantOf(term.right.right, parserType)
+			if lowerOk && upperOk {
+				restriction(col).restrict(GREATER_OR_EQUAL, lower)
+				restriction(col).restrict(LESS_OR_EQUAL, upper)
+			}
+			continue
+		}
+		flipped, comparison := flippedComparison[term.operator]
+		if !comparison {
+			continue
+		}
+		if col, parserType, ok := r.columnOf(term.left); ok {
+			if value, ok := constantOf(term.right, parserType); ok {
+				restriction(col).restrict(term.operator, value)
+			}
+		} else if col, parserType, ok := r.columnOf(term.right); ok {
+			if value, ok := constantOf(term.left, parserType); ok {
+				restriction(col).restrict(flipped, value)
+			}
+		}
+	}
+	return res
+}
+
+// the index restricting the keys to look at most, false if no index of the table can be used for the where clause
+func (r *JoinedRecords) chooseIndex(table *data.GoSqlTable, where *GoSqlTerm) (*data.GoSqlIndex, data.IndexRange, bool) {
+	var best *data.GoSqlIndex
+	var bestRange data.IndexRange
+	if where == nil {
+		return nil, bestRange, false
+	}
+	restrictions := r.analyzeWhere(where)
+	bestScore := 0
+	for _, index := range table.Indexes() {
+		var keys data.IndexRange
+		score := 0
+		for _, col := range index.Columns {
+			c, ok := restrictions[col]
+			if !ok {
+				break
+			}
+			if c.equal != nil {
+				keys.Equal = append(keys.Equal, c.equal)
+				score += 2
+				continue
+			}
+			keys.Lower, keys.LowerIncluded = c.lower, c.lowerIncluded
+			keys.Upper, keys.UpperIncluded = c.upper, c.upperIncluded
+			score++
+			break
+		}
+		if score > bestScore {
+			best, bestRange, bestScore = index, keys, score
+		}
+	}
+	return best, bestRange, best != nil
+}
+
+// an index scan, if an index of the single table restricts the tuples the where clause selects, a full scan otherwise
+func (r *JoinedRecords) newTableIterator(baseData *data.StatementBaseData, forChange bool, where *GoSqlTerm) data.TableIterator {
+	table := r.tableExpr[0].table
+	if goSqlTable, ok := table.(*data.GoSqlTable); ok {
+		if index, keys, found := r.chooseIndex(goSqlTable, where); found {
+			return goSqlTable.NewIndexIterator(baseData, forChange, index, keys)
+		}
+	}
+	return table.NewIterator(baseData, forChange)
 }
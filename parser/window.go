@@ -0,0 +1,654 @@
+package parser
+
+import (
+	. "database/sql/driver"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	. "github.com/aschoerk/go-sql-mem/data"
+	. "github.com/aschoerk/go-sql-mem/machine"
+)
+
+// A window function is computed over the tuples of the select, once WHERE selected them and before they are ordered
+// and limited. The tuples of a partition are the ones equal in the terms of PARTITION BY, they are ordered by the
+// terms of ORDER BY, the tuples equal in them are peers. The frame of a tuple is a range of its partition, by
+// default the tuples up to its last peer, all of the partition without ORDER BY.
+// The term calling the function is a leaf of token OVER, its machine pushes the value computed for the tuple from
+// the tuple of window values.
+type GoSqlWindowFunction struct {
+	function int          // ROW_NUMBER, RANK, DENSE_RANK, LAG, LEAD, FIRST_VALUE, LAST_VALUE or an aggregate function
+	args     []*GoSqlTerm // nil for COUNT(*)
+	distinct bool
+	window   GoSqlWindow
+	ix       int                  // of its value in the tuple of window values, -1 outside of a select list
+	contexts []*EvaluationContext // computing args, partitionBy and orderBy, set when compiled
+	values   []Value              // of the placeholders of the contexts
+}
+
+type GoSqlWindow struct {
+	partitionBy []*GoSqlTerm
+	orderBy     []GoSqlWindowOrder
+	frame       *GoSqlFrame // nil for the default frame
+}
+
+type GoSqlWindowOrder struct {
+	term      *GoSqlTerm
+	direction int
+}
+
+// ROWS or RANGE BETWEEN start AND end, a frame given by its start only ends with the current tuple
+type GoSqlFrame struct {
+	unit  int // ROWS or RANGE
+	start GoSqlFrameBound
+	end   GoSqlFrameBound
+}
+
+// UNBOUNDED PRECEDING, <offset> PRECEDING, CURRENT ROW, <offset> FOLLOWING or UNBOUNDED FOLLOWING
+type GoSqlFrameBound struct {
+	direction int // PRECEDING, CURRENT or FOLLOWING
+	unbounded bool
+	offset    int64 // tuples with ROWS, distance of the value of the ORDER BY term with RANGE
+}
+
+func NewWindowFunction(function int, args []*GoSqlTerm, window GoSqlWindow) *GoSqlTerm {
+	return &GoSqlTerm{-1, nil, nil, &Ptr{&GoSqlWindowFunction{function, args, false, window, -1, nil, nil}, OVER}}
+}
+
+// the aggregate function computed over the window, parameter is the one of the aggregate function
+func NewWindowAggregate(function int, parameter *GoSqlTerm, window GoSqlWindow) *GoSqlTerm {
+	var args []*GoSqlTerm
+	if parameter.operator != ASTERISK {
+		args = []*GoSqlTerm{parameter.left}
+	}
+	return &GoSqlTerm{-1, nil, nil, &Ptr{&GoSqlWindowFunction{function, args, parameter.operator == DISTINCT, window,
+		-1, nil, nil}, OVER}}
+}
+
+func (w *GoSqlWindowFunction) name() string {
+	switch w.function {
+	case ROW_NUMBER:
+		return "row_number"
+	case RANK:
+		return "rank"
+	case DENSE_RANK:
+		return "dense_rank"
+	case LAG:
+		return "lag"
+	case LEAD:
+		return "lead"
+	case FIRST_VALUE:
+		return "first_value"
+	case LAST_VALUE:
+		return "last_value"
+	case COUNT:
+		return "count"
+	case SUM:
+		return "sum"
+	case AVG:
+		return "avg"
+	case MIN:
+		return "min"
+	default:
+		return "max"
+	}
+}
+
+// the terms the function is computed from, in the order they are written
+func (w *GoSqlWindowFunction) terms() []*GoSqlTerm {
+	res := slices.Clone(w.args)
+	res = append(res, w.window.partitionBy...)
+	for _, order := range w.window.orderBy {
+		res = append(res, order.term)
+	}
+	return res
+}
+
+func (w *GoSqlWindowFunction) placeHolders(res []*GoSqlTerm) []*GoSqlTerm {
+	for _, term := range w.terms() {
+		res = term.FindPlaceHolders(res)
+	}
+	return res
+}
+
+// the window functions called by the term
+func (t *GoSqlTerm) windowFunctions(res []*GoSqlWindowFunction) []*GoSqlWindowFunction {
+	if t.leaf != nil {
+		if t.leaf.token == OVER {
+			res = append(res, t.leaf.ptr.(*GoSqlWindowFunction))
+		}
+		return res
+	}
+	if t.left != nil {
+		res = t.left.windowFunctions(res)
+	}
+	if t.right != nil {
+		res = t.right.windowFunctions(res)
+	}
+	return res
+}
+
+// the window functions of the select list, numbered in the order of their values in the tuple of window values
+func (r *GoSqlSelectRequest) windowFunctions() ([]*GoSqlWindowFunction, error) {
+	var res []*GoSqlWindowFunction
+	for _, sl := range r.selectList {
+		if sl.expression != nil {
+			res = sl.expression.windowFunctions(res)
+		}
+	}
+	if r.where != nil && r.where.containsLeaf(OVER) {
+		return nil, errors.New("window functions are not allowed in WHERE")
+	}
+	if r.having != nil && r.having.containsLeaf(OVER) {
+		return nil, errors.New("window functions are not allowed in HAVING")
+	}
+	if len(res) > 0 && r.rowLock.strength != 0 {
+		return nil, errors.New("FOR UPDATE and FOR SHARE are not allowed with window functions")
+	}
+	for ix, w := range res {
+		for _, term := range w.terms() {
+			if term.containsLeaf(OVER) {
+				return nil, errors.New("window function calls cannot be nested")
+			}
+			if aggregation, _ := extractAggregation(term); aggregation != nil {
+				return nil, fmt.Errorf("aggregate functions are not allowed in the arguments of %s", w.name())
+			}
+		}
+		w.ix = ix
+	}
+	return res, nil
+}
+
+// compiles the terms the function is computed from, the machine of e pushes the value computed for the tuple. The
+// placeholders of the terms are the ones following the ones e compiled before.
+func (w *GoSqlWindowFunction) toMachine(e *EvaluationContext) (int, error) {
+	if w.ix < 0 {
+		return -1, fmt.Errorf("window function %s is only allowed in the select list", w.name())
+	}
+	if w.distinct {
+		return -1, fmt.Errorf("DISTINCT is not implemented for window function %s", w.name())
+	}
+	w.values = nil
+	for _, placeHolder := range w.placeHolders(nil) {
+		w.values = append(w.values, placeHolder.leaf.ptr)
+	}
+	e.lastPlaceHolderIndex += len(w.values)
+	placeHolderOffset := 0
+	var err error
+	w.contexts, err = Terms2Commands(e.conn, w.terms(), w.values, e.t, &placeHolderOffset)
+	if err != nil {
+		return -1, err
+	}
+	resultType, err := w.resultType()
+	if err != nil {
+		return -1, err
+	}
+	AddPushAttribute2(e.m, 0, w.ix)
+	return resultType, nil
+}
+
+// the type of the values computed, the arguments are checked and the default of LAG and LEAD converted to it
+func (w *GoSqlWindowFunction) resultType() (int, error) {
+	minArgs, maxArgs := 1, 1
+	switch w.function {
+	case ROW_NUMBER, RANK, DENSE_RANK:
+		minArgs, maxArgs = 0, 0
+	case LAG, LEAD:
+		maxArgs = 3
+	case COUNT:
+		minArgs = 0
+	}
+	if len(w.args) < minArgs || len(w.args) > maxArgs {
+		return -1, fmt.Errorf("wrong number of arguments for window function %s", w.name())
+	}
+	switch w.function {
+	case ROW_NUMBER, RANK, DENSE_RANK, COUNT:
+		return INTEGER, nil
+	}
+	argType := w.contexts[0].resultType
+	switch w.function {
+	case SUM, AVG:
+		if argType != INTEGER && argType != FLOAT {
+			return -1, fmt.Errorf("window function %s is not defined for type %s", w.name(), typeName(argType))
+		}
+		if w.function == AVG {
+			return FLOAT, nil
+		}
+	case LAG, LEAD:
+		if len(w.args) > 1 && w.contexts[1].resultType != INTEGER {
+			return -1, fmt.Errorf("offset of window function %s must be an integer", w.name())
+		}
+		if len(w.args) > 2 && w.contexts[2].resultType != argType {
+			conversion, err := calcConversion(argType, w.contexts[2].resultType)
+			if err != nil {
+				return -1, fmt.Errorf("default of window function %s cannot be converted to type %s", w.name(),
+					typeName(argType))
+			}
+			w.contexts[2].m.AddCommand(conversion)
+		}
+	}
+	return argType, nil
+}
+
+func typeName(token int) string {
+	if token == STRING {
+		token = TEXT
+	}
+	return columnTypeName(GoSqlColumn{ColType: token, ParserType: token})
+}
+
+// the tuples of the select, read before the window functions can be computed
+type windowStage struct {
+	functions []*GoSqlWindowFunction
+	tuples    []Tuple
+	inputs    [][][]Value // by function and tuple, the values of the terms the function is computed from
+}
+
+func newWindowStage(functions []*GoSqlWindowFunction) *windowStage {
+	return &windowStage{functions, nil, make([][][]Value, len(functions))}
+}
+
+func (s *windowStage) add(tuple Tuple) error {
+	for ix, w := range s.functions {
+		values := make([]Value, len(w.contexts))
+		for cix, context := range w.contexts {
+			value, err := context.m.Execute(w.values, tuple, NULL_TUPLE)
+			if err != nil {
+				return err
+			}
+			values[cix] = value
+		}
+		s.inputs[ix] = append(s.inputs[ix], values)
+	}
+	s.tuples = append(s.tuples, tuple)
+	return nil
+}
+
+// computes the window functions and fills the temp table with the select list evaluated for the tuples read
+func (s *windowStage) fill(tempTable Table, args []Value, evaluationContexts []*EvaluationContext, sizeSelectList int) error {
+	values := make([][]Value, len(s.tuples))
+	for ix := range values {
+		values[ix] = make([]Value, len(s.functions))
+	}
+	for ix, w := range s.functions {
+		err := w.compute(s.inputs[ix], func(tupleIx int, value Value) {
+			values[tupleIx][ix] = value
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for ix, tuple := range s.tuples {
+		err := calcTuple(tempTable, args, evaluationContexts, tuple, NewSliceTuple(-1, values[ix]), sizeSelectList)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// computes the function for the tuples, whose inputs are given, partition by partition
+func (w *GoSqlWindowFunction) compute(inputs [][]Value, set func(tupleIx int, value Value)) error {
+	partitionStart := len(w.args)
+	orderStart := partitionStart + len(w.window.partitionBy)
+	var keys []string
+	partitions := make(map[string][]int)
+	for ix, values := range inputs {
+		key := ValuesKey(values[partitionStart:orderStart])
+		if partitions[key] == nil {
+			keys = append(keys, key)
+		}
+		partitions[key] = append(partitions[key], ix)
+	}
+	for _, key := range keys {
+		rows := partitions[key]
+		slices.SortStableFunc(rows, func(a, b int) int {
+			return w.compareOrder(inputs[a][orderStart:], inputs[b][orderStart:])
+		})
+		partition := make([][]Value, len(rows))
+		for ix, row := range rows {
+			partition[ix] = inputs[row]
+		}
+		results, err := w.computePartition(partition, orderStart)
+		if err != nil {
+			return err
+		}
+		for ix, row := range rows {
+			set(row, results[ix])
+		}
+	}
+	return nil
+}
+
+// compares the values of the ORDER BY terms, NULL follows the other values unless the order is DESC
+func (w *GoSqlWindowFunction) compareOrder(a, b []Value) int {
+	for ix, order := range w.window.orderBy {
+		res := compareNullable(a[ix], b[ix])
+		if order.direction == DESC {
+			res = -res
+		}
+		if res != 0 {
+			return res
+		}
+	}
+	return 0
+}
+
+func compareNullable(a, b Value) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+	switch a := a.(type) {
+	case int64:
+		return compareOrdered(a, b.(int64))
+	case float64:
+		return compareOrdered(a, b.(float64))
+	case string:
+		return compareOrdered(a, b.(string))
+	case bool:
+		if a == b.(bool) {
+			return 0
+		} else if a {
+			return 1
+		}
+		return -1
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	}
+	return 0
+}
+
+func compareOrdered[T int64 | float64 | string](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// the values of the function for the tuples of the partition in their order
+func (w *GoSqlWindowFunction) computePartition(partition [][]Value, orderStart int) ([]Value, error) {
+	n := len(partition)
+	res := make([]Value, n)
+	// the tuples of peers[ix] up to peers[ix + 1] are peers
+	var peers []int
+	for ix := range partition {
+		if ix == 0 || w.compareOrder(partition[ix-1][orderStart:], partition[ix][orderStart:]) != 0 {
+			peers = append(peers, ix)
+		}
+	}
+	peers = append(peers, n)
+	group := 0
+	aggregate := &windowAggregate{function: w.function}
+	for ix := range partition {
+		if ix == peers[group+1] {
+			group++
+		}
+		switch w.function {
+		case ROW_NUMBER:
+			res[ix] = int64(ix + 1)
+		case RANK:
+			res[ix] = int64(peers[group] + 1)
+		case DENSE_RANK:
+			res[ix] = int64(group + 1)
+		case LAG, LEAD:
+			res[ix] = w.shifted(partition, ix)
+		default:
+			start, end, err := w.frame(partition, orderStart, ix, peers[group], peers[group+1])
+			if err != nil {
+				return nil, err
+			}
+			res[ix] = w.aggregate(partition, start, end, aggregate)
+		}
+	}
+	return res, nil
+}
+
+// the value of the tuple offset tuples before (LAG) or behind (LEAD) the tuple, the default if there is none
+func (w *GoSqlWindowFunction) shifted(partition [][]Value, ix int) Value {
+	offset := int64(1)
+	if len(w.args) > 1 {
+		value, ok := partition[ix][1].(int64)
+		if !ok {
+			return nil
+		}
+		offset = value
+	}
+	if w.function == LAG {
+		offset = -offset
+	}
+	if target := int64(ix) + offset; target >= 0 && target < int64(len(partition)) {
+		return partition[target][0]
+	}
+	if len(w.args) > 2 {
+		return partition[ix][2]
+	}
+	return nil
+}
+
+// the first and behind the last tuple of the frame of the tuple ix, whose peers are the ones from peerStart up to
+// peerEnd
+func (w *GoSqlWindowFunction) frame(partition [][]Value, orderStart int, ix int, peerStart int, peerEnd int) (int, int, error) {
+	frame := w.window.frame
+	if frame == nil {
+		if len(w.window.orderBy) == 0 {
+			return 0, len(partition), nil
+		}
+		return 0, peerEnd, nil
+	}
+	if frame.start.unbounded && frame.start.direction == FOLLOWING {
+		return 0, 0, errors.New("frame start cannot be UNBOUNDED FOLLOWING")
+	}
+	if frame.end.unbounded && frame.end.direction == PRECEDING {
+		return 0, 0, errors.New("frame end cannot be UNBOUNDED PRECEDING")
+	}
+	if frame.unit == RANGE && (!frame.start.unbounded && frame.start.direction != CURRENT ||
+		!frame.end.unbounded && frame.end.direction != CURRENT) && len(w.window.orderBy) != 1 {
+		return 0, 0, errors.New("RANGE with offset PRECEDING/FOLLOWING requires exactly one ORDER BY column")
+	}
+	start, err := w.frameBound(frame, frame.start, partition, orderStart, ix, peerStart, peerEnd, false)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := w.frameBound(frame, frame.end, partition, orderStart, ix, peerStart, peerEnd, true)
+	if err != nil {
+		return 0, 0, err
+	}
+	return max(0, start), min(len(partition), max(start, end)), nil
+}
+
+// the index of the first tuple of the frame, or the one behind its last tuple if isEnd
+func (w *GoSqlWindowFunction) frameBound(frame *GoSqlFrame, bound GoSqlFrameBound, partition [][]Value, orderStart int,
+	ix int, peerStart int, peerEnd int, isEnd bool) (int, error) {
+	if bound.unbounded {
+		if bound.direction == PRECEDING {
+			return 0, nil
+		}
+		return len(partition), nil
+	}
+	if frame.unit == ROWS {
+		res := int64(ix)
+		switch bound.direction {
+		case PRECEDING:
+			res -= bound.offset
+		case FOLLOWING:
+			res += bound.offset
+		}
+		if isEnd {
+			res++
+		}
+		return int(max(-1, min(res, int64(len(partition))))), nil
+	}
+	if bound.direction == CURRENT || partition[ix][orderStart] == nil {
+		// the peers of a tuple with NULL are the tuples at any distance
+		if isEnd {
+			return peerEnd, nil
+		}
+		return peerStart, nil
+	}
+	// the distance of the values in the order of the partition
+	value := func(row int) (float64, error) {
+		switch v := partition[row][orderStart].(type) {
+		case int64:
+			res := float64(v)
+			if w.window.orderBy[0].direction == DESC {
+				res = -res
+			}
+			return res, nil
+		case float64:
+			if w.window.orderBy[0].direction == DESC {
+				v = -v
+			}
+			return v, nil
+		}
+		return 0, errors.New("RANGE with offset PRECEDING/FOLLOWING requires an ORDER BY column of a numeric type")
+	}
+	current, err := value(ix)
+	if err != nil {
+		return 0, err
+	}
+	limit := current + float64(bound.offset)
+	if bound.direction == PRECEDING {
+		limit = current - float64(bound.offset)
+	}
+	// the tuples with NULL precede or follow the others
+	first, last := 0, len(partition)
+	for first < last && partition[first][orderStart] == nil {
+		first++
+	}
+	for last > first && partition[last-1][orderStart] == nil {
+		last--
+	}
+	return first + sort.Search(last-first, func(i int) bool {
+		v, _ := value(first + i)
+		if isEnd {
+			return v > limit
+		}
+		return v >= limit
+	}), nil
+}
+
+// the value of the function over the tuples of the partition from start up to end, the aggregate holds the one over
+// the frame of the tuple before
+func (w *GoSqlWindowFunction) aggregate(partition [][]Value, start int, end int, aggregate *windowAggregate) Value {
+	switch w.function {
+	case FIRST_VALUE:
+		if start < end {
+			return partition[start][0]
+		}
+		return nil
+	case LAST_VALUE:
+		if start < end {
+			return partition[end-1][0]
+		}
+		return nil
+	case COUNT:
+		if len(w.args) == 0 {
+			return int64(end - start)
+		}
+	}
+	aggregate.moveTo(partition, start, end)
+	return aggregate.value()
+}
+
+// COUNT, SUM, AVG, MIN or MAX over the tuples of a partition from start up to end. The frames of the tuples in the
+// order of the partition do not move back, so the tuples entering the frame are added to the aggregate and the ones
+// leaving it are taken back, instead of aggregating every frame from its start.
+type windowAggregate struct {
+	function int
+	start    int
+	end      int
+	count    int64 // of the values not NULL
+	sum      Value // of SUM and AVG
+	extreme  Value // of MIN and MAX
+}
+
+func (a *windowAggregate) moveTo(partition [][]Value, start int, end int) {
+	for ; a.start < start; a.start++ {
+		if a.start == a.end || !a.remove(partition[a.start][0]) {
+			// aggregated again from the new start
+			*a = windowAggregate{function: a.function, start: start, end: start}
+			break
+		}
+	}
+	for ; a.end < end; a.end++ {
+		a.add(partition[a.end][0])
+	}
+}
+
+func (a *windowAggregate) add(value Value) {
+	if value == nil {
+		return
+	}
+	a.count++
+	switch a.function {
+	case SUM, AVG:
+		if a.sum == nil {
+			a.sum = value
+		} else if sum, ok := a.sum.(int64); ok {
+			a.sum = sum + value.(int64)
+		} else {
+			a.sum = a.sum.(float64) + value.(float64)
+		}
+	case MIN:
+		if a.extreme == nil || compareNullable(value, a.extreme) < 0 {
+			a.extreme = value
+		}
+	case MAX:
+		if a.extreme == nil || compareNullable(value, a.extreme) > 0 {
+			a.extreme = value
+		}
+	}
+}
+
+// false, if the value can not be taken back: MIN and MAX do not know the extreme of the other values, a float sum
+// would lose precision
+func (a *windowAggregate) remove(value Value) bool {
+	if value == nil {
+		return true
+	}
+	switch a.function {
+	case MIN, MAX:
+		return false
+	case SUM, AVG:
+		sum, ok := a.sum.(int64)
+		if !ok {
+			return false
+		}
+		a.sum = sum - value.(int64)
+	}
+	a.count--
+	return true
+}
+
+func (a *windowAggregate) value() Value {
+	switch a.function {
+	case COUNT:
+		return a.count
+	case MIN, MAX:
+		return a.extreme
+	}
+	if a.count == 0 {
+		return nil
+	}
+	switch sum := a.sum.(type) {
+	case int64:
+		if a.function == AVG {
+			return float64(sum) / float64(a.count)
+		}
+	case float64:
+		if a.function == AVG {
+			return sum / float64(a.count)
+		}
+	}
+	return a.sum
+}
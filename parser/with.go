@@ -0,0 +1,153 @@
+package parser
+
+import (
+	. "database/sql/driver"
+	"fmt"
+	"maps"
+
+	. "github.com/aschoerk/go-sql-mem/data"
+)
+
+// WITH [RECURSIVE] of a select. Its common tables are materialized once per query, in the order they are written,
+// each one is visible to the ones following it and to the select. With RECURSIVE a common table of the form
+// non-recursive select UNION [ALL] recursive select is visible to its recursive select as well, which is
+// evaluated on the tuples it returned last until it returns no new ones.
+type GoSqlWith struct {
+	recursive bool
+	tables    []*GoSqlCommonTable
+}
+
+type GoSqlCommonTable struct {
+	name    string
+	columns []string // nil, if the columns are named like the ones of the select
+	query   *GoSqlSelectRequest
+}
+
+// materializes the common tables of the select, the ones of the selects it is part of stay visible unless hidden by
+// one of the same name. Returns the args following the placeholders of the common tables.
+func (r *GoSqlSelectRequest) materializeWith(args []Value) ([]Value, error) {
+	if r.with == nil {
+		return args, nil
+	}
+	visible := maps.Clone(r.commonTables)
+	if visible == nil {
+		visible = make(map[string]*TempTable)
+	}
+	r.commonTables = visible
+	for ix, table := range r.with.tables {
+		for _, other := range r.with.tables[:ix] {
+			if other.name == table.name {
+				return nil, fmt.Errorf("WITH query name %s specified more than once", table.name)
+			}
+		}
+		tableArgs := len(FindPlaceHoldersInSelect(table.query))
+		tempTable, err := r.with.materialize(r, table, args[:tableArgs])
+		if err != nil {
+			return nil, err
+		}
+		visible[table.name] = tempTable
+		args = args[tableArgs:]
+	}
+	return args, nil
+}
+
+// the tuples of the common table, its select is executed for the statement of the query
+func (w *GoSqlWith) materialize(query *GoSqlSelectRequest, table *GoSqlCommonTable, args []Value) (*TempTable, error) {
+	if w.recursive && referencesTable(table.query, table.name) {
+		return table.iterate(query, args)
+	}
+	table.query.StatementBaseData = StatementBaseData{Conn: query.Conn, State: Created, Sql: query.Sql, LockStrength: LOCK_FOR_UPDATE, LockWait: LOCK_WAIT, Views: query.Views}
+	table.query.commonTables = query.commonTables
+	columns, tuples, err := selectTuples(table.query, args)
+	if err != nil {
+		return nil, err
+	}
+	return table.tempTable(query, columns, tuples)
+}
+
+// the tuples of the non-recursive select united with the ones the recursive select returns, evaluated on the tuples
+// found last until it finds no new ones. Without ALL tuples found before are not returned again.
+func (t *GoSqlCommonTable) iterate(query *GoSqlSelectRequest, args []Value) (*TempTable, error) {
+	union := t.query.setOperation
+	if union == nil || union.operator != UNION || referencesTable(union.left, t.name) {
+		return nil, fmt.Errorf("recursive query %s does not have the form non-recursive-term UNION [ALL] recursive-term",
+			t.name)
+	}
+	if t.query.orderBy != nil || len(t.query.limit.terms()) > 0 {
+		return nil, fmt.Errorf("ORDER BY and LIMIT are not allowed in recursive query %s", t.name)
+	}
+	leftArgs := len(FindPlaceHoldersInSelect(union.left))
+	columns, tuples, err := operandTuples(query, union.left, args[:leftArgs])
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	if !union.all {
+		tuples = distinctTuples(tuples)
+		for _, values := range tuples {
+			seen[ValuesKey(values)] = true
+		}
+	}
+	result := tuples
+	for len(tuples) > 0 {
+		working, err := t.tempTable(query, columns, tuples)
+		if err != nil {
+			return nil, err
+		}
+		query.commonTables[t.name] = working
+		rightColumns, found, err := operandTuples(query, union.right, args[leftArgs:])
+		if err != nil {
+			return nil, err
+		}
+		if len(rightColumns) != len(columns) {
+			return nil, fmt.Errorf("each UNION query must have the same number of columns")
+		}
+		for ix, column := range columns {
+			err = convertColumn(found, ix, column.ParserType, rightColumns[ix].ParserType)
+			if err != nil {
+				return nil, fmt.Errorf("recursive query %s column %d has type %s in non-recursive term but type %s in recursive term",
+					t.name, ix+1, columnTypeName(column), columnTypeName(rightColumns[ix]))
+			}
+		}
+		tuples = nil
+		for _, values := range found {
+			key := ValuesKey(values)
+			if union.all || !seen[key] {
+				seen[key] = true
+				tuples = append(tuples, values)
+			}
+		}
+		result = append(result, tuples...)
+	}
+	return t.tempTable(query, columns, result)
+}
+
+// the table holding the tuples, named like the common table, its columns like the column list if there is one
+func (t *GoSqlCommonTable) tempTable(query *GoSqlSelectRequest, columns []GoSqlColumn, tuples [][]Value) (*TempTable, error) {
+	columns, err := nameColumns("WITH query "+t.name, t.columns, columns)
+	if err != nil {
+		return nil, err
+	}
+	return &TempTable{BaseTable: BaseTable{SchemaName: query.Conn.CurrentSchema, TableName: t.name, TableColumns: columns}, Tempdata: tuples}, nil
+}
+
+// whether the select reads the relation name in one of its from clauses
+func referencesTable(query *GoSqlSelectRequest, name string) bool {
+	if query.setOperation != nil {
+		return referencesTable(query.setOperation.left, name) || referencesTable(query.setOperation.right, name)
+	}
+	isName := func(identifier GoSqlAsIdentifier) bool {
+		return len(identifier.Id.Parts) == 1 && identifier.Id.Parts[0] == name
+	}
+	for _, spec := range query.from {
+		if isName(spec.Id) {
+			return true
+		}
+		for _, joinSpec := range spec.JoinSpecs {
+			if isName(joinSpec.JoinedTable) {
+				return true
+			}
+		}
+	}
+	return false
+}
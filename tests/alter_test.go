@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/aschoerk/go-sql-mem/data"
+	_ "github.com/aschoerk/go-sql-mem/driver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlterTable(t *testing.T) {
+	db, err := sql.Open("GoSql", "memory")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE alter_test (id INTEGER PRIMARY KEY, value TEXT, obsolete TEXT)")
+	assert.Nil(t, err)
+	_, err = db.Exec("INSERT INTO alter_test (id, value, obsolete) VALUES (1, '10', 'x'), (2, '20', 'y')")
+	assert.Nil(t, err)
+
+	// the tuples existing get the DEFAULT
+	_, err = db.Exec("ALTER TABLE alter_test ADD COLUMN amount INTEGER DEFAULT 7 CHECK (amount > 0)")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, countRows(t, db, "SELECT COUNT(*) FROM alter_test WHERE amount = 7"))
+	_, err = db.Exec("ALTER TABLE alter_test ADD COLUMN IF NOT EXISTS amount INTEGER")
+	assert.Nil(t, err)
+	_, err = db.Exec("ALTER TABLE alter_test ADD name TEXT NOT NULL")
+	assert.ErrorIs(t, err, data.ErrNotNullViolation)
+	_, err = db.Exec("INSERT INTO alter_test (id, value, amount) VALUES (3, '30', 0)")
+	assert.ErrorIs(t, err, data.ErrCheckViolation)
+
+	_, err = db.Exec("ALTER TABLE alter_test DROP COLUMN obsolete")
+	assert.Nil(t, err)
+	_, err = db.Exec("ALTER TABLE alter_test DROP COLUMN IF EXISTS obsolete")
+	assert.Nil(t, err)
+	rows, err := db.Query("SELECT * FROM alter_test")
+	assert.Nil(t, err)
+	columns, err := rows.Columns()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"id", "value", "amount"}, columns)
+	rows.Close()
+
+	// the values written before are converted, when they are read
+	_, err = db.Exec("ALTER TABLE alter_test ALTER COLUMN value TYPE INTEGER")
+	assert.Nil(t, err)
+	_, err = db.Exec("INSERT INTO alter_test (id, value) VALUES (3, 30)")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, countRows(t, db, "SELECT COUNT(*) FROM alter_test WHERE value > 15"))
+
+	// the CHECK refers to the column by its new name
+	_, err = db.Exec("ALTER TABLE alter_test RENAME COLUMN amount TO quantity")
+	assert.Nil(t, err)
+	_, err = db.Exec("UPDATE alter_test SET quantity = 0 WHERE id = 1")
+	assert.ErrorIs(t, err, data.ErrCheckViolation)
+	assert.Equal(t, 3, countRows(t, db, "SELECT COUNT(*) FROM alter_test WHERE quantity = 7"))
+
+	_, err = db.Exec("ALTER TABLE alter_test RENAME TO altered_test")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, countRows(t, db, "SELECT COUNT(*) FROM altered_test"))
+	_, err = db.Exec("SELECT COUNT(*) FROM alter_test")
+	assert.NotNil(t, err)
+}
+
+func TestAlterTableInTransaction(t *testing.T) {
+	db, err := sql.Open("GoSql", "memory:alter_transaction_test")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE alter_tx_test (id INTEGER PRIMARY KEY, value TEXT)")
+	assert.Nil(t, err)
+	_, err = db.Exec("INSERT INTO alter_tx_test (id, value) VALUES (1, '10')")
+	assert.Nil(t, err)
+
+	// a rollback could not undo the changes
+	tx, err := db.Begin()
+	assert.Nil(t, err)
+	_, err = tx.Exec("ALTER TABLE alter_tx_test ADD COLUMN amount INTEGER DEFAULT 7")
+	assert.NotNil(t, err)
+	_, err = tx.Exec("ALTER TABLE alter_tx_test RENAME TO renamed_tx_test")
+	assert.NotNil(t, err)
+	assert.Nil(t, tx.Rollback())
+	rows, err := db.Query("SELECT * FROM alter_tx_test")
+	assert.Nil(t, err)
+	columns, err := rows.Columns()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"id", "value"}, columns)
+	rows.Close()
+
+	// the rollback drops the table created by the transaction together with its changes
+	tx, err = db.Begin()
+	assert.Nil(t, err)
+	_, err = tx.Exec("CREATE TABLE created_tx_test (id INTEGER PRIMARY KEY)")
+	assert.Nil(t, err)
+	_, err = tx.Exec("ALTER TABLE created_tx_test ADD COLUMN amount INTEGER")
+	assert.Nil(t, err)
+	_, err = tx.Exec("INSERT INTO created_tx_test (id, amount) VALUES (1, 2)")
+	assert.Nil(t, err)
+	assert.Nil(t, tx.Rollback())
+	_, err = db.Exec("SELECT COUNT(*) FROM created_tx_test")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/aschoerk/go-sql-mem/data"
+	_ "github.com/aschoerk/go-sql-mem/driver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrimaryKeyAndUnique(t *testing.T) {
+	db, err := sql.Open("GoSql", "memory")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE key_test (code TEXT PRIMARY KEY, email TEXT UNIQUE, value INTEGER)")
+	assert.Nil(t, err)
+	table, index := data.OpenDatabase(data.DEFAULT_DATABASE_NAME).FindIndex("public", "key_test_pkey")
+	assert.NotNil(t, table)
+	assert.Equal(t, data.PRIMARY_KEY_CONSTRAINT, index.Kind)
+	_, index = data.OpenDatabase(data.DEFAULT_DATABASE_NAME).FindIndex("public", "key_test_email_key")
+	assert.Equal(t, data.UNIQUE_CONSTRAINT, index.Kind)
+
+	_, err = db.Exec("INSERT INTO key_test (code, email, value) VALUES ('a', 'a@x', 1), ('b', 'b@x', 2)")
+	assert.Nil(t, err)
+	_, err = db.Exec("INSERT INTO key_test (code, email, value) VALUES ('a', 'c@x', 3)")
+	assert.ErrorIs(t, err, data.ErrUniqueViolation)
+	_, err = db.Exec("INSERT INTO key_test (code, email, value) VALUES ('c', 'a@x', 3)")
+	assert.ErrorIs(t, err, data.ErrUniqueViolation)
+	_, err = db.Exec("INSERT INTO key_test (code, email, value) VALUES (NULL, 'c@x', 3)")
+	assert.NotNil(t, err)
+	// several NULLs do not violate a unique constraint
+	_, err = db.Exec("INSERT INTO key_test (code, email, value) VALUES ('c', NULL, 3), ('d', NULL, 4)")
+	assert.Nil(t, err)
+	_, err = db.Exec("UPDATE key_test SET code = 'a' WHERE code = 'b'")
+	assert.ErrorIs(t, err, data.ErrUniqueViolation)
+	assert.Equal(t, 4, countRows(t, db, "SELECT COUNT(*) FROM key_test"))
+
+	_, err = db.Exec("DROP INDEX key_test_pkey")
+	assert.NotNil(t, err)
+}
+
+func TestCompositeKeys(t *testing.T) {
+	db, err := sql.Open("GoSql", "memory")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE composite_test (a INTEGER, b INTEGER, c TEXT, PRIMARY KEY (a, b), UNIQUE (c, b))")
+	assert.Nil(t, err)
+	_, index := data.OpenDatabase(data.DEFAULT_DATABASE_NAME).FindIndex("public", "composite_test_c_b_key")
+	assert.NotNil(t, index)
+	_, err = db.Exec("CREATE TABLE composite_test2 (a INTEGER PRIMARY KEY, b INTEGER, PRIMARY KEY (b))")
+	assert.NotNil(t, err)
+
+	_, err = db.Exec("INSERT INTO composite_test (a, b, c) VALUES (1, 1, 'x'), (1, 2, 'x'), (2, 1, 'y')")
+	assert.Nil(t, err)
+	_, err = db.Exec("INSERT INTO composite_test (a, b, c) VALUES (1, 2, 'z')")
+	assert.ErrorIs(t, err, data.ErrUniqueViolation)
+	_, err = db.Exec("INSERT INTO composite_test (a, b, c) VALUES (3, 1, 'x')")
+	assert.ErrorIs(t, err, data.ErrUniqueViolation)
+	_, err = db.Exec("INSERT INTO composite_test (a, b, c) VALUES (1, NULL, 'z')")
+	assert.NotNil(t, err)
+	// a statement changing several tuples is checked after each one, so shifting keys fails
+	_, err = db.Exec("UPDATE composite_test SET b = b + 1 WHERE a = 1")
+	assert.ErrorIs(t, err, data.ErrUniqueViolation)
+	assert.Equal(t, 1, countRows(t, db, "SELECT COUNT(*) FROM composite_test WHERE a = 1 AND b = 1 AND c = 'x'"))
+}
+
+func TestUniqueWaitsForInsertingTransaction(t *testing.T) {
+	db, err := sql.Open("GoSql", "memory")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE unique_wait_test (id INTEGER PRIMARY KEY, value INTEGER)")
+	assert.Nil(t, err)
+
+	for id, commit := range []bool{true, false} {
+		first, err := db.BeginTx(context.Background(), nil)
+		assert.Nil(t, err)
+		_, err = first.Exec("INSERT INTO unique_wait_test (id, value) VALUES (?, 1)", id)
+		assert.Nil(t, err)
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			if commit {
+				first.Commit()
+			} else {
+				first.Rollback()
+			}
+		}()
+		second, err := db.BeginTx(context.Background(), nil)
+		assert.Nil(t, err)
+		start := time.Now()
+		_, err = second.Exec("INSERT INTO unique_wait_test (id, value) VALUES (?, 2)", id)
+		assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+		if commit {
+			assert.ErrorIs(t, err, data.ErrUniqueViolation)
+			second.Rollback()
+		} else {
+			assert.Nil(t, err)
+			assert.Nil(t, second.Commit())
+		}
+	}
+	assert.Equal(t, 1, countRows(t, db, "SELECT COUNT(*) FROM unique_wait_test WHERE value = 2"))
+}
+
+func TestNotNullDefaultAndCheck(t *testing.T) {
+	db, err := sql.Open("GoSql", "memory")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec(`CREATE TABLE column_constraint_test (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		amount INTEGER DEFAULT 10 CHECK (amount IS NULL OR amount > 0),
+		state TEXT NOT NULL DEFAULT 'new',
+		created TIMESTAMP DEFAULT CURRENT_TIMESTAMP)`)
+	assert.Nil(t, err)
+
+	before := time.Now()
+	_, err = db.Exec("INSERT INTO column_constraint_test (name) VALUES ('a')")
+	assert.Nil(t, err)
+	var amount int64
+	var state string
+	var created time.Time
+	err = db.QueryRow("SELECT amount, state, created FROM column_constraint_test WHERE name = 'a'").Scan(&amount, &state, &created)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(10), amount)
+	assert.Equal(t, "new", state)
+	assert.False(t, created.Before(before))
+
+	_, err = db.Exec("INSERT INTO column_constraint_test (amount) VALUES (1)")
+	assert.ErrorIs(t, err, data.ErrNotNullViolation)
+	_, err = db.Exec("INSERT INTO column_constraint_test (name, amount) VALUES ('b', 0)")
+	assert.ErrorIs(t, err, data.ErrCheckViolation)
+	_, err = db.Exec("UPDATE column_constraint_test SET amount = amount - 10 WHERE name = 'a'")
+	assert.ErrorIs(t, err, data.ErrCheckViolation)
+	assert.Equal(t, 1, countRows(t, db, "SELECT COUNT(*) FROM column_constraint_test WHERE amount = 10"))
+
+	_, err = db.Exec("CREATE TABLE column_constraint_test2 (a INTEGER, b INTEGER DEFAULT a)")
+	assert.NotNil(t, err)
+	_, err = db.Exec("CREATE TABLE column_constraint_test2 (a INTEGER CHECK (a + 1))")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/aschoerk/go-sql-mem/data"
+	_ "github.com/aschoerk/go-sql-mem/driver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDatabasesAreIsolated(t *testing.T) {
+	for i := 1; i <= 3; i++ {
+		name := fmt.Sprintf("database_test_%d", i)
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			_, err := data.CreateDatabase(name)
+			assert.Nil(t, err)
+			defer data.DestroyDatabase(name)
+			db, err := sql.Open("GoSql", "memory:"+name)
+			if err != nil {
+				t.Fatalf("Failed to open database: %v", err)
+			}
+			defer db.Close()
+			// the same names in every database
+			_, err = db.Exec("CREATE TABLE isolated (id INTEGER PRIMARY KEY)")
+			assert.Nil(t, err)
+			for id := 1; id <= i; id++ {
+				_, err = db.Exec("INSERT INTO isolated (id) VALUES (?)", id)
+				assert.Nil(t, err)
+			}
+			assert.Equal(t, i, countRows(t, db, "SELECT COUNT(*) FROM isolated"))
+		})
+	}
+}
+
+func TestDestroyDatabase(t *testing.T) {
+	_, err := data.CreateDatabase("database_test_destroy")
+	assert.Nil(t, err)
+	_, err = data.CreateDatabase("database_test_destroy")
+	assert.NotNil(t, err)
+	db, err := sql.Open("GoSql", "memory:database_test_destroy")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	_, err = db.Exec("CREATE TABLE destroyed (id INTEGER)")
+	assert.Nil(t, err)
+	db.Close()
+
+	assert.Nil(t, data.DestroyDatabase("database_test_destroy"))
+	assert.NotNil(t, data.DestroyDatabase("database_test_destroy"))
+	db, err = sql.Open("GoSql", "memory:database_test_destroy")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec("SELECT COUNT(*) FROM destroyed")
+	assert.NotNil(t, err)
+	assert.Nil(t, data.DestroyDatabase("database_test_destroy"))
+}
+
+func TestCreateDatabaseStatement(t *testing.T) {
+	db, err := sql.Open("GoSql", "memory:database_test_statement")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec("CREATE DATABASE database_test_created")
+	assert.Nil(t, err)
+	_, err = db.Exec("CREATE DATABASE database_test_created")
+	assert.NotNil(t, err)
+	_, err = db.Exec("CREATE DATABASE IF NOT EXISTS database_test_created")
+	assert.Nil(t, err)
+
+	// a database of its own, not a schema of the one of the connection
+	created, err := sql.Open("GoSql", "memory:database_test_created")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	_, err = created.Exec("CREATE TABLE created (id INTEGER)")
+	assert.Nil(t, err)
+	created.Close()
+	_, err = db.Exec("SELECT COUNT(*) FROM created")
+	assert.NotNil(t, err)
+	_, err = db.Exec("SELECT COUNT(*) FROM database_test_created.created")
+	assert.NotNil(t, err)
+
+	tx, err := db.Begin()
+	assert.Nil(t, err)
+	_, err = tx.Exec("DROP DATABASE database_test_created")
+	assert.NotNil(t, err)
+	assert.Nil(t, tx.Rollback())
+	_, err = db.Exec("DROP DATABASE database_test_statement")
+	assert.NotNil(t, err)
+
+	_, err = db.Exec("DROP DATABASE database_test_created")
+	assert.Nil(t, err)
+	assert.False(t, data.DatabaseExists("database_test_created"))
+	_, err = db.Exec("DROP DATABASE database_test_created")
+	assert.NotNil(t, err)
+	_, err = db.Exec("DROP DATABASE IF EXISTS database_test_created")
+	assert.Nil(t, err)
+}
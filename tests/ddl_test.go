@@ -0,0 +1,103 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	_ "github.com/aschoerk/go-sql-mem/driver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDropTable(t *testing.T) {
+	db, err := sql.Open("GoSql", "memory")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE drop_test (id INTEGER PRIMARY KEY, value TEXT)")
+	assert.Nil(t, err)
+	_, err = db.Exec("INSERT INTO drop_test (id, value) VALUES (1, 'a'), (2, 'b')")
+	assert.Nil(t, err)
+
+	// the table is restored by the rollback
+	tx, err := db.BeginTx(context.Background(), nil)
+	assert.Nil(t, err)
+	_, err = tx.Exec("DROP TABLE drop_test")
+	assert.Nil(t, err)
+	_, err = tx.Exec("SELECT COUNT(*) FROM drop_test")
+	assert.NotNil(t, err)
+	assert.Nil(t, tx.Rollback())
+	assert.Equal(t, 2, countRows(t, db, "SELECT COUNT(*) FROM drop_test"))
+
+	_, err = db.Exec("DROP TABLE drop_test")
+	assert.Nil(t, err)
+	_, err = db.Exec("SELECT COUNT(*) FROM drop_test")
+	assert.NotNil(t, err)
+	_, err = db.Exec("DROP TABLE drop_test")
+	assert.NotNil(t, err)
+	_, err = db.Exec("DROP TABLE IF EXISTS drop_test")
+	assert.Nil(t, err)
+}
+
+func TestCreateTableIsTransactional(t *testing.T) {
+	db, err := sql.Open("GoSql", "memory")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	tx, err := db.BeginTx(context.Background(), nil)
+	assert.Nil(t, err)
+	_, err = tx.Exec("CREATE TABLE create_tra_test (id INTEGER PRIMARY KEY)")
+	assert.Nil(t, err)
+	_, err = tx.Exec("INSERT INTO create_tra_test (id) VALUES (1)")
+	assert.Nil(t, err)
+	// other connections do not see the table before the commit
+	_, err = db.Exec("SELECT COUNT(*) FROM create_tra_test")
+	assert.NotNil(t, err)
+	assert.Nil(t, tx.Rollback())
+	_, err = db.Exec("SELECT COUNT(*) FROM create_tra_test")
+	assert.NotNil(t, err)
+
+	tx, err = db.BeginTx(context.Background(), nil)
+	assert.Nil(t, err)
+	_, err = tx.Exec("CREATE TABLE create_tra_test (id INTEGER PRIMARY KEY)")
+	assert.Nil(t, err)
+	_, err = tx.Exec("INSERT INTO create_tra_test (id) VALUES (1)")
+	assert.Nil(t, err)
+	assert.Nil(t, tx.Commit())
+	assert.Equal(t, 1, countRows(t, db, "SELECT COUNT(*) FROM create_tra_test"))
+}
+
+func TestTruncate(t *testing.T) {
+	db, err := sql.Open("GoSql", "memory")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE truncate_test (id INTEGER PRIMARY KEY)")
+	assert.Nil(t, err)
+	_, err = db.Exec("CREATE TABLE truncate_child (id INTEGER REFERENCES truncate_test)")
+	assert.Nil(t, err)
+	_, err = db.Exec("INSERT INTO truncate_test (id) VALUES (1), (2), (3)")
+	assert.Nil(t, err)
+	_, err = db.Exec("TRUNCATE truncate_test")
+	assert.NotNil(t, err)
+	_, err = db.Exec("DROP TABLE truncate_test")
+	assert.NotNil(t, err)
+	_, err = db.Exec("DROP TABLE truncate_child")
+	assert.Nil(t, err)
+
+	tx, err := db.BeginTx(context.Background(), nil)
+	assert.Nil(t, err)
+	res, err := tx.Exec("TRUNCATE TABLE truncate_test")
+	assert.Nil(t, err)
+	affected, _ := res.RowsAffected()
+	assert.Equal(t, int64(3), affected)
+	assert.Nil(t, tx.Rollback())
+	assert.Equal(t, 3, countRows(t, db, "SELECT COUNT(*) FROM truncate_test"))
+
+	_, err = db.Exec("TRUNCATE truncate_test")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, countRows(t, db, "SELECT COUNT(*) FROM truncate_test"))
+}
@@ -0,0 +1,88 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/aschoerk/go-sql-mem/data"
+	_ "github.com/aschoerk/go-sql-mem/driver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForeignKeys(t *testing.T) {
+	db, err := sql.Open("GoSql", "memory")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE fk_parent (id INTEGER PRIMARY KEY, code TEXT UNIQUE)")
+	assert.Nil(t, err)
+	_, err = db.Exec(`CREATE TABLE fk_child (
+		id INTEGER PRIMARY KEY,
+		parent INTEGER REFERENCES fk_parent ON DELETE CASCADE ON UPDATE SET NULL,
+		code TEXT,
+		FOREIGN KEY (code) REFERENCES fk_parent (code))`)
+	assert.Nil(t, err)
+	_, err = db.Exec("CREATE TABLE fk_other (id INTEGER REFERENCES fk_child (code))")
+	assert.NotNil(t, err)
+
+	_, err = db.Exec("INSERT INTO fk_parent (id, code) VALUES (1, 'a'), (2, 'b'), (3, 'c')")
+	assert.Nil(t, err)
+	_, err = db.Exec("INSERT INTO fk_child (id, parent, code) VALUES (1, 1, 'a'), (2, 1, 'b'), (3, 2, 'c')")
+	assert.Nil(t, err)
+	_, err = db.Exec("INSERT INTO fk_child (id, parent) VALUES (4, 4)")
+	assert.ErrorIs(t, err, data.ErrForeignKeyViolation)
+	_, err = db.Exec("UPDATE fk_child SET code = 'x' WHERE id = 1")
+	assert.ErrorIs(t, err, data.ErrForeignKeyViolation)
+
+	// the foreign key on code has NO ACTION
+	_, err = db.Exec("DELETE FROM fk_parent WHERE id = 3")
+	assert.ErrorIs(t, err, data.ErrForeignKeyViolation)
+	_, err = db.Exec("DELETE FROM fk_child WHERE id = 3")
+	assert.Nil(t, err)
+	_, err = db.Exec("DELETE FROM fk_parent WHERE id = 3")
+	assert.Nil(t, err)
+
+	_, err = db.Exec("UPDATE fk_parent SET id = 10 WHERE id = 1")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, countRows(t, db, "SELECT COUNT(*) FROM fk_child WHERE parent IS NULL"))
+	_, err = db.Exec("INSERT INTO fk_child (id, parent) VALUES (4, 10), (5, 10)")
+	assert.Nil(t, err)
+	_, err = db.Exec("UPDATE fk_child SET code = 'b' WHERE id = 1")
+	assert.Nil(t, err)
+	_, err = db.Exec("DELETE FROM fk_parent WHERE id = 10")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, countRows(t, db, "SELECT COUNT(*) FROM fk_child"))
+}
+
+func TestForeignKeyLocksParent(t *testing.T) {
+	db, err := sql.Open("GoSql", "memory")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE fk_lock_parent (id INTEGER PRIMARY KEY, value INTEGER)")
+	assert.Nil(t, err)
+	_, err = db.Exec("CREATE TABLE fk_lock_child (id INTEGER PRIMARY KEY, parent INTEGER REFERENCES fk_lock_parent)")
+	assert.Nil(t, err)
+	_, err = db.Exec("INSERT INTO fk_lock_parent (id, value) VALUES (1, 1)")
+	assert.Nil(t, err)
+
+	child, err := db.BeginTx(context.Background(), nil)
+	assert.Nil(t, err)
+	_, err = child.Exec("INSERT INTO fk_lock_child (id, parent) VALUES (1, 1)")
+	assert.Nil(t, err)
+	// the parent is locked FOR KEY SHARE, so it can be updated without changing its key
+	_, err = db.Exec("UPDATE fk_lock_parent SET value = 2 WHERE id = 1")
+	assert.Nil(t, err)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		child.Commit()
+	}()
+	start := time.Now()
+	_, err = db.Exec("DELETE FROM fk_lock_parent WHERE id = 1")
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	assert.ErrorIs(t, err, data.ErrForeignKeyViolation)
+}
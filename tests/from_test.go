@@ -88,7 +88,7 @@ func Test(t *testing.T) {
 			parser.YYDebug = 3
 			parseResult, _ := parser.Parse(tt.stmt)
 			r := parseResult.(*parser.GoSqlSelectRequest)
-			r.BaseData().Conn = &data.GoSqlConnData{CurrentSchema: "public"}
+			r.BaseData().Conn = &data.GoSqlConnData{CurrentSchema: "public", Database: data.OpenDatabase(data.DEFAULT_DATABASE_NAME)}
 			fromHandler := parser.GoSqlFromHandler{}
 			errs := fromHandler.Init(r)
 			assert.Equal(t, tt.errsNo, len(errs))
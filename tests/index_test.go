@@ -0,0 +1,136 @@
+package tests
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/aschoerk/go-sql-mem/data"
+	_ "github.com/aschoerk/go-sql-mem/driver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndexScan(t *testing.T) {
+	db, err := sql.Open("GoSql", "memory")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE index_test (id INTEGER PRIMARY KEY AUTOINCREMENT, category INTEGER, name TEXT)")
+	assert.Nil(t, err)
+	for i := 0; i < 100; i++ {
+		_, err = db.Exec("INSERT INTO index_test (category, name) VALUES (?, ?)", i%10, "name")
+		assert.Nil(t, err)
+	}
+	_, err = db.Exec("CREATE INDEX index_test_category ON index_test (category, name)")
+	assert.Nil(t, err)
+	table, index := data.OpenDatabase(data.DEFAULT_DATABASE_NAME).FindIndex("public", "index_test_category")
+	assert.NotNil(t, table)
+	assert.NotNil(t, index)
+
+	assert.Equal(t, 10, countRows(t, db, "SELECT COUNT(*) FROM index_test WHERE category = 3"))
+	assert.Equal(t, 10, countRows(t, db, "SELECT COUNT(*) FROM index_test WHERE category = ? AND name = 'name'", 3))
+	assert.Equal(t, 30, countRows(t, db, "SELECT COUNT(*) FROM index_test WHERE category >= 2 AND category < 5"))
+	assert.Equal(t, 30, countRows(t, db, "SELECT COUNT(*) FROM index_test WHERE 5 > category AND category > 1"))
+	assert.Equal(t, 40, countRows(t, db, "SELECT COUNT(*) FROM index_test WHERE category BETWEEN 6 AND 9"))
+	// the whole where clause is evaluated for the tuples found by the index
+	assert.Equal(t, 1, countRows(t, db, "SELECT COUNT(*) FROM index_test WHERE category = 3 AND id = 4"))
+
+	// the index follows updates and deletes
+	res, err := db.Exec("UPDATE index_test SET category = 30 WHERE category = 3")
+	assert.Nil(t, err)
+	rows, _ := res.RowsAffected()
+	assert.Equal(t, int64(10), rows)
+	assert.Equal(t, 0, countRows(t, db, "SELECT COUNT(*) FROM index_test WHERE category = 3"))
+	assert.Equal(t, 10, countRows(t, db, "SELECT COUNT(*) FROM index_test WHERE category = 30"))
+	_, err = db.Exec("DELETE FROM index_test WHERE category = 30")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, countRows(t, db, "SELECT COUNT(*) FROM index_test WHERE category = 30"))
+	_, err = db.Exec("VACUUM index_test")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, countRows(t, db, "SELECT COUNT(*) FROM index_test WHERE category = 30"))
+	assert.Equal(t, 10, countRows(t, db, "SELECT COUNT(*) FROM index_test WHERE category = 4"))
+
+	_, err = db.Exec("DROP INDEX index_test_category")
+	assert.Nil(t, err)
+	table, _ = data.OpenDatabase(data.DEFAULT_DATABASE_NAME).FindIndex("public", "index_test_category")
+	assert.Nil(t, table)
+	_, err = db.Exec("DROP INDEX index_test_category")
+	assert.NotNil(t, err)
+	_, err = db.Exec("DROP INDEX IF EXISTS index_test_category")
+	assert.Nil(t, err)
+	assert.Equal(t, 10, countRows(t, db, "SELECT COUNT(*) FROM index_test WHERE category = 4"))
+}
+
+func TestUniqueIndex(t *testing.T) {
+	db, err := sql.Open("GoSql", "memory")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE unique_index_test (id INTEGER PRIMARY KEY AUTOINCREMENT, code TEXT, value INTEGER)")
+	assert.Nil(t, err)
+	_, err = db.Exec("INSERT INTO unique_index_test (code, value) VALUES ('a', 1), ('b', 2), ('b', 3)")
+	assert.Nil(t, err)
+	_, err = db.Exec("CREATE UNIQUE INDEX unique_index_test_code ON unique_index_test (code)")
+	assert.NotNil(t, err)
+	_, err = db.Exec("DELETE FROM unique_index_test WHERE value = 3")
+	assert.Nil(t, err)
+	_, err = db.Exec("CREATE UNIQUE INDEX unique_index_test_code ON unique_index_test (code)")
+	assert.Nil(t, err)
+	_, err = db.Exec("CREATE UNIQUE INDEX unique_index_test_code ON unique_index_test (value)")
+	assert.NotNil(t, err)
+	_, err = db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS unique_index_test_code ON unique_index_test (value)")
+	assert.Nil(t, err)
+
+	_, err = db.Exec("INSERT INTO unique_index_test (code, value) VALUES ('a', 4)")
+	assert.NotNil(t, err)
+	_, err = db.Exec("UPDATE unique_index_test SET code = 'a' WHERE code = 'b'")
+	assert.NotNil(t, err)
+	// the failed statements did not change anything
+	assert.Equal(t, 1, countRows(t, db, "SELECT COUNT(*) FROM unique_index_test WHERE code = 'a'"))
+	assert.Equal(t, 1, countRows(t, db, "SELECT COUNT(*) FROM unique_index_test WHERE code = 'b'"))
+
+	// the key of a deleted tuple can be used again
+	_, err = db.Exec("DELETE FROM unique_index_test WHERE code = 'a'")
+	assert.Nil(t, err)
+	_, err = db.Exec("INSERT INTO unique_index_test (code, value) VALUES ('a', 5)")
+	assert.Nil(t, err)
+	assert.Equal(t, 5, countRows(t, db, "SELECT value FROM unique_index_test WHERE code = 'a'"))
+}
+
+func TestIndexInTransaction(t *testing.T) {
+	db, err := sql.Open("GoSql", "memory:index_transaction_test")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE index_tx_test (id INTEGER PRIMARY KEY, code TEXT)")
+	assert.Nil(t, err)
+	_, err = db.Exec("CREATE INDEX index_tx_test_kept ON index_tx_test (code)")
+	assert.Nil(t, err)
+
+	// a rollback could not undo the changes
+	tx, err := db.Begin()
+	assert.Nil(t, err)
+	_, err = tx.Exec("CREATE INDEX index_tx_test_code ON index_tx_test (code)")
+	assert.NotNil(t, err)
+	_, err = tx.Exec("DROP INDEX index_tx_test_kept")
+	assert.NotNil(t, err)
+	assert.Nil(t, tx.Rollback())
+	database := data.OpenDatabase("index_transaction_test")
+	table, _ := database.FindIndex("public", "index_tx_test_code")
+	assert.Nil(t, table)
+	table, _ = database.FindIndex("public", "index_tx_test_kept")
+	assert.NotNil(t, table)
+
+	// the rollback drops the table created by the transaction together with its indexes
+	tx, err = db.Begin()
+	assert.Nil(t, err)
+	_, err = tx.Exec("CREATE TABLE created_index_tx_test (id INTEGER PRIMARY KEY, code TEXT)")
+	assert.Nil(t, err)
+	_, err = tx.Exec("CREATE INDEX created_index_tx_test_code ON created_index_tx_test (code)")
+	assert.Nil(t, err)
+	assert.Nil(t, tx.Rollback())
+	table, _ = database.FindIndex("public", "created_index_tx_test_code")
+	assert.Nil(t, table)
+}
@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/aschoerk/go-sql-mem/data"
+	_ "github.com/aschoerk/go-sql-mem/driver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInformationSchema(t *testing.T) {
+	_, err := data.CreateDatabase("information_schema_test")
+	assert.Nil(t, err)
+	defer data.DestroyDatabase("information_schema_test")
+	db, err := sql.Open("GoSql", "memory:information_schema_test")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec("CREATE SCHEMA shop")
+	assert.Nil(t, err)
+	_, err = db.Exec("CREATE TABLE shop.customers (id INTEGER PRIMARY KEY, name VARCHAR(40) NOT NULL, email TEXT UNIQUE)")
+	assert.Nil(t, err)
+	_, err = db.Exec("CREATE TABLE shop.orders (id INTEGER PRIMARY KEY, customer_id INTEGER REFERENCES shop.customers, amount INTEGER CHECK (amount > 0))")
+	assert.Nil(t, err)
+
+	assert.Equal(t, 1, countRows(t, db, "SELECT COUNT(*) FROM information_schema.schemata WHERE schema_name = 'shop'"))
+	assert.Equal(t, 2, countRows(t, db, "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = 'shop' AND table_type = 'BASE TABLE'"))
+	assert.Equal(t, 6, countRows(t, db, "SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = 'shop'"))
+
+	rows, err := db.Query(`SELECT column_name, ordinal_position, is_nullable, data_type, character_maximum_length
+		FROM information_schema.columns WHERE table_name = 'customers' ORDER BY ordinal_position`)
+	assert.Nil(t, err)
+	var names, nullables, types []string
+	var lengths []sql.NullInt64
+	for rows.Next() {
+		var name, nullable, dataType string
+		var position int64
+		var length sql.NullInt64
+		assert.Nil(t, rows.Scan(&name, &position, &nullable, &dataType, &length))
+		names, nullables, types, lengths = append(names, name), append(nullables, nullable), append(types, dataType), append(lengths, length)
+	}
+	rows.Close()
+	assert.Equal(t, []string{"id", "name", "email"}, names)
+	assert.Equal(t, []string{"NO", "NO", "YES"}, nullables)
+	assert.Equal(t, []string{"integer", "character varying", "text"}, types)
+	assert.Equal(t, sql.NullInt64{Int64: 40, Valid: true}, lengths[1])
+
+	assert.Equal(t, 1, countRows(t, db, "SELECT COUNT(*) FROM information_schema.table_constraints WHERE table_name = 'orders' AND constraint_type = 'FOREIGN KEY'"))
+	assert.Equal(t, 1, countRows(t, db, "SELECT COUNT(*) FROM information_schema.table_constraints WHERE table_name = 'orders' AND constraint_type = 'CHECK'"))
+	assert.Equal(t, 2, countRows(t, db, "SELECT COUNT(*) FROM information_schema.table_constraints WHERE table_name = 'customers'"))
+
+	// the key columns joined with their constraints
+	var column string
+	err = db.QueryRow(`SELECT k.column_name FROM information_schema.key_column_usage k
+		JOIN information_schema.table_constraints c ON k.constraint_name = c.constraint_name
+		WHERE c.table_name = 'orders' AND c.constraint_type = 'FOREIGN KEY'`).Scan(&column)
+	assert.Nil(t, err)
+	assert.Equal(t, "customer_id", column)
+
+	// read-only
+	_, err = db.Exec("INSERT INTO information_schema.schemata (schema_name) VALUES ('x')")
+	assert.NotNil(t, err)
+	_, err = db.Exec("DELETE FROM information_schema.tables")
+	assert.NotNil(t, err)
+	_, err = db.Exec("CREATE TABLE information_schema.x (id INTEGER)")
+	assert.NotNil(t, err)
+	_, err = db.Exec("DROP SCHEMA information_schema")
+	assert.NotNil(t, err)
+
+	_, err = db.Exec("DROP TABLE shop.orders")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, countRows(t, db, "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = 'shop'"))
+}
@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/aschoerk/go-sql-mem/data"
+	_ "github.com/aschoerk/go-sql-mem/driver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimit(t *testing.T) {
+	_, err := data.CreateDatabase("limit_test")
+	assert.Nil(t, err)
+	defer data.DestroyDatabase("limit_test")
+	db, err := sql.Open("GoSql", "memory:limit_test")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE fruits (id INTEGER PRIMARY KEY, name TEXT, price INTEGER)")
+	assert.Nil(t, err)
+	_, err = db.Exec("INSERT INTO fruits (id, name, price) VALUES (1, 'apple', 3), (2, 'melon', 12), (3, 'cherry', 25), (4, 'mango', 8), (5, 'grape', 5)")
+	assert.Nil(t, err)
+
+	testCases := []struct {
+		name     string
+		query    string
+		args     []interface{}
+		expected []string
+	}{
+		{"limit", "SELECT name FROM fruits LIMIT 2", nil, []string{"apple", "melon"}},
+		{"limit offset", "SELECT name FROM fruits LIMIT 2 OFFSET 1", nil, []string{"melon", "cherry"}},
+		{"limit all", "SELECT name FROM fruits LIMIT ALL OFFSET 3", nil, []string{"mango", "grape"}},
+		{"limit zero", "SELECT name FROM fruits LIMIT 0", nil, nil},
+		{"offset beyond", "SELECT name FROM fruits OFFSET 10", nil, nil},
+		{"where", "SELECT name FROM fruits WHERE price > 4 LIMIT 2", nil, []string{"melon", "cherry"}},
+		{"ordered", "SELECT name FROM fruits ORDER BY price DESC LIMIT 3", nil, []string{"cherry", "melon", "mango"}},
+		{"ordered offset", "SELECT name FROM fruits ORDER BY price OFFSET 1 ROWS FETCH FIRST 2 ROWS ONLY", nil, []string{"grape", "mango"}},
+		{"fetch first row", "SELECT name FROM fruits ORDER BY price FETCH FIRST ROW ONLY", nil, []string{"apple"}},
+		{"fetch next", "SELECT name FROM fruits ORDER BY name FETCH NEXT 2 ROWS ONLY", nil, []string{"apple", "cherry"}},
+		{"placeholders", "SELECT name FROM fruits ORDER BY id LIMIT ? OFFSET ?", []interface{}{2, 3}, []string{"mango", "grape"}},
+		{"placeholders fetch", "SELECT name FROM fruits WHERE price < ? ORDER BY id OFFSET ? ROWS FETCH FIRST ? ROWS ONLY", []interface{}{20, 1, 2}, []string{"melon", "mango"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rows, err := db.Query(tc.query, tc.args...)
+			if err != nil {
+				t.Fatalf("Failed to execute query: %v", err)
+			}
+			defer rows.Close()
+			var names []string
+			for rows.Next() {
+				var name string
+				assert.Nil(t, rows.Scan(&name))
+				names = append(names, name)
+			}
+			assert.Equal(t, tc.expected, names)
+		})
+	}
+
+	var count int
+	assert.Nil(t, db.QueryRow("SELECT COUNT(*) FROM fruits LIMIT 1").Scan(&count))
+	assert.Equal(t, 5, count)
+	assert.Equal(t, sql.ErrNoRows, db.QueryRow("SELECT COUNT(*) FROM fruits OFFSET 1").Scan(&count))
+	_, err = db.Query("SELECT name FROM fruits LIMIT ?", -1)
+	assert.NotNil(t, err)
+	_, err = db.Query("SELECT name FROM fruits LIMIT ?", "two")
+	assert.NotNil(t, err)
+
+	// the words of FETCH FIRST are no reserved words
+	tx, err := db.Begin()
+	assert.Nil(t, err)
+	_, err = tx.Exec("SAVEPOINT first")
+	assert.Nil(t, err)
+	_, err = tx.Exec("ROLLBACK TO SAVEPOINT first")
+	assert.Nil(t, err)
+	assert.Nil(t, tx.Commit())
+	_, err = db.Exec("CREATE TABLE pages (first INTEGER, next INTEGER, rows INTEGER)")
+	assert.Nil(t, err)
+	_, err = db.Exec("INSERT INTO pages (first, next, rows) VALUES (1, 2, 10), (2, 3, 20)")
+	assert.Nil(t, err)
+	var rows int
+	assert.Nil(t, db.QueryRow("SELECT rows FROM pages ORDER BY next DESC FETCH FIRST ROW ONLY").Scan(&rows))
+	assert.Equal(t, 20, rows)
+}
@@ -0,0 +1,255 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/aschoerk/go-sql-mem/data"
+	_ "github.com/aschoerk/go-sql-mem/driver"
+	"github.com/stretchr/testify/assert"
+)
+
+// creates the table with a single row, which is locked by the returned transaction
+func lockWaitTestSetup(t *testing.T, table string) (*sql.DB, *sql.Tx) {
+	db, err := sql.Open("GoSql", "memory")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	_, err = db.Exec("CREATE TABLE " + table + " (id INTEGER PRIMARY KEY AUTOINCREMENT, value INTEGER)")
+	assert.Nil(t, err)
+	_, err = db.Exec("INSERT INTO " + table + " (value) VALUES (1)")
+	assert.Nil(t, err)
+	holder, err := db.BeginTx(context.Background(), nil)
+	assert.Nil(t, err)
+	_, err = holder.Exec("UPDATE " + table + " SET value = 2 WHERE value = 1")
+	assert.Nil(t, err)
+	return db, holder
+}
+
+func TestLockWaitWokenByCommit(t *testing.T) {
+	db, holder := lockWaitTestSetup(t, "lockwait_commit")
+	defer db.Close()
+
+	waiter, err := db.BeginTx(context.Background(), nil)
+	assert.Nil(t, err)
+	_, err = waiter.Exec("SET lock_timeout = '10s'")
+	assert.Nil(t, err)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		holder.Commit()
+	}()
+	start := time.Now()
+	res, err := waiter.Exec("UPDATE lockwait_commit SET value = value + 1 WHERE id = 1")
+	assert.Nil(t, err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+	rows, err := res.RowsAffected()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), rows)
+	assert.Nil(t, waiter.Commit())
+	assert.Equal(t, 1, countRows(t, db, "SELECT COUNT(*) FROM lockwait_commit WHERE value = 3"))
+}
+
+func TestLockWaitTimeout(t *testing.T) {
+	db, holder := lockWaitTestSetup(t, "lockwait_timeout")
+	defer db.Close()
+	defer holder.Rollback()
+
+	waiter, err := db.BeginTx(context.Background(), nil)
+	assert.Nil(t, err)
+	_, err = waiter.Exec("SET lock_timeout TO 100")
+	assert.Nil(t, err)
+	start := time.Now()
+	_, err = waiter.Exec("UPDATE lockwait_timeout SET value = 3 WHERE value = 1")
+	assert.ErrorIs(t, err, data.ErrTraLockTimeout)
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	waiter.Rollback()
+}
+
+func TestLockWaitCancelledByContext(t *testing.T) {
+	db, holder := lockWaitTestSetup(t, "lockwait_cancel")
+	defer db.Close()
+	defer holder.Rollback()
+
+	waiter, err := db.BeginTx(context.Background(), nil)
+	assert.Nil(t, err)
+	// 0 waits without limit
+	_, err = waiter.Exec("SET lock_timeout = 0")
+	assert.Nil(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = waiter.ExecContext(ctx, "UPDATE lockwait_cancel SET value = 3 WHERE value = 1")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	waiter.Rollback()
+}
+
+func TestDeadlockDetected(t *testing.T) {
+	db, err := sql.Open("GoSql", "memory")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE deadlock_test (id INTEGER PRIMARY KEY AUTOINCREMENT, value INTEGER)")
+	assert.Nil(t, err)
+	_, err = db.Exec("INSERT INTO deadlock_test (value) VALUES (1)")
+	assert.Nil(t, err)
+	_, err = db.Exec("INSERT INTO deadlock_test (value) VALUES (2)")
+	assert.Nil(t, err)
+
+	tx1, err := db.BeginTx(context.Background(), nil)
+	assert.Nil(t, err)
+	tx2, err := db.BeginTx(context.Background(), nil)
+	assert.Nil(t, err)
+	var id int64
+	assert.Nil(t, tx1.QueryRow("SELECT id FROM deadlock_test WHERE value = 1 FOR UPDATE").Scan(&id))
+	assert.Nil(t, tx2.QueryRow("SELECT id FROM deadlock_test WHERE value = 2 FOR UPDATE").Scan(&id))
+
+	done := make(chan error)
+	go func() {
+		_, err := tx1.Exec("UPDATE deadlock_test SET value = 20 WHERE value = 2")
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	_, err = tx2.Exec("UPDATE deadlock_test SET value = 10 WHERE value = 1")
+	assert.ErrorIs(t, err, data.ErrDeadlock)
+	tx2.Rollback()
+	// the victim got rolled back, so the other one can go on
+	assert.Nil(t, <-done)
+	assert.Nil(t, tx1.Commit())
+	assert.Equal(t, 1, countRows(t, db, "SELECT COUNT(*) FROM deadlock_test WHERE value = 20"))
+}
+
+func TestLockWaitRepeatableRead(t *testing.T) {
+	db, err := sql.Open("GoSql", "memory")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE lockwait_repeatable (id INTEGER PRIMARY KEY AUTOINCREMENT, value INTEGER)")
+	assert.Nil(t, err)
+	_, err = db.Exec("INSERT INTO lockwait_repeatable (value) VALUES (1), (2)")
+	assert.Nil(t, err)
+	ctx := context.Background()
+	repeatableRead := &sql.TxOptions{Isolation: sql.LevelRepeatableRead}
+
+	// waiting for a transaction, which only locked the tuple, succeeds
+	holder, err := db.BeginTx(ctx, repeatableRead)
+	assert.Nil(t, err)
+	waiter, err := db.BeginTx(ctx, repeatableRead)
+	assert.Nil(t, err)
+	var id int64
+	assert.Nil(t, holder.QueryRow("SELECT id FROM lockwait_repeatable WHERE value = 1 FOR UPDATE").Scan(&id))
+	assert.Equal(t, 2, countTxRows(t, waiter, "SELECT COUNT(*) FROM lockwait_repeatable"))
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		holder.Commit()
+	}()
+	_, err = waiter.Exec("UPDATE lockwait_repeatable SET value = 10 WHERE value = 1")
+	assert.Nil(t, err)
+	assert.Nil(t, waiter.Commit())
+
+	// waiting for a transaction, which changed the tuple, fails
+	holder, err = db.BeginTx(ctx, repeatableRead)
+	assert.Nil(t, err)
+	waiter, err = db.BeginTx(ctx, repeatableRead)
+	assert.Nil(t, err)
+	_, err = holder.Exec("UPDATE lockwait_repeatable SET value = 20 WHERE value = 2")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, countTxRows(t, waiter, "SELECT COUNT(*) FROM lockwait_repeatable"))
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		holder.Commit()
+	}()
+	_, err = waiter.Exec("UPDATE lockwait_repeatable SET value = 30 WHERE value = 2")
+	assert.ErrorIs(t, err, data.ErrTraSerialization)
+	waiter.Rollback()
+	assert.Equal(t, 1, countRows(t, db, "SELECT COUNT(*) FROM lockwait_repeatable WHERE value = 10"))
+	assert.Equal(t, 1, countRows(t, db, "SELECT COUNT(*) FROM lockwait_repeatable WHERE value = 20"))
+}
+
+func countTxRows(t *testing.T, tx *sql.Tx, query string) int {
+	var count int
+	err := tx.QueryRow(query).Scan(&count)
+	if err != nil {
+		t.Fatalf("Failed to count rows: %v", err)
+	}
+	return count
+}
+
+func TestSkipLockedAndNowait(t *testing.T) {
+	db, err := sql.Open("GoSql", "memory")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE jobs_test (id INTEGER PRIMARY KEY AUTOINCREMENT, state INTEGER)")
+	assert.Nil(t, err)
+	for i := 0; i < 3; i++ {
+		_, err = db.Exec("INSERT INTO jobs_test (state) VALUES (0)")
+		assert.Nil(t, err)
+	}
+
+	// worker i is interested in the first i+1 jobs, it gets the one not locked by the workers before
+	var workers []*sql.Tx
+	for i := 0; i < 3; i++ {
+		worker, err := db.BeginTx(context.Background(), nil)
+		assert.Nil(t, err)
+		workers = append(workers, worker)
+		var id int64
+		assert.Nil(t, worker.QueryRow("SELECT id FROM jobs_test WHERE state = 0 AND id <= ? FOR UPDATE SKIP LOCKED", i+1).Scan(&id))
+		assert.Equal(t, int64(i+1), id)
+	}
+	late, err := db.BeginTx(context.Background(), nil)
+	assert.Nil(t, err)
+	var id int64
+	assert.ErrorIs(t, late.QueryRow("SELECT id FROM jobs_test WHERE state = 0 FOR UPDATE SKIP LOCKED").Scan(&id), sql.ErrNoRows)
+	start := time.Now()
+	_, err = late.Query("SELECT id FROM jobs_test WHERE state = 0 FOR UPDATE NOWAIT")
+	assert.ErrorIs(t, err, data.ErrLockNotAvailable)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	late.Rollback()
+	for _, worker := range workers {
+		assert.Nil(t, worker.Rollback())
+	}
+}
+
+func TestShareLocks(t *testing.T) {
+	db, err := sql.Open("GoSql", "memory")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE share_test (id INTEGER PRIMARY KEY AUTOINCREMENT, value INTEGER)")
+	assert.Nil(t, err)
+	_, err = db.Exec("INSERT INTO share_test (value) VALUES (1)")
+	assert.Nil(t, err)
+
+	begin := func() *sql.Tx {
+		tx, err := db.BeginTx(context.Background(), nil)
+		assert.Nil(t, err)
+		return tx
+	}
+	var id int64
+	reader1, reader2 := begin(), begin()
+	assert.Nil(t, reader1.QueryRow("SELECT id FROM share_test WHERE value = 1 FOR SHARE").Scan(&id))
+	assert.Nil(t, reader2.QueryRow("SELECT id FROM share_test WHERE value = 1 FOR SHARE NOWAIT").Scan(&id))
+	writer := begin()
+	_, err = writer.Query("SELECT id FROM share_test FOR UPDATE NOWAIT")
+	assert.ErrorIs(t, err, data.ErrLockNotAvailable)
+	assert.Nil(t, reader1.Commit())
+	assert.Nil(t, reader2.Commit())
+
+	// an update not changing the key only conflicts with FOR SHARE, not with FOR KEY SHARE
+	keyReader := begin()
+	assert.Nil(t, keyReader.QueryRow("SELECT id FROM share_test FOR KEY SHARE").Scan(&id))
+	_, err = writer.Exec("SET lock_timeout = 100")
+	assert.Nil(t, err)
+	_, err = writer.Exec("UPDATE share_test SET value = 2 WHERE id = 1")
+	assert.Nil(t, err)
+	_, err = keyReader.Query("SELECT id FROM share_test FOR SHARE NOWAIT")
+	assert.ErrorIs(t, err, data.ErrLockNotAvailable)
+	assert.Nil(t, writer.Commit())
+	keyReader.Rollback()
+	assert.Equal(t, 1, countRows(t, db, "SELECT COUNT(*) FROM share_test WHERE value = 2"))
+}
@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/aschoerk/go-sql-mem/data"
+	_ "github.com/aschoerk/go-sql-mem/driver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaterializedView(t *testing.T) {
+	_, err := data.CreateDatabase("matview_test")
+	assert.Nil(t, err)
+	defer data.DestroyDatabase("matview_test")
+	db, err := sql.Open("GoSql", "memory:matview_test")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE orders (id INTEGER PRIMARY KEY, customer TEXT, amount INTEGER)")
+	assert.Nil(t, err)
+	_, err = db.Exec("INSERT INTO orders (id, customer, amount) VALUES (1, 'anna', 10), (2, 'bert', 20), (3, 'anna', 5)")
+	assert.Nil(t, err)
+	_, err = db.Exec("CREATE MATERIALIZED VIEW big_orders (customer, amount) AS SELECT customer, amount FROM orders WHERE amount >= 10")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, countRows(t, db, "SELECT COUNT(*) FROM big_orders"))
+	_, err = db.Exec("CREATE MATERIALIZED VIEW big_orders AS SELECT customer FROM orders")
+	assert.NotNil(t, err)
+	_, err = db.Exec("CREATE MATERIALIZED VIEW IF NOT EXISTS big_orders AS SELECT customer FROM orders")
+	assert.Nil(t, err)
+
+	// only REFRESH changes the tuples
+	_, err = db.Exec("INSERT INTO big_orders (customer, amount) VALUES ('carl', 30)")
+	assert.NotNil(t, err)
+	_, err = db.Exec("DELETE FROM big_orders")
+	assert.NotNil(t, err)
+	_, err = db.Exec("DROP TABLE big_orders")
+	assert.NotNil(t, err)
+	_, err = db.Exec("INSERT INTO orders (id, customer, amount) VALUES (4, 'carl', 30)")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, countRows(t, db, "SELECT COUNT(*) FROM big_orders"))
+	_, err = db.Exec("REFRESH MATERIALIZED VIEW big_orders")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, countRows(t, db, "SELECT COUNT(*) FROM big_orders"))
+	var amount int
+	assert.Nil(t, db.QueryRow("SELECT amount FROM big_orders WHERE customer = 'carl'").Scan(&amount))
+	assert.Equal(t, 30, amount)
+
+	// readers are not blocked and see the tuples committed before
+	tx, err := db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+	assert.Nil(t, err)
+	var count int
+	assert.Nil(t, tx.QueryRow("SELECT COUNT(*) FROM big_orders").Scan(&count))
+	assert.Equal(t, 3, count)
+	refreshing, err := db.BeginTx(context.Background(), nil)
+	assert.Nil(t, err)
+	_, err = refreshing.Exec("DELETE FROM orders WHERE customer = 'bert'")
+	assert.Nil(t, err)
+	_, err = refreshing.Exec("REFRESH MATERIALIZED VIEW CONCURRENTLY big_orders")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, countRows(t, db, "SELECT COUNT(*) FROM big_orders"))
+	assert.Nil(t, refreshing.Commit())
+	assert.Equal(t, 2, countRows(t, db, "SELECT COUNT(*) FROM big_orders"))
+	assert.Nil(t, tx.QueryRow("SELECT COUNT(*) FROM big_orders").Scan(&count))
+	assert.Equal(t, 3, count)
+	assert.Nil(t, tx.Commit())
+
+	_, err = db.Exec("REFRESH MATERIALIZED VIEW orders")
+	assert.NotNil(t, err)
+	_, err = db.Exec("DROP VIEW big_orders")
+	assert.NotNil(t, err)
+	_, err = db.Exec("DROP MATERIALIZED VIEW orders")
+	assert.NotNil(t, err)
+	_, err = db.Exec("DROP MATERIALIZED VIEW big_orders")
+	assert.Nil(t, err)
+	_, err = db.Exec("SELECT COUNT(*) FROM big_orders")
+	assert.NotNil(t, err)
+	_, err = db.Exec("DROP MATERIALIZED VIEW IF EXISTS big_orders")
+	assert.Nil(t, err)
+}
@@ -0,0 +1,115 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	_ "github.com/aschoerk/go-sql-mem/driver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSavepoints(t *testing.T) {
+	db, err := sql.Open("GoSql", "memory")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE savepoint_test (id INTEGER PRIMARY KEY AUTOINCREMENT, value INTEGER)")
+	assert.Nil(t, err)
+	_, err = db.Exec("INSERT INTO savepoint_test (value) VALUES (1)")
+	assert.Nil(t, err)
+
+	tx, err := db.BeginTx(context.Background(), nil)
+	assert.Nil(t, err)
+	_, err = tx.Exec("INSERT INTO savepoint_test (value) VALUES (2)")
+	assert.Nil(t, err)
+	_, err = tx.Exec("SAVEPOINT first")
+	assert.Nil(t, err)
+	_, err = tx.Exec("UPDATE savepoint_test SET value = 10 WHERE value = 1")
+	assert.Nil(t, err)
+	_, err = tx.Exec("SAVEPOINT second")
+	assert.Nil(t, err)
+	_, err = tx.Exec("DELETE FROM savepoint_test WHERE value = 2")
+	assert.Nil(t, err)
+	_, err = tx.Exec("INSERT INTO savepoint_test (value) VALUES (3)")
+	assert.Nil(t, err)
+
+	_, err = tx.Exec("ROLLBACK TO SAVEPOINT second")
+	assert.Nil(t, err)
+	var count int
+	assert.Nil(t, tx.QueryRow("SELECT COUNT(*) FROM savepoint_test WHERE value = 10 OR value = 2").Scan(&count))
+	assert.Equal(t, 2, count)
+	assert.Nil(t, tx.QueryRow("SELECT COUNT(*) FROM savepoint_test").Scan(&count))
+	assert.Equal(t, 2, count)
+
+	_, err = tx.Exec("ROLLBACK TO first")
+	assert.Nil(t, err)
+	// second does not exist anymore
+	_, err = tx.Exec("RELEASE SAVEPOINT second")
+	assert.NotNil(t, err)
+	_, err = tx.Exec("RELEASE first")
+	assert.Nil(t, err)
+	assert.Nil(t, tx.Commit())
+
+	assert.Equal(t, 1, countRows(t, db, "SELECT COUNT(*) FROM savepoint_test WHERE value = 1"))
+	assert.Equal(t, 1, countRows(t, db, "SELECT COUNT(*) FROM savepoint_test WHERE value = 2"))
+	assert.Equal(t, 2, countRows(t, db, "SELECT COUNT(*) FROM savepoint_test"))
+}
+
+func TestSavepointAsFirstStatement(t *testing.T) {
+	db, err := sql.Open("GoSql", "memory")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	conn, err := db.Conn(context.Background())
+	assert.Nil(t, err)
+	defer conn.Close()
+	_, err = conn.ExecContext(context.Background(), "CREATE TABLE savepoint_first (value INTEGER)")
+	assert.Nil(t, err)
+	for _, statement := range []string{"BEGIN", "SAVEPOINT first", "INSERT INTO savepoint_first (value) VALUES (1)",
+		"ROLLBACK TO SAVEPOINT first", "INSERT INTO savepoint_first (value) VALUES (2)", "COMMIT"} {
+		_, err = conn.ExecContext(context.Background(), statement)
+		assert.Nil(t, err, statement)
+	}
+	assert.Equal(t, 1, countRows(t, db, "SELECT COUNT(*) FROM savepoint_first"))
+	assert.Equal(t, 1, countRows(t, db, "SELECT COUNT(*) FROM savepoint_first WHERE value = 2"))
+}
+
+func TestRollbackToSavepointAfterVacuum(t *testing.T) {
+	// transactions left running by other tests would keep every version alive
+	db, err := sql.Open("GoSql", "memory:savepoint_vacuum_test")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE savepoint_vacuum_test (id INTEGER PRIMARY KEY, value INTEGER)")
+	assert.Nil(t, err)
+	// two committed versions below the horizon of the transaction
+	_, err = db.Exec("INSERT INTO savepoint_vacuum_test (id, value) VALUES (1, 1)")
+	assert.Nil(t, err)
+	_, err = db.Exec("UPDATE savepoint_vacuum_test SET value = 2 WHERE id = 1")
+	assert.Nil(t, err)
+
+	tx, err := db.BeginTx(context.Background(), nil)
+	assert.Nil(t, err)
+	_, err = tx.Exec("SAVEPOINT s")
+	assert.Nil(t, err)
+	_, err = tx.Exec("UPDATE savepoint_vacuum_test SET value = 3 WHERE id = 1")
+	assert.Nil(t, err)
+	// removes the first version, while the transaction holds the undo of the header of the second one
+	res, err := db.Exec("VACUUM savepoint_vacuum_test")
+	assert.Nil(t, err)
+	removed, _ := res.RowsAffected()
+	assert.Equal(t, int64(1), removed)
+	_, err = tx.Exec("ROLLBACK TO SAVEPOINT s")
+	assert.Nil(t, err)
+	var value int
+	assert.Nil(t, tx.QueryRow("SELECT value FROM savepoint_vacuum_test WHERE id = 1").Scan(&value))
+	assert.Equal(t, 2, value)
+	_, err = tx.Exec("UPDATE savepoint_vacuum_test SET value = 4 WHERE id = 1")
+	assert.Nil(t, err)
+	assert.Nil(t, tx.Commit())
+	assert.Equal(t, 1, countRows(t, db, "SELECT COUNT(*) FROM savepoint_vacuum_test WHERE value = 4"))
+}
@@ -0,0 +1,98 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	_ "github.com/aschoerk/go-sql-mem/driver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchemas(t *testing.T) {
+	db, err := sql.Open("GoSql", "memory")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	// the search path is set per connection
+	tenantA, err := db.Conn(ctx)
+	assert.Nil(t, err)
+	defer tenantA.Close()
+	tenantB, err := db.Conn(ctx)
+	assert.Nil(t, err)
+	defer tenantB.Close()
+
+	_, err = tenantA.ExecContext(ctx, "CREATE SCHEMA schema_tenant_a")
+	assert.Nil(t, err)
+	_, err = tenantA.ExecContext(ctx, "CREATE SCHEMA schema_tenant_a")
+	assert.NotNil(t, err)
+	_, err = tenantA.ExecContext(ctx, "CREATE SCHEMA IF NOT EXISTS schema_tenant_a")
+	assert.Nil(t, err)
+	_, err = tenantB.ExecContext(ctx, "CREATE SCHEMA schema_tenant_b")
+	assert.Nil(t, err)
+	_, err = tenantA.ExecContext(ctx, "SET SCHEMA 'schema_tenant_a'")
+	assert.Nil(t, err)
+	_, err = tenantB.ExecContext(ctx, "USE schema_tenant_b")
+	assert.Nil(t, err)
+	_, err = tenantB.ExecContext(ctx, "USE schema_tenant_missing")
+	assert.NotNil(t, err)
+
+	for _, conn := range []*sql.Conn{tenantA, tenantB} {
+		_, err = conn.ExecContext(ctx, "CREATE TABLE schema_orders (id INTEGER PRIMARY KEY)")
+		assert.Nil(t, err)
+	}
+	_, err = tenantA.ExecContext(ctx, "INSERT INTO schema_orders (id) VALUES (1), (2)")
+	assert.Nil(t, err)
+	_, err = tenantB.ExecContext(ctx, "INSERT INTO schema_orders (id) VALUES (1)")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, countConnRows(t, tenantA, "SELECT COUNT(*) FROM schema_orders"))
+	assert.Equal(t, 1, countConnRows(t, tenantB, "SELECT COUNT(*) FROM schema_orders"))
+	assert.Equal(t, 2, countConnRows(t, tenantB, "SELECT COUNT(*) FROM schema_tenant_a.schema_orders"))
+	_, err = db.Exec("SELECT COUNT(*) FROM schema_orders")
+	assert.NotNil(t, err)
+
+	// tables of public are found after the ones of the tenant
+	_, err = db.Exec("CREATE TABLE schema_shared (id INTEGER)")
+	assert.Nil(t, err)
+	_, err = tenantA.ExecContext(ctx, "SET search_path TO schema_tenant_a, public")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, countConnRows(t, tenantA, "SELECT COUNT(*) FROM schema_shared"))
+	assert.Equal(t, 2, countConnRows(t, tenantA, "SELECT COUNT(*) FROM schema_orders"))
+
+	// the creation of a schema is transactional
+	tx, err := db.BeginTx(ctx, nil)
+	assert.Nil(t, err)
+	_, err = tx.Exec("CREATE SCHEMA schema_tenant_c")
+	assert.Nil(t, err)
+	_, err = tx.Exec("CREATE TABLE schema_tenant_c.schema_orders (id INTEGER)")
+	assert.Nil(t, err)
+	assert.Nil(t, tx.Rollback())
+	_, err = db.Exec("SELECT COUNT(*) FROM schema_tenant_c.schema_orders")
+	assert.NotNil(t, err)
+	_, err = db.Exec("CREATE TABLE schema_tenant_c.schema_orders (id INTEGER)")
+	assert.NotNil(t, err)
+
+	_, err = db.Exec("DROP SCHEMA schema_tenant_b")
+	assert.NotNil(t, err)
+	_, err = db.Exec("DROP SCHEMA schema_tenant_b CASCADE")
+	assert.Nil(t, err)
+	_, err = db.Exec("SELECT COUNT(*) FROM schema_tenant_b.schema_orders")
+	assert.NotNil(t, err)
+	_, err = db.Exec("DROP SCHEMA schema_tenant_a CASCADE")
+	assert.Nil(t, err)
+	_, err = db.Exec("DROP SCHEMA IF EXISTS schema_tenant_a")
+	assert.Nil(t, err)
+	_, err = db.Exec("DROP SCHEMA public")
+	assert.NotNil(t, err)
+}
+
+func countConnRows(t *testing.T, conn *sql.Conn, query string) int {
+	var count int
+	err := conn.QueryRowContext(context.Background(), query).Scan(&count)
+	if err != nil {
+		t.Fatalf("Failed to count rows: %v", err)
+	}
+	return count
+}
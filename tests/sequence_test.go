@@ -0,0 +1,123 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/aschoerk/go-sql-mem/data"
+	_ "github.com/aschoerk/go-sql-mem/driver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSequence(t *testing.T) {
+	_, err := data.CreateDatabase("sequence_test")
+	assert.Nil(t, err)
+	defer data.DestroyDatabase("sequence_test")
+	db, err := sql.Open("GoSql", "memory:sequence_test")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec("CREATE SEQUENCE order_numbers INCREMENT BY 10 START WITH 100")
+	assert.Nil(t, err)
+	_, err = db.Exec("CREATE SEQUENCE order_numbers")
+	assert.NotNil(t, err)
+	_, err = db.Exec("CREATE SEQUENCE IF NOT EXISTS order_numbers")
+	assert.Nil(t, err)
+	_, err = db.Exec("CREATE TABLE orders (nr INTEGER, item TEXT)")
+	assert.Nil(t, err)
+	// a session of its own, kept open so the statements of db do not run on it
+	conn, err := db.Conn(context.Background())
+	assert.Nil(t, err)
+	defer conn.Close()
+	_, err = db.Exec("INSERT INTO orders (nr, item) VALUES (nextval('order_numbers'), 'apple'), (nextval('order_numbers'), 'melon')")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, countRows(t, db, "SELECT COUNT(*) FROM orders WHERE nr = 110"))
+
+	// currval is the value nextval returned last in the session
+	var value int64
+	assert.NotNil(t, conn.QueryRowContext(context.Background(), "SELECT currval('order_numbers') FROM orders").Scan(&value))
+	assert.Nil(t, conn.QueryRowContext(context.Background(), "SELECT nextval('order_numbers') FROM order_numbers").Scan(&value))
+	assert.Equal(t, int64(120), value)
+	assert.Nil(t, conn.QueryRowContext(context.Background(), "SELECT currval('order_numbers') FROM order_numbers").Scan(&value))
+	assert.Equal(t, int64(120), value)
+	var called bool
+	assert.Nil(t, db.QueryRow("SELECT last_value, is_called FROM order_numbers").Scan(&value, &called))
+	assert.Equal(t, int64(120), value)
+	assert.True(t, called)
+
+	// values are not given back by a rollback
+	tx, err := db.BeginTx(context.Background(), nil)
+	assert.Nil(t, err)
+	_, err = tx.Exec("INSERT INTO orders (nr, item) VALUES (nextval('order_numbers'), 'cherry')")
+	assert.Nil(t, err)
+	assert.Nil(t, tx.Rollback())
+	_, err = db.Exec("INSERT INTO orders (nr, item) VALUES (nextval('order_numbers'), 'cherry')")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, countRows(t, db, "SELECT COUNT(*) FROM orders WHERE nr = 140"))
+
+	_, err = db.Exec("UPDATE orders SET nr = setval('order_numbers', 500, false) WHERE item = 'cherry'")
+	assert.Nil(t, err)
+	_, err = db.Exec("INSERT INTO orders (nr, item) VALUES (nextval('order_numbers'), 'mango')")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, countRows(t, db, "SELECT COUNT(*) FROM orders WHERE nr = 500"))
+	_, err = db.Exec("INSERT INTO orders (nr, item) VALUES (nextval('missing'), 'grape')")
+	assert.NotNil(t, err)
+
+	_, err = db.Exec("CREATE SEQUENCE countdown INCREMENT BY -1 MINVALUE 1 MAXVALUE 2 CYCLE")
+	assert.Nil(t, err)
+	_, err = db.Exec("CREATE TABLE counts (n INTEGER DEFAULT nextval('countdown'), item TEXT)")
+	assert.Nil(t, err)
+	_, err = db.Exec("INSERT INTO counts (item) VALUES ('a'), ('b'), ('c')")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, countRows(t, db, "SELECT COUNT(*) FROM counts WHERE n = 2"))
+	_, err = db.Exec("CREATE SEQUENCE limited MAXVALUE 2 START WITH 2")
+	assert.Nil(t, err)
+	_, err = db.Exec("INSERT INTO counts (n, item) VALUES (nextval('limited'), 'd'), (nextval('limited'), 'e')")
+	assert.NotNil(t, err)
+
+	_, err = db.Exec("DROP SEQUENCE orders")
+	assert.NotNil(t, err)
+	_, err = db.Exec("DROP SEQUENCE limited")
+	assert.Nil(t, err)
+	_, err = db.Exec("DROP SEQUENCE IF EXISTS limited")
+	assert.Nil(t, err)
+
+	// the keywords of the sequence options are no reserved words
+	_, err = db.Exec("CREATE TABLE schedules (start INTEGER, increment INTEGER, cycle TEXT)")
+	assert.Nil(t, err)
+	_, err = db.Exec("INSERT INTO schedules (start, increment, cycle) VALUES (1, 7, 'weekly')")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, countRows(t, db, "SELECT COUNT(*) FROM schedules WHERE increment = 7"))
+}
+
+func TestAutoincrementSequence(t *testing.T) {
+	_, err := data.CreateDatabase("autoincrement_test")
+	assert.Nil(t, err)
+	defer data.DestroyDatabase("autoincrement_test")
+	db, err := sql.Open("GoSql", "memory:autoincrement_test")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE items (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT)")
+	assert.Nil(t, err)
+	_, err = db.Exec("INSERT INTO items (name) VALUES ('apple'), ('melon')")
+	assert.Nil(t, err)
+	var value int64
+	assert.Nil(t, db.QueryRow("SELECT nextval('items_id_seq') FROM items_id_seq").Scan(&value))
+	assert.Equal(t, int64(3), value)
+	_, err = db.Exec("INSERT INTO items (name) VALUES ('cherry')")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, countRows(t, db, "SELECT COUNT(*) FROM items WHERE id = 4 AND name = 'cherry'"))
+	assert.Equal(t, 0, countRows(t, db, "SELECT COUNT(*) FROM information_schema.tables WHERE table_name = 'items_id_seq'"))
+
+	// owned by the column, it is dropped with the table
+	_, err = db.Exec("DROP SEQUENCE items_id_seq")
+	assert.NotNil(t, err)
+	_, err = db.Exec("DROP TABLE items")
+	assert.Nil(t, err)
+	_, err = db.Exec("SELECT nextval('items_id_seq') FROM items_id_seq")
+	assert.NotNil(t, err)
+}
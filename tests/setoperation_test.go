@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/aschoerk/go-sql-mem/data"
+	_ "github.com/aschoerk/go-sql-mem/driver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetOperation(t *testing.T) {
+	_, err := data.CreateDatabase("setoperation_test")
+	assert.Nil(t, err)
+	defer data.DestroyDatabase("setoperation_test")
+	db, err := sql.Open("GoSql", "memory:setoperation_test")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE shop_a (id INTEGER PRIMARY KEY, name TEXT, price INTEGER)")
+	assert.Nil(t, err)
+	_, err = db.Exec("CREATE TABLE shop_b (id INTEGER PRIMARY KEY, name TEXT, price FLOAT)")
+	assert.Nil(t, err)
+	_, err = db.Exec("INSERT INTO shop_a (id, name, price) VALUES (1, 'apple', 3), (2, 'melon', 12), (3, 'cherry', 25), (4, 'apple', 4)")
+	assert.Nil(t, err)
+	_, err = db.Exec("INSERT INTO shop_b (id, name, price) VALUES (1, 'melon', 11.5), (2, 'grape', 5), (3, 'apple', 3)")
+	assert.Nil(t, err)
+
+	testCases := []struct {
+		name     string
+		query    string
+		args     []interface{}
+		expected []string
+	}{
+		{"union", "SELECT name FROM shop_a UNION SELECT name FROM shop_b ORDER BY name", nil, []string{"apple", "cherry", "grape", "melon"}},
+		{"union all", "SELECT name FROM shop_a UNION ALL SELECT name FROM shop_b ORDER BY name", nil, []string{"apple", "apple", "apple", "cherry", "grape", "melon", "melon"}},
+		{"intersect", "SELECT name FROM shop_a INTERSECT SELECT name FROM shop_b ORDER BY name", nil, []string{"apple", "melon"}},
+		{"intersect all", "SELECT name FROM shop_a INTERSECT ALL SELECT name FROM shop_b ORDER BY name", nil, []string{"apple", "melon"}},
+		{"except", "SELECT name FROM shop_a EXCEPT SELECT name FROM shop_b", nil, []string{"cherry"}},
+		{"except all", "SELECT name FROM shop_a EXCEPT ALL SELECT name FROM shop_b ORDER BY name", nil, []string{"apple", "cherry"}},
+		{"intersect binds stronger", "SELECT name FROM shop_b EXCEPT SELECT name FROM shop_a INTERSECT SELECT name FROM shop_a WHERE price > 20", nil, []string{"melon", "grape", "apple"}},
+		{"limit", "SELECT name FROM shop_a UNION SELECT name FROM shop_b ORDER BY name DESC LIMIT 2 OFFSET 1", nil, []string{"grape", "cherry"}},
+		{"placeholders", "SELECT name FROM shop_a WHERE price > ? UNION SELECT name FROM shop_b WHERE price < ? ORDER BY 1 LIMIT ?", []interface{}{10, 6, 3}, []string{"apple", "cherry", "grape"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rows, err := db.Query(tc.query, tc.args...)
+			if err != nil {
+				t.Fatalf("Failed to execute query: %v", err)
+			}
+			defer rows.Close()
+			var names []string
+			for rows.Next() {
+				var name string
+				assert.Nil(t, rows.Scan(&name))
+				names = append(names, name)
+			}
+			assert.Equal(t, tc.expected, names)
+		})
+	}
+
+	// INTEGER and FLOAT columns are combined as FLOAT
+	rows, err := db.Query("SELECT price FROM shop_a WHERE id = 1 UNION SELECT price FROM shop_b ORDER BY price")
+	assert.Nil(t, err)
+	var prices []float64
+	for rows.Next() {
+		var price float64
+		assert.Nil(t, rows.Scan(&price))
+		prices = append(prices, price)
+	}
+	rows.Close()
+	assert.Equal(t, []float64{3, 5, 11.5}, prices)
+
+	// times are the same, if they are the same instant, whatever their location
+	_, err = db.Exec("CREATE TABLE shop_opened (id INTEGER PRIMARY KEY, opened TIMESTAMP)")
+	assert.Nil(t, err)
+	opened := time.Date(2024, 5, 6, 7, 8, 9, 10, time.UTC)
+	_, err = db.Exec("INSERT INTO shop_opened (id, opened) VALUES (1, ?), (2, ?)", opened, opened.In(time.FixedZone("CEST", 2*3600)))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, countRows(t, db, "WITH o AS (SELECT opened FROM shop_opened WHERE id = 1 UNION SELECT opened FROM shop_opened WHERE id = 2) SELECT COUNT(*) FROM o"))
+	assert.Equal(t, 1, countRows(t, db, "WITH o AS (SELECT opened FROM shop_opened WHERE id = 1 INTERSECT SELECT opened FROM shop_opened WHERE id = 2) SELECT COUNT(*) FROM o"))
+
+	_, err = db.Query("SELECT name, price FROM shop_a UNION SELECT name FROM shop_b")
+	assert.NotNil(t, err)
+	_, err = db.Query("SELECT name FROM shop_a UNION SELECT name FROM shop_b FOR UPDATE")
+	assert.NotNil(t, err)
+
+	_, err = db.Exec("CREATE VIEW all_names AS SELECT name FROM shop_a UNION SELECT name FROM shop_b")
+	assert.Nil(t, err)
+	assert.Equal(t, 4, countRows(t, db, "SELECT COUNT(*) FROM all_names"))
+}
@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/aschoerk/go-sql-mem/data"
+	_ "github.com/aschoerk/go-sql-mem/driver"
+	"github.com/stretchr/testify/assert"
+)
+
+// two doctors are on call, each of them checks, that the other one stays, and leaves.
+// Returns the results of both commits and the number of doctors on duty afterwards.
+func doctorsLeaving(t *testing.T, isolation sql.IsolationLevel) (error, error, int) {
+	db, err := sql.Open("GoSql", "memory")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE IF NOT EXISTS on_call (name VARCHAR(20), on_duty BOOLEAN)")
+	assert.Nil(t, err)
+	_, err = db.Exec("DELETE FROM on_call")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, countRows(t, db, "SELECT COUNT(*) FROM on_call"))
+	_, err = db.Exec("INSERT INTO on_call (name, on_duty) VALUES ('alice', true), ('bob', true)")
+	assert.Nil(t, err)
+
+	ctx := context.Background()
+	tx1, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: isolation})
+	assert.Nil(t, err)
+	tx2, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: isolation})
+	assert.Nil(t, err)
+	var count1, count2 int
+	assert.Nil(t, tx1.QueryRow("SELECT COUNT(*) FROM on_call WHERE on_duty = true").Scan(&count1))
+	assert.Nil(t, tx2.QueryRow("SELECT COUNT(*) FROM on_call WHERE on_duty = true").Scan(&count2))
+	assert.Equal(t, 2, count1)
+	assert.Equal(t, 2, count2)
+	_, err = tx1.Exec("UPDATE on_call SET on_duty = false WHERE name = 'alice'")
+	assert.Nil(t, err)
+	_, err = tx2.Exec("UPDATE on_call SET on_duty = false WHERE name = 'bob'")
+	assert.Nil(t, err)
+	err1, err2 := tx1.Commit(), tx2.Commit()
+	return err1, err2, countRows(t, db, "SELECT COUNT(*) FROM on_call WHERE on_duty = true")
+}
+
+func TestWriteSkewRepeatableRead(t *testing.T) {
+	err1, err2, onDuty := doctorsLeaving(t, sql.LevelRepeatableRead)
+	assert.Nil(t, err1)
+	assert.Nil(t, err2)
+	// the write skew: nobody is on call anymore
+	assert.Equal(t, 0, onDuty)
+}
+
+func TestWriteSkewSerializable(t *testing.T) {
+	err1, err2, onDuty := doctorsLeaving(t, sql.LevelSerializable)
+	assert.Nil(t, err1)
+	assert.True(t, errors.Is(err2, data.ErrTraSerialization))
+	assert.Equal(t, 1, onDuty)
+}
@@ -16,19 +16,22 @@ var initialRecord = []Value{1}
 
 var nextUpdateValue = 100
 
+// the tests of transactions expect the xids to start at 1, so each one uses a new database
+const transactionTestDatabase = "transaction_test"
+
 func NewStatementBaseData(t *testing.T) *data.StatementBaseData {
-	conn, err := testdriver.Open("xxx")
+	conn, err := testdriver.Open("memory:" + transactionTestDatabase)
 	if err != nil {
 		t.Error(err)
 	}
 	conndata := &conn.(*driver.GoSqlConn).Data
-	return &data.StatementBaseData{conndata, nil, data.Executing}
+	return &data.StatementBaseData{Conn: conndata, State: data.Executing}
 }
 
 func InitTraAndTestTable() *data.GoSqlTable {
 	nextUpdateValue = 100
-	data.InitTransactionManager()
-	return data.NewTable(data.GoSqlIdentifier{[]string{"testtable"}}, []data.GoSqlColumn{{"x", parser.INTEGER, parser.INTEGER, 0, 0, false}})
+	data.DestroyDatabase(transactionTestDatabase)
+	return data.NewTable(data.GoSqlIdentifier{Parts: []string{"testtable"}}, []data.GoSqlColumn{{Name: "x", ColType: parser.INTEGER, ParserType: parser.INTEGER}})
 }
 
 func check(tuple data.Tuple) (bool, error) {
@@ -48,7 +51,7 @@ func TestSnapshotAndIteratorOnEmptyTable(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Nil(t, baseData.Conn.Transaction)
 	assert.Nil(t, baseData.SnapShot)
-	s := data.GetSnapShot(nil)
+	s := baseData.Conn.Database.GetSnapShot(nil)
 	assert.Equal(t, int64(0), s.Xmin())
 	assert.Equal(t, int64(2), s.Xmax())
 	assert.Equal(t, 0, len(s.RunningIds()))
@@ -65,7 +68,7 @@ func TestSnapshotOnOneRecord(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Nil(t, baseData.Conn.Transaction)
 	assert.Nil(t, baseData.SnapShot)
-	s := data.GetSnapShot(baseData.Conn.Transaction)
+	s := baseData.Conn.Database.GetSnapShot(baseData.Conn.Transaction)
 	assert.Equal(t, int64(0), s.Xmin())
 	assert.Equal(t, int64(2), s.Xmax())
 	assert.Equal(t, 0, len(s.RunningIds()))
@@ -80,7 +83,7 @@ func TestSnapshotAndReadOnlyIteratorOnOneRecord(t *testing.T) {
 	res, ok, _ := it.Next(check)
 	assert.True(t, ok)
 	assert.NotEqual(t, initialRecord, res)
-	s := data.GetSnapShot(baseData.Conn.Transaction)
+	s := baseData.Conn.Database.GetSnapShot(baseData.Conn.Transaction)
 	assert.Equal(t, int64(2), s.Xmin())
 	assert.Equal(t, int64(3), s.Xmax())
 	assert.Equal(t, 1, len(s.RunningIds()))
@@ -98,14 +101,14 @@ func TestSnapshotAndUpdateIteratorOnOneRecord(t *testing.T) {
 	res, ok, _ := it.Next(check)
 	assert.True(t, ok)
 	assert.NotEqual(t, initialRecord, res)
-	s := data.GetSnapShot(baseData.Conn.Transaction)
+	s := baseData.Conn.Database.GetSnapShot(baseData.Conn.Transaction)
 	assert.Equal(t, int64(2), s.Xmin())
 	assert.Equal(t, int64(3), s.Xmax())
 	assert.Equal(t, 1, len(s.RunningIds()))
 	_, ok, _ = it.Next(check)
 	assert.False(t, ok)
 	data.EndStatement(baseData)
-	s = data.GetSnapShot(baseData.Conn.Transaction)
+	s = baseData.Conn.Database.GetSnapShot(baseData.Conn.Transaction)
 	assert.Equal(t, int64(0), s.Xmin())
 	assert.Equal(t, int64(3), s.Xmax())
 	assert.Equal(t, 0, len(s.RunningIds()))
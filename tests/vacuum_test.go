@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"database/sql"
+	"testing"
+
+	_ "github.com/aschoerk/go-sql-mem/driver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVacuum(t *testing.T) {
+	// transactions left running by other tests would keep every version alive
+	db, err := sql.Open("GoSql", "memory:vacuum_test")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE vacuum_test (id INTEGER PRIMARY KEY AUTOINCREMENT, value INTEGER)")
+	assert.Nil(t, err)
+	_, err = db.Exec("INSERT INTO vacuum_test (value) VALUES (0), (100)")
+	assert.Nil(t, err)
+	for i := 1; i <= 10; i++ {
+		res, err := db.Exec("UPDATE vacuum_test SET value = ? WHERE value = ?", i, i-1)
+		assert.Nil(t, err)
+		updated, _ := res.RowsAffected()
+		assert.Equal(t, int64(1), updated)
+	}
+	_, err = db.Exec("DELETE FROM vacuum_test WHERE value = 100")
+	assert.Nil(t, err)
+
+	res, err := db.Exec("VACUUM vacuum_test")
+	assert.Nil(t, err)
+	removed, _ := res.RowsAffected()
+	// 10 replaced versions and the deleted tuple
+	assert.Equal(t, int64(11), removed)
+	assert.Equal(t, 1, countRows(t, db, "SELECT COUNT(*) FROM vacuum_test"))
+	assert.Equal(t, 1, countRows(t, db, "SELECT COUNT(*) FROM vacuum_test WHERE value = 10"))
+
+	res, err = db.Exec("VACUUM")
+	assert.Nil(t, err)
+	removed, _ = res.RowsAffected()
+	assert.Equal(t, int64(0), removed)
+}
+
+func TestVacuumAfterLimit(t *testing.T) {
+	db, err := sql.Open("GoSql", "memory:vacuum_limit_test")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE vacuum_limit_test (id INTEGER PRIMARY KEY, value INTEGER)")
+	assert.Nil(t, err)
+	_, err = db.Exec("INSERT INTO vacuum_limit_test (id, value) VALUES (1, 0), (2, 0)")
+	assert.Nil(t, err)
+
+	// the scan stops after the first tuple, the snapshot of its iterator is released anyway
+	var id int
+	assert.Nil(t, db.QueryRow("SELECT id FROM vacuum_limit_test LIMIT 1").Scan(&id))
+	_, err = db.Exec("UPDATE vacuum_limit_test SET value = 1")
+	assert.Nil(t, err)
+	res, err := db.Exec("VACUUM vacuum_limit_test")
+	assert.Nil(t, err)
+	removed, _ := res.RowsAffected()
+	assert.Equal(t, int64(2), removed)
+}
@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/aschoerk/go-sql-mem/data"
+	_ "github.com/aschoerk/go-sql-mem/driver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestView(t *testing.T) {
+	_, err := data.CreateDatabase("view_test")
+	assert.Nil(t, err)
+	defer data.DestroyDatabase("view_test")
+	db, err := sql.Open("GoSql", "memory:view_test")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE products (id INTEGER PRIMARY KEY, name TEXT, price INTEGER)")
+	assert.Nil(t, err)
+	_, err = db.Exec("INSERT INTO products (id, name, price) VALUES (1, 'apple', 3), (2, 'melon', 12), (3, 'cherry', 25)")
+	assert.Nil(t, err)
+	_, err = db.Exec("CREATE VIEW expensive (product, price) AS SELECT name, price FROM products WHERE price > 10")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, countRows(t, db, "SELECT COUNT(*) FROM expensive"))
+	var product string
+	err = db.QueryRow("SELECT product FROM expensive WHERE price > 20").Scan(&product)
+	assert.Nil(t, err)
+	assert.Equal(t, "cherry", product)
+	assert.Equal(t, 1, countRows(t, db, "SELECT COUNT(*) FROM information_schema.tables WHERE table_name = 'expensive' AND table_type = 'VIEW'"))
+
+	_, err = db.Exec("CREATE VIEW expensive AS SELECT name, price FROM products")
+	assert.NotNil(t, err)
+	_, err = db.Exec("INSERT INTO expensive (product, price) VALUES ('grape', 30)")
+	assert.NotNil(t, err)
+	// the columns must be kept
+	_, err = db.Exec("CREATE OR REPLACE VIEW expensive AS SELECT id, price FROM products WHERE price > 10")
+	assert.NotNil(t, err)
+	_, err = db.Exec("CREATE OR REPLACE VIEW expensive (product, price) AS SELECT name, price FROM products WHERE price > 5")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, countRows(t, db, "SELECT COUNT(*) FROM expensive"))
+
+	// a repeatable read transaction sees the view at its snapshot
+	tx, err := db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+	assert.Nil(t, err)
+	var count int
+	assert.Nil(t, tx.QueryRow("SELECT COUNT(*) FROM expensive").Scan(&count))
+	assert.Equal(t, 2, count)
+	_, err = db.Exec("INSERT INTO products (id, name, price) VALUES (4, 'mango', 8)")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, countRows(t, db, "SELECT COUNT(*) FROM expensive"))
+	assert.Nil(t, tx.QueryRow("SELECT COUNT(*) FROM expensive").Scan(&count))
+	assert.Equal(t, 2, count)
+	assert.Nil(t, tx.Commit())
+
+	// DROP VIEW is transactional
+	tx, err = db.BeginTx(context.Background(), nil)
+	assert.Nil(t, err)
+	_, err = tx.Exec("DROP VIEW expensive")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, countRows(t, db, "SELECT COUNT(*) FROM expensive"))
+	assert.Nil(t, tx.Rollback())
+	_, err = db.Exec("DROP VIEW products")
+	assert.NotNil(t, err)
+	_, err = db.Exec("DROP VIEW expensive")
+	assert.Nil(t, err)
+	_, err = db.Exec("SELECT COUNT(*) FROM expensive")
+	assert.NotNil(t, err)
+	_, err = db.Exec("DROP VIEW IF EXISTS expensive")
+	assert.Nil(t, err)
+}
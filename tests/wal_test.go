@@ -0,0 +1,142 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aschoerk/go-sql-mem/data"
+	_ "github.com/aschoerk/go-sql-mem/driver"
+	"github.com/stretchr/testify/assert"
+)
+
+// simulates a crash by forgetting everything the database logged in dir holds in memory
+func simulateCrash(t *testing.T, dir string) {
+	assert.Nil(t, data.DestroyDatabase("file:"+dir))
+}
+
+func countRows(t *testing.T, db *sql.DB, query string, args ...interface{}) int {
+	var count int
+	err := db.QueryRow(query, args...).Scan(&count)
+	if err != nil {
+		t.Fatalf("Failed to count rows: %v", err)
+	}
+	return count
+}
+
+func TestWalRecovery(t *testing.T) {
+	dir := t.TempDir()
+	db, err := sql.Open("GoSql", "file:"+dir)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	_, err = db.Exec("CREATE TABLE wal_test (id INTEGER PRIMARY KEY AUTOINCREMENT, value TEXT)")
+	assert.Nil(t, err)
+	_, err = db.Exec("INSERT INTO wal_test (value) VALUES ('committed'), ('to be updated'), ('to be deleted')")
+	assert.Nil(t, err)
+	_, err = db.Exec("UPDATE wal_test SET value = 'updated' WHERE value = 'to be updated'")
+	assert.Nil(t, err)
+	_, err = db.Exec("DELETE FROM wal_test WHERE value = 'to be deleted'")
+	assert.Nil(t, err)
+
+	tx, err := db.BeginTx(context.Background(), nil)
+	assert.Nil(t, err)
+	_, err = tx.Exec("INSERT INTO wal_test (value) VALUES ('uncommitted')")
+	assert.Nil(t, err)
+
+	simulateCrash(t, dir)
+
+	db2, err := sql.Open("GoSql", "file:"+dir)
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	defer db2.Close()
+	assert.Equal(t, 2, countRows(t, db2, "SELECT COUNT(*) FROM wal_test"))
+	assert.Equal(t, 1, countRows(t, db2, "SELECT COUNT(*) FROM wal_test WHERE value = 'updated'"))
+	assert.Equal(t, 0, countRows(t, db2, "SELECT COUNT(*) FROM wal_test WHERE value = 'uncommitted'"))
+
+	// autoincrement continues behind the values handed out before the crash, including the uncommitted one
+	_, err = db2.Exec("INSERT INTO wal_test (value) VALUES ('after recovery')")
+	assert.Nil(t, err)
+	var id int
+	assert.Nil(t, db2.QueryRow("SELECT id FROM wal_test WHERE value = 'after recovery'").Scan(&id))
+	assert.Equal(t, 5, id)
+	assert.Nil(t, data.DestroyDatabase("file:"+dir))
+}
+
+func TestWalIgnoresTornRecord(t *testing.T) {
+	dir := t.TempDir()
+	db, err := sql.Open("GoSql", "file:"+dir)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	_, err = db.Exec("CREATE TABLE wal_torn (value INTEGER)")
+	assert.Nil(t, err)
+	_, err = db.Exec("INSERT INTO wal_torn (value) VALUES (1)")
+	assert.Nil(t, err)
+	simulateCrash(t, dir)
+
+	f, err := os.OpenFile(filepath.Join(dir, "wal-0.log"), os.O_APPEND|os.O_WRONLY, 0o644)
+	assert.Nil(t, err)
+	_, err = f.Write([]byte{200, 0, 0, 0, 1, 2})
+	assert.Nil(t, err)
+	f.Close()
+
+	db2, err := sql.Open("GoSql", "file:"+dir)
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	defer db2.Close()
+	assert.Equal(t, 1, countRows(t, db2, "SELECT COUNT(*) FROM wal_torn"))
+	assert.Nil(t, data.DestroyDatabase("file:"+dir))
+}
+
+func TestWalCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	db, err := sql.Open("GoSql", "file:"+dir)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	_, err = db.Exec("CREATE TABLE wal_checkpoint (id INTEGER PRIMARY KEY AUTOINCREMENT, value TEXT)")
+	assert.Nil(t, err)
+	_, err = db.Exec("INSERT INTO wal_checkpoint (value) VALUES ('a'), ('b'), ('c')")
+	assert.Nil(t, err)
+	_, err = db.Exec("DELETE FROM wal_checkpoint WHERE value = 'b'")
+	assert.Nil(t, err)
+
+	// running during the checkpoint, committed afterwards
+	tx, err := db.BeginTx(context.Background(), nil)
+	assert.Nil(t, err)
+	_, err = tx.Exec("INSERT INTO wal_checkpoint (value) VALUES ('d')")
+	assert.Nil(t, err)
+
+	_, err = db.Exec("CHECKPOINT")
+	assert.Nil(t, err)
+	assert.Nil(t, tx.Commit())
+	_, err = db.Exec("UPDATE wal_checkpoint SET value = 'e' WHERE value = 'a'")
+	assert.Nil(t, err)
+
+	_, err = os.Stat(filepath.Join(dir, "wal-0.log"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(dir, "snapshot-1.dat"))
+	assert.Nil(t, err)
+
+	simulateCrash(t, dir)
+
+	db2, err := sql.Open("GoSql", "file:"+dir)
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	defer db2.Close()
+	assert.Equal(t, 3, countRows(t, db2, "SELECT COUNT(*) FROM wal_checkpoint"))
+	assert.Equal(t, 1, countRows(t, db2, "SELECT COUNT(*) FROM wal_checkpoint WHERE value = 'd'"))
+	assert.Equal(t, 1, countRows(t, db2, "SELECT COUNT(*) FROM wal_checkpoint WHERE value = 'e'"))
+	_, err = db2.Exec("INSERT INTO wal_checkpoint (value) VALUES ('f')")
+	assert.Nil(t, err)
+	var id int
+	assert.Nil(t, db2.QueryRow("SELECT id FROM wal_checkpoint WHERE value = 'f'").Scan(&id))
+	assert.Equal(t, 5, id)
+	assert.Nil(t, data.DestroyDatabase("file:"+dir))
+}
@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/aschoerk/go-sql-mem/data"
+	_ "github.com/aschoerk/go-sql-mem/driver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWindowFunctions(t *testing.T) {
+	_, err := data.CreateDatabase("window_test")
+	assert.Nil(t, err)
+	defer data.DestroyDatabase("window_test")
+	db, err := sql.Open("GoSql", "memory:window_test")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE employees (id INTEGER PRIMARY KEY, dept TEXT, salary INTEGER)")
+	assert.Nil(t, err)
+	_, err = db.Exec(`INSERT INTO employees (id, dept, salary) VALUES (1, 'sales', 10), (2, 'sales', 20), (3, 'sales', 20),
+		(4, 'dev', 5), (5, 'dev', 15), (6, 'sales', 30), (7, 'dev', NULL)`)
+	assert.Nil(t, err)
+
+	// the values computed for the employees in the order of their ids, nil for NULL
+	testCases := []struct {
+		name     string
+		query    string
+		args     []interface{}
+		expected []interface{}
+	}{
+		{"row_number", "SELECT id, ROW_NUMBER() OVER (PARTITION BY dept ORDER BY salary) AS w FROM employees ORDER BY id", nil,
+			[]interface{}{1, 2, 3, 1, 2, 4, 3}},
+		{"rank", "SELECT id, RANK() OVER (PARTITION BY dept ORDER BY salary) AS w FROM employees ORDER BY id", nil,
+			[]interface{}{1, 2, 2, 1, 2, 4, 3}},
+		{"dense_rank", "SELECT id, DENSE_RANK() OVER (PARTITION BY dept ORDER BY salary) AS w FROM employees ORDER BY id", nil,
+			[]interface{}{1, 2, 2, 1, 2, 3, 3}},
+		{"rank desc", "SELECT id, RANK() OVER (ORDER BY salary DESC) AS w FROM employees ORDER BY id", nil,
+			[]interface{}{6, 3, 3, 7, 5, 2, 1}},
+		{"lag", "SELECT id, LAG(salary) OVER (PARTITION BY dept ORDER BY salary) AS w FROM employees ORDER BY id", nil,
+			[]interface{}{nil, 10, 20, nil, 5, 20, 15}},
+		{"lead", "SELECT id, LEAD(id, 2, -1) OVER (PARTITION BY dept ORDER BY id) AS w FROM employees ORDER BY id", nil,
+			[]interface{}{3, 6, -1, 7, -1, -1, -1}},
+		{"lead placeholders", "SELECT id, LEAD(id, ?, ?) OVER (ORDER BY id) AS w FROM employees WHERE id < ? ORDER BY id", []interface{}{3, 0, 6},
+			[]interface{}{4, 5, 0, 0, 0}},
+		{"first_value", "SELECT id, FIRST_VALUE(id) OVER (PARTITION BY dept ORDER BY salary) AS w FROM employees ORDER BY id", nil,
+			[]interface{}{1, 1, 1, 4, 4, 1, 4}},
+		{"last_value", "SELECT id, LAST_VALUE(id) OVER (PARTITION BY dept ORDER BY salary) AS w FROM employees ORDER BY id", nil,
+			[]interface{}{1, 3, 3, 4, 5, 6, 7}},
+		{"last_value unbounded", `SELECT id, LAST_VALUE(id) OVER (PARTITION BY dept ORDER BY salary
+			ROWS BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING) AS w FROM employees ORDER BY id`, nil,
+			[]interface{}{6, 6, 6, 7, 7, 6, 7}},
+		{"running sum", "SELECT id, SUM(salary) OVER (PARTITION BY dept ORDER BY salary) AS w FROM employees ORDER BY id", nil,
+			[]interface{}{10, 50, 50, 5, 20, 80, 20}},
+		{"partition sum", "SELECT id, SUM(salary) OVER (PARTITION BY dept) AS w FROM employees ORDER BY id", nil,
+			[]interface{}{80, 80, 80, 20, 20, 80, 20}},
+		{"count", "SELECT id, COUNT(*) OVER () AS w FROM employees WHERE dept = 'dev' ORDER BY id", nil,
+			[]interface{}{3, 3, 3}},
+		{"rows", "SELECT id, SUM(salary) OVER (ORDER BY id ROWS BETWEEN 1 PRECEDING AND CURRENT ROW) AS w FROM employees ORDER BY id", nil,
+			[]interface{}{10, 30, 40, 25, 20, 45, 30}},
+		{"rows following", "SELECT id, MAX(salary) OVER (ORDER BY id ROWS BETWEEN CURRENT ROW AND 2 FOLLOWING) AS w FROM employees ORDER BY id", nil,
+			[]interface{}{20, 20, 20, 30, 30, 30, nil}},
+		{"range", "SELECT id, COUNT(salary) OVER (ORDER BY salary RANGE BETWEEN 10 PRECEDING AND 5 FOLLOWING) AS w FROM employees ORDER BY id", nil,
+			[]interface{}{3, 4, 4, 2, 5, 3, 0}},
+		{"range shorthand", "SELECT id, MIN(salary) OVER (ORDER BY salary RANGE 5 PRECEDING) AS w FROM employees ORDER BY id", nil,
+			[]interface{}{5, 15, 15, 5, 10, 30, nil}},
+		// ids 2 and 3 are peers, with RANGE CURRENT ROW stands for all of them, with ROWS for the tuple only
+		{"range current row", `SELECT id, SUM(salary) OVER (PARTITION BY dept ORDER BY salary
+			RANGE BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW) AS w FROM employees ORDER BY id`, nil,
+			[]interface{}{10, 50, 50, 5, 20, 80, 20}},
+		{"rows current row", `SELECT id, SUM(salary) OVER (PARTITION BY dept ORDER BY salary, id
+			ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW) AS w FROM employees ORDER BY id`, nil,
+			[]interface{}{10, 30, 50, 5, 20, 80, 20}},
+		{"range current row following", `SELECT id, SUM(salary) OVER (PARTITION BY dept ORDER BY salary
+			RANGE BETWEEN CURRENT ROW AND UNBOUNDED FOLLOWING) AS w FROM employees ORDER BY id`, nil,
+			[]interface{}{80, 70, 70, 20, 15, 30, nil}},
+		{"min current row following", `SELECT id, MIN(salary) OVER (PARTITION BY dept ORDER BY salary
+			RANGE BETWEEN CURRENT ROW AND UNBOUNDED FOLLOWING) AS w FROM employees ORDER BY id`, nil,
+			[]interface{}{10, 20, 20, 5, 15, 30, nil}},
+		{"rows sliding count", "SELECT id, COUNT(salary) OVER (ORDER BY id ROWS BETWEEN 1 FOLLOWING AND 2 FOLLOWING) AS w FROM employees ORDER BY id", nil,
+			[]interface{}{2, 2, 2, 2, 1, 0, 0}},
+		{"expression", "SELECT id, 100 + ROW_NUMBER() OVER (ORDER BY id DESC) AS w FROM employees ORDER BY id", nil,
+			[]interface{}{107, 106, 105, 104, 103, 102, 101}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rows, err := db.Query(tc.query, tc.args...)
+			if err != nil {
+				t.Fatalf("Failed to execute query: %v", err)
+			}
+			defer rows.Close()
+			var values []interface{}
+			for rows.Next() {
+				var id int
+				var value sql.NullInt64
+				assert.Nil(t, rows.Scan(&id, &value))
+				if value.Valid {
+					values = append(values, int(value.Int64))
+				} else {
+					values = append(values, nil)
+				}
+			}
+			assert.Equal(t, tc.expected, values)
+		})
+	}
+
+	// the window functions are computed before the tuples are ordered and limited
+	rows, err := db.Query("SELECT id, ROW_NUMBER() OVER (ORDER BY salary) AS w FROM employees ORDER BY w DESC LIMIT 2")
+	assert.Nil(t, err)
+	var ids []int
+	for rows.Next() {
+		var id, w int
+		assert.Nil(t, rows.Scan(&id, &w))
+		ids = append(ids, id)
+	}
+	rows.Close()
+	assert.Equal(t, []int{7, 6}, ids)
+
+	var avg float64
+	assert.Nil(t, db.QueryRow("SELECT AVG(salary) OVER (PARTITION BY dept) AS w FROM employees WHERE id = 4").Scan(&avg))
+	assert.Equal(t, 5.0, avg)
+
+	_, err = db.Query("SELECT id FROM employees WHERE ROW_NUMBER() OVER () > 1")
+	assert.NotNil(t, err)
+	_, err = db.Query("SELECT SUM(dept) OVER () AS w FROM employees")
+	assert.NotNil(t, err)
+	_, err = db.Query("SELECT ROW_NUMBER(id) OVER () AS w FROM employees")
+	assert.NotNil(t, err)
+	_, err = db.Query("SELECT LAG(id, 'one') OVER () AS w FROM employees")
+	assert.NotNil(t, err)
+	_, err = db.Query("SELECT SUM(salary) OVER (ORDER BY dept RANGE 1 PRECEDING) AS w FROM employees")
+	assert.NotNil(t, err)
+	_, err = db.Query("SELECT id, ROW_NUMBER() OVER () AS w FROM employees FOR UPDATE")
+	assert.NotNil(t, err)
+
+	// the words of the window functions are no reserved words
+	_, err = db.Exec("CREATE TABLE standings (rank INTEGER, range INTEGER, current TEXT, row_number INTEGER)")
+	assert.Nil(t, err)
+	_, err = db.Exec("INSERT INTO standings (rank, range, current, row_number) VALUES (1, 10, 'leader', 1), (2, 20, 'runner-up', 2)")
+	assert.Nil(t, err)
+	var current string
+	assert.Nil(t, db.QueryRow("SELECT current FROM standings WHERE rank = 2 AND range = 20").Scan(&current))
+	assert.Equal(t, "runner-up", current)
+	var rank, w int
+	assert.Nil(t, db.QueryRow("SELECT rank, RANK() OVER (ORDER BY range DESC) AS w FROM standings ORDER BY row_number").Scan(&rank, &w))
+	assert.Equal(t, 1, rank)
+	assert.Equal(t, 2, w)
+}
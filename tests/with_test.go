@@ -0,0 +1,101 @@
+package tests
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/aschoerk/go-sql-mem/data"
+	_ "github.com/aschoerk/go-sql-mem/driver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWith(t *testing.T) {
+	_, err := data.CreateDatabase("with_test")
+	assert.Nil(t, err)
+	defer data.DestroyDatabase("with_test")
+	db, err := sql.Open("GoSql", "memory:with_test")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE categories (id INTEGER PRIMARY KEY, parent_id INTEGER, name TEXT)")
+	assert.Nil(t, err)
+	_, err = db.Exec(`INSERT INTO categories (id, parent_id, name) VALUES (1, 0, 'food'), (2, 1, 'fruit'),
+		(3, 1, 'vegetables'), (4, 2, 'apple'), (5, 2, 'melon'), (6, 4, 'boskoop'), (7, 0, 'tools')`)
+	assert.Nil(t, err)
+
+	testCases := []struct {
+		name     string
+		query    string
+		args     []interface{}
+		expected []string
+	}{
+		{"with", "WITH fruit AS (SELECT id, name FROM categories WHERE parent_id = 2) SELECT name FROM fruit ORDER BY name", nil, []string{"apple", "melon"}},
+		{"column names", "WITH fruit (fruit_name) AS (SELECT name FROM categories WHERE parent_id = 2) SELECT fruit_name FROM fruit WHERE fruit_name <> 'melon'", nil, []string{"apple"}},
+		{"following tables", `WITH roots AS (SELECT id FROM categories WHERE parent_id = 0),
+			children AS (SELECT c.name AS name FROM categories c JOIN roots r ON c.parent_id = r.id)
+			SELECT name FROM children ORDER BY name`, nil, []string{"fruit", "vegetables"}},
+		{"hides table", "WITH categories AS (SELECT name FROM categories WHERE id = 7) SELECT name FROM categories", nil, []string{"tools"}},
+		{"placeholders", "WITH fruit AS (SELECT name FROM categories WHERE parent_id = ?) SELECT name FROM fruit WHERE name <> ? ORDER BY name", []interface{}{1, "fruit"}, []string{"vegetables"}},
+		{"recursive subtree", `WITH RECURSIVE subtree (id, name) AS (
+				SELECT id, name FROM categories WHERE id = ?
+				UNION ALL
+				SELECT c.id, c.name FROM categories c JOIN subtree s ON c.parent_id = s.id)
+			SELECT name FROM subtree ORDER BY id`, []interface{}{2}, []string{"fruit", "apple", "melon", "boskoop"}},
+		{"recursive ancestors", `WITH RECURSIVE ancestors (id, parent_id, name) AS (
+				SELECT id, parent_id, name FROM categories WHERE name = 'boskoop'
+				UNION
+				SELECT c.id, c.parent_id, c.name FROM categories c JOIN ancestors a ON c.id = a.parent_id)
+			SELECT name FROM ancestors WHERE id <> 6 ORDER BY id`, nil, []string{"food", "fruit", "apple"}},
+		{"recursive in set operation", `WITH RECURSIVE below_food (id) AS (
+				SELECT id FROM categories WHERE id = 1
+				UNION
+				SELECT c.id FROM categories c JOIN below_food b ON c.parent_id = b.id)
+			SELECT name FROM categories WHERE parent_id = 0 EXCEPT SELECT c.name FROM categories c JOIN below_food b ON c.id = b.id`, nil, []string{"tools"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rows, err := db.Query(tc.query, tc.args...)
+			if err != nil {
+				t.Fatalf("Failed to execute query: %v", err)
+			}
+			defer rows.Close()
+			var names []string
+			for rows.Next() {
+				var name string
+				assert.Nil(t, rows.Scan(&name))
+				names = append(names, name)
+			}
+			assert.Equal(t, tc.expected, names)
+		})
+	}
+
+	// the depth of each category, counted by the recursive select from the roots, whose parent_id is 0
+	var depth int
+	assert.Nil(t, db.QueryRow(`WITH RECURSIVE depths (id, depth) AS (
+			SELECT id, parent_id FROM categories WHERE parent_id = 0
+			UNION ALL
+			SELECT c.id, d.depth + 1 FROM categories c JOIN depths d ON c.parent_id = d.id)
+		SELECT depth FROM depths WHERE id = 6`).Scan(&depth))
+	assert.Equal(t, 3, depth)
+
+	_, err = db.Query("WITH a AS (SELECT id FROM categories), a AS (SELECT id FROM categories) SELECT id FROM a")
+	assert.NotNil(t, err)
+	_, err = db.Query("WITH a (x, y) AS (SELECT id FROM categories) SELECT x FROM a")
+	assert.NotNil(t, err)
+	_, err = db.Query(`WITH RECURSIVE a (id) AS (SELECT id FROM categories EXCEPT SELECT c.id FROM categories c JOIN a ON c.parent_id = a.id)
+		SELECT id FROM a`)
+	assert.NotNil(t, err)
+	// without RECURSIVE the table is not visible to its own select
+	_, err = db.Query(`WITH a (id) AS (SELECT id FROM categories WHERE id = 1 UNION SELECT c.id FROM categories c JOIN a ON c.parent_id = a.id)
+		SELECT id FROM a`)
+	assert.NotNil(t, err)
+
+	_, err = db.Exec(`CREATE VIEW fruit_tree AS WITH RECURSIVE t (id, name) AS (
+			SELECT id, name FROM categories WHERE id = 2
+			UNION ALL
+			SELECT c.id, c.name FROM categories c JOIN t ON c.parent_id = t.id)
+		SELECT name FROM t`)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, countRows(t, db, "SELECT COUNT(*) FROM fruit_tree"))
+}